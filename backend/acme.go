@@ -0,0 +1,381 @@
+/*
+ * @Copyright Reserved By Janusec (https://www.janusec.com/).
+ * @Author: U2
+ * @Date: 2026-10-19 10:12:48
+ * @Last Modified: U2, 2026-10-19 10:12:48
+ */
+
+package backend
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"janusec/data"
+	"janusec/models"
+	"janusec/utils"
+
+	"golang.org/x/crypto/acme"
+)
+
+var (
+	acmeClient *acme.Client
+
+	// acmeLock serializes the ACME orders and the updates of Certs
+	acmeLock sync.Mutex
+
+	// acmeChallenges are the TXT records of pending DNS-01 challenges, name => values
+	acmeChallenges     = map[string][]string{}
+	acmeChallengesLock sync.RWMutex
+)
+
+// parseACMEDomains convert comma separated domains to lowercase list
+func parseACMEDomains(domainsStr string) []string {
+	domains := []string{}
+	for _, domain := range strings.Split(domainsStr, ",") {
+		domain = strings.ToLower(strings.TrimSpace(domain))
+		if len(domain) > 0 && !slices.Contains(domains, domain) {
+			domains = append(domains, domain)
+		}
+	}
+	return domains
+}
+
+// GetACMEChallengeValues return the TXT values of _acme-challenge name, used by the DNS handler
+func GetACMEChallengeValues(name string) []string {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	acmeChallengesLock.RLock()
+	defer acmeChallengesLock.RUnlock()
+	return slices.Clone(acmeChallenges[name])
+}
+
+func addACMEChallenge(name string, value string) {
+	acmeChallengesLock.Lock()
+	defer acmeChallengesLock.Unlock()
+	acmeChallenges[name] = append(acmeChallenges[name], value)
+}
+
+func removeACMEChallenge(name string, value string) {
+	acmeChallengesLock.Lock()
+	defer acmeChallengesLock.Unlock()
+	values := slices.DeleteFunc(acmeChallenges[name], func(v string) bool {
+		return v == value
+	})
+	if len(values) == 0 {
+		delete(acmeChallenges, name)
+		return
+	}
+	acmeChallenges[name] = values
+}
+
+// getACMEDNSDomain return the DNS domain which answers the challenge of domain, the same as DNS handler
+func getACMEDNSDomain(domain string) (*models.DNSDomain, error) {
+	labels := strings.Split(strings.TrimPrefix(domain, "*."), ".")
+	if len(labels) < 2 {
+		return nil, errors.New("invalid domain " + domain)
+	}
+	dnsDomainName := labels[len(labels)-2] + "." + labels[len(labels)-1]
+	dnsDomain, err := GetDNSDomainByName(dnsDomainName)
+	if err != nil {
+		return nil, errors.New("DNS domain " + dnsDomainName + " not found for " + domain)
+	}
+	return dnsDomain, nil
+}
+
+// getACMEAccountKey load the account key from settings, or generate a new one
+func getACMEAccountKey() (crypto.Signer, error) {
+	if data.DAL.ExistsSetting("acme_account_key") {
+		encryptedKey, err := hex.DecodeString(data.DAL.SelectStringSetting("acme_account_key"))
+		if err != nil {
+			return nil, err
+		}
+		keyDER, err := data.AES256Decrypt(encryptedKey, false)
+		if err != nil {
+			return nil, err
+		}
+		return x509.ParseECPrivateKey(keyDER)
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	err = data.DAL.SaveStringSetting("acme_account_key", hex.EncodeToString(data.AES256Encrypt(keyDER, false)))
+	return key, err
+}
+
+// getACMEClient return the registered client of the ACME directory
+func getACMEClient(ctx context.Context) (*acme.Client, error) {
+	if acmeClient != nil {
+		return acmeClient, nil
+	}
+	acmeConfig := data.CFG.ACME
+	httpClient := &http.Client{Timeout: 60 * time.Second}
+	if len(acmeConfig.CACert) > 0 {
+		caPEM, err := os.ReadFile(acmeConfig.CACert)
+		if err != nil {
+			return nil, err
+		}
+		certPool := x509.NewCertPool()
+		if !certPool.AppendCertsFromPEM(caPEM) {
+			return nil, errors.New("invalid CA certificate " + acmeConfig.CACert)
+		}
+		httpClient.Transport = &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{RootCAs: certPool},
+		}
+	}
+	key, err := getACMEAccountKey()
+	if err != nil {
+		return nil, err
+	}
+	client := &acme.Client{
+		Key:          key,
+		DirectoryURL: acmeConfig.DirectoryURL,
+		HTTPClient:   httpClient,
+		UserAgent:    "Janusec",
+	}
+	account := &acme.Account{}
+	if len(acmeConfig.Email) > 0 {
+		account.Contact = []string{"mailto:" + acmeConfig.Email}
+	}
+	_, err = client.Register(ctx, account, acme.AcceptTOS)
+	if err != nil && !errors.Is(err, acme.ErrAccountAlreadyExists) {
+		return nil, err
+	}
+	acmeClient = client
+	return client, nil
+}
+
+// generateACMECertKey return the private key of certificate and its PEM, ecdsa P-256 or rsa 2048
+func generateACMECertKey(keyType string) (crypto.Signer, []byte, error) {
+	if keyType == "rsa" {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, nil, err
+		}
+		keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+		return key, keyPEM, nil
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return key, keyPEM, nil
+}
+
+// obtainACMECertificate order the certificate of domains, the DNS-01 challenges are published
+// as _acme-challenge TXT records of the built-in DNS server, return PEM of certificate chain and key
+func obtainACMECertificate(ctx context.Context, domains []string, keyType string) ([]byte, []byte, error) {
+	for _, domain := range domains {
+		if _, err := getACMEDNSDomain(domain); err != nil {
+			return nil, nil, err
+		}
+	}
+	client, err := getACMEClient(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	order, err := client.AuthorizeOrder(ctx, acme.DomainIDs(domains...))
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, authzURL := range order.AuthzURLs {
+		authz, err := client.GetAuthorization(ctx, authzURL)
+		if err != nil {
+			return nil, nil, err
+		}
+		if authz.Status == acme.StatusValid {
+			continue
+		}
+		var challenge *acme.Challenge
+		for _, c := range authz.Challenges {
+			if c.Type == "dns-01" {
+				challenge = c
+				break
+			}
+		}
+		if challenge == nil {
+			return nil, nil, errors.New("no dns-01 challenge for " + authz.Identifier.Value)
+		}
+		value, err := client.DNS01ChallengeRecord(challenge.Token)
+		if err != nil {
+			return nil, nil, err
+		}
+		// *.example.com and example.com share the same record name
+		name := "_acme-challenge." + strings.TrimPrefix(strings.ToLower(authz.Identifier.Value), "*.")
+		addACMEChallenge(name, value)
+		defer removeACMEChallenge(name, value)
+		if _, err = client.Accept(ctx, challenge); err != nil {
+			return nil, nil, err
+		}
+		if _, err = client.WaitAuthorization(ctx, authz.URI); err != nil {
+			return nil, nil, err
+		}
+	}
+	order, err = client.WaitOrder(ctx, order.URI)
+	if err != nil {
+		return nil, nil, err
+	}
+	key, keyPEM, err := generateACMECertKey(keyType)
+	if err != nil {
+		return nil, nil, err
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{DNSNames: domains}, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	chainDER, _, err := client.CreateOrderCert(ctx, order.FinalizeURL, csr, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	certPEM := []byte{}
+	for _, certDER := range chainDER {
+		certPEM = append(certPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})...)
+	}
+	return certPEM, keyPEM, nil
+}
+
+// issueACMECertificate obtain the certificate of certItem.ACMEDomains and save it to the certificates table,
+// replica nodes will load it with the other certificates
+func issueACMECertificate(certItem *models.CertItem, keyType string) error {
+	if !data.PrimarySetting.DNSEnabled {
+		return errors.New("the built-in DNS server is not enabled, DNS-01 challenges can not be answered")
+	}
+	acmeLock.Lock()
+	defer acmeLock.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	certPEM, keyPEM, err := obtainACMECertificate(ctx, certItem.ACMEDomains, keyType)
+	if err != nil {
+		return err
+	}
+	tlsCert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return err
+	}
+	certItem.CommonName = certItem.ACMEDomains[0]
+	certItem.CertContent = string(certPEM)
+	certItem.PrivKeyContent = string(keyPEM)
+	certItem.TlsCert = tlsCert
+	certItem.ExpireTime = data.GetCertificateExpiryTime(certItem.CertContent)
+	encryptedPrivKey := data.AES256Encrypt(keyPEM, false)
+	acmeDomains := strings.Join(certItem.ACMEDomains, ",")
+	if certItem.ID == 0 {
+		certItem.ID = data.DAL.InsertCertificate(certItem.CommonName, certItem.CertContent, encryptedPrivKey, certItem.ExpireTime, certItem.Description, acmeDomains)
+		Certs = append(Certs, certItem)
+	} else {
+		err = data.DAL.UpdateCertificate(certItem.CommonName, certItem.CertContent, encryptedPrivKey, certItem.ExpireTime, certItem.Description, acmeDomains, certItem.ID)
+		if err != nil {
+			return err
+		}
+		UpdateCerts(certItem)
+		refreshCertRelations(certItem)
+	}
+	data.UpdateBackendLastModified()
+	return nil
+}
+
+// getCertKeyType return ecdsa or rsa, used for renewal
+func getCertKeyType(certItem *models.CertItem) string {
+	if _, ok := certItem.TlsCert.PrivateKey.(*rsa.PrivateKey); ok {
+		return "rsa"
+	}
+	return "ecdsa"
+}
+
+// ApplyACMECertificate obtain a new certificate, or renew the certificate of ObjectID immediately
+func ApplyACMECertificate(body []byte, clientIP string, authUser *models.AuthUser) (*models.CertItem, error) {
+	if !authUser.IsCertAdmin {
+		return nil, errors.New("no privileges")
+	}
+	var apiACMECertRequest models.APIACMECertRequest
+	if err := json.Unmarshal(body, &apiACMECertRequest); err != nil {
+		utils.DebugPrintln("ApplyACMECertificate Unmarshal", err)
+		return nil, err
+	}
+	order := apiACMECertRequest.Object
+	if order == nil {
+		order = &models.ACMECertOrder{}
+	}
+	certItem := &models.CertItem{Description: order.Description}
+	if apiACMECertRequest.ObjectID > 0 {
+		oldCert, err := SysCallGetCertByID(apiACMECertRequest.ObjectID)
+		if err != nil {
+			return nil, err
+		}
+		certItem.ID = oldCert.ID
+		if len(order.Domains) == 0 {
+			order.Domains = oldCert.ACMEDomains
+		}
+		if len(order.KeyType) == 0 {
+			order.KeyType = getCertKeyType(oldCert)
+		}
+		if len(certItem.Description) == 0 {
+			certItem.Description = oldCert.Description
+		}
+	}
+	certItem.ACMEDomains = parseACMEDomains(strings.Join(order.Domains, ","))
+	if len(certItem.ACMEDomains) == 0 {
+		return nil, errors.New("domains of the certificate are required")
+	}
+	err := issueACMECertificate(certItem, order.KeyType)
+	if err != nil {
+		utils.DebugPrintln("ApplyACMECertificate", certItem.ACMEDomains, err)
+		return nil, err
+	}
+	go utils.OperationLog(clientIP, authUser.Username, "Apply ACME Certificate", certItem.CommonName)
+	return certItem, nil
+}
+
+// RoutineRenewACMECerts renew the certificates obtained by ACME before expiry, primary node only
+func RoutineRenewACMECerts() {
+	for {
+		renewACMECerts()
+		time.Sleep(12 * time.Hour)
+	}
+}
+
+func renewACMECerts() {
+	renewBefore := time.Duration(data.CFG.ACME.RenewBefore) * 24 * time.Hour
+	for _, certItem := range slices.Clone(Certs) {
+		if len(certItem.ACMEDomains) == 0 || time.Until(time.Unix(certItem.ExpireTime, 0)) > renewBefore {
+			continue
+		}
+		newCert := &models.CertItem{
+			ID:          certItem.ID,
+			Description: certItem.Description,
+			ACMEDomains: certItem.ACMEDomains,
+		}
+		err := issueACMECertificate(newCert, getCertKeyType(certItem))
+		if err != nil {
+			utils.DebugPrintln("renewACMECerts", certItem.ACMEDomains, err)
+			continue
+		}
+		utils.DebugPrintln("renewACMECerts", certItem.ACMEDomains, "renewed")
+	}
+}
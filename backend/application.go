@@ -10,11 +10,9 @@ package backend
 import (
 	"encoding/json"
 	"errors"
-	"hash/fnv"
 	"net/http"
-	"path/filepath"
 	"strings"
-	"sync"
+	"time"
 
 	"janusec/data"
 	"janusec/firewall"
@@ -43,68 +41,67 @@ func SelectDestination(app *models.Application) string {
 */
 
 // SelectBackendRoute will replace SelectDestination
-func SelectBackendRoute(app *models.Application, r *http.Request, srcIP string) *models.Destination {
-	routePath := utils.GetRoutePath(r.URL.Path)
-	var dests []*models.Destination
-	hit := false
-	if routePath != "/" {
-		// First check /abc/
-		valueI, ok := app.Route.Load(routePath)
-		if ok {
-			hit = true
-			dests = valueI.([]*models.Destination)
-		}
+// group is the destination group selected by traffic split, used only when the route has traffic split
+func SelectBackendRoute(app *models.Application, r *http.Request, srcIP string, group string) *models.Destination {
+	entry := GetRouteEntry(app, r, r.URL.Path)
+	if entry == nil {
+		return nil
+	}
+	dest := selectOnlineDestination(app, entry, getGroupDestinations(entry, group), r, srcIP, nil)
+	if dest == nil {
+		// no online destination in the group, fall back to the remaining groups
+		dest = selectOnlineDestination(app, entry, getFallbackDestinations(entry, group), r, srcIP, nil)
 	}
+	if dest == nil {
+		return nil
+	}
+	r.URL.Path = RewriteRoutePath(dest, r.URL.Path)
+	return dest
+}
 
-	if !hit {
-		// Second check .php
-		ext := filepath.Ext(r.URL.Path)
-		valueI, ok := app.Route.Load(ext)
-		// Third check /
-		if !ok {
-			valueI, ok = app.Route.Load("/")
-		}
-		if !ok {
-			// lack of route /
-			return nil
-		}
-		dests = valueI.([]*models.Destination)
+// SelectRetryDestination select another online destination of the same route, exclude destinations tried
+// urlPath is the original path before rewriting
+func SelectRetryDestination(app *models.Application, r *http.Request, srcIP string, urlPath string, group string, tried []*models.Destination) *models.Destination {
+	entry := GetRouteEntry(app, r, urlPath)
+	if entry == nil {
+		return nil
+	}
+	dest := selectOnlineDestination(app, entry, getGroupDestinations(entry, group), r, srcIP, tried)
+	if dest == nil {
+		dest = selectOnlineDestination(app, entry, getFallbackDestinations(entry, group), r, srcIP, tried)
 	}
+	return dest
+}
 
+func selectOnlineDestination(app *models.Application, entry *models.RouteEntry, dests []*models.Destination, r *http.Request, srcIP string, excluded []*models.Destination) *models.Destination {
 	// get online destinations
+	nowTimeStamp := time.Now().Unix()
 	var onlineDests = []*models.Destination{}
+	var ejectedDests = []*models.Destination{}
 	for _, dest := range dests {
-		dest.Mutex.Lock()
-		defer dest.Mutex.Unlock()
-		if dest.Online {
-			onlineDests = append(onlineDests, dest)
+		if ContainsDestinationID(excluded, dest.ID) {
+			continue
 		}
-	}
-
-	destLen := uint32(len(onlineDests))
-	if destLen == 0 {
-		return nil
-	}
-	var dest *models.Destination
-	if destLen == 1 {
-		dest = onlineDests[0]
-	} else if destLen > 1 {
-		// According to Hash(IP+UA)
-		h := fnv.New32a()
-		_, err := h.Write([]byte(srcIP + r.UserAgent()))
-		if err != nil {
-			utils.DebugPrintln("SelectBackendRoute h.Write", err)
+		dest.Mutex.RLock()
+		online := dest.Online
+		admitted := online && IsDestinationAdmitted(app, dest, nowTimeStamp)
+		dest.Mutex.RUnlock()
+		if online {
+			if admitted {
+				onlineDests = append(onlineDests, dest)
+			} else {
+				ejectedDests = append(ejectedDests, dest)
+			}
 		}
-		hashUInt32 := h.Sum32()
-		destIndex := hashUInt32 % destLen
-		dest = onlineDests[destIndex]
 	}
-	if dest.RouteType == models.ReverseProxyRoute {
-		if dest.RequestRoute != dest.BackendRoute {
-			r.URL.Path = strings.Replace(r.URL.Path, dest.RequestRoute, dest.BackendRoute, 1)
-		}
+	if len(onlineDests) == 0 {
+		// all online destinations are ejected, use them instead of no destination
+		onlineDests = ejectedDests
 	}
-	return dest
+	if len(onlineDests) == 0 {
+		return nil
+	}
+	return SelectDestination(entry, onlineDests, r, srcIP)
 }
 
 // GetApplicationByID ...
@@ -137,7 +134,7 @@ func GetApplicationByDomain(domain string) *models.Application {
 	if domainRelation, ok := DomainsMap.Load(wildDomain); ok {
 		domainRelation2 := domainRelation.(models.DomainRelation)
 		app := domainRelation2.App //DomainsMap[domain].App
-		DomainsMap.Store(domain, models.DomainRelation{App: app, Cert: domainRelation2.Cert, Redirect: false, Location: ""})
+		DomainsMap.Store(domain, models.DomainRelation{App: app, Cert: domainRelation2.Cert, Certs: domainRelation2.Certs, Redirect: false, Location: "", TLSProfile: domainRelation2.TLSProfile})
 		return app
 	}
 	return nil
@@ -159,7 +156,6 @@ func LoadApps() {
 				ClientIPMethod: dbApp.ClientIPMethod,
 				Description:    dbApp.Description,
 				Destinations:   []*models.Destination{},
-				Route:          sync.Map{},
 				OAuthRequired:  dbApp.OAuthRequired,
 				SessionSeconds: dbApp.SessionSeconds,
 				Owner:          dbApp.Owner,
@@ -179,10 +175,23 @@ func LoadApps() {
 				UnclassifiedNotice: dbApp.UnclassifiedNotice,
 				EnableUnclassified: dbApp.EnableUnclassified,
 				CustomHeaders:      GetCustomHeaders(dbApp.CustomHeaders),
+				RetryPolicy:        GetRetryPolicy(dbApp.RetryPolicy),
+				TrafficSplits:      GetTrafficSplits(dbApp.TrafficSplits),
+				HeaderRules:        GetHeaderRules(dbApp.HeaderRules),
+				ForwardedPolicy:    dbApp.ForwardedPolicy,
+				TrustedProxies:     dbApp.TrustedProxies,
+				RequestLimits:      GetRequestLimits(dbApp.RequestLimits),
+				ErrorPages:         GetErrorPages(dbApp.ErrorPages),
+				Maintenance:        GetMaintenance(dbApp.Maintenance),
+				Compression:        GetCompression(dbApp.Compression),
+				CachePolicy:        GetCachePolicy(dbApp.CachePolicy),
+				UpstreamTLS:        GetUpstreamTLS(dbApp.UpstreamTLS),
+				ClientAuth:         GetClientAuth(dbApp.ClientAuth),
 			}
 			// Load Cookies of each App
 			InitAppConsentCookie(app.ID)
 			app.Cookies = data.DAL.SelectCookiesByAppID(app.ID)
+			app.RewriteRules = data.DAL.SelectRewriteRulesByAppID(app.ID)
 
 			Apps = append(Apps, app)
 		}
@@ -225,34 +234,320 @@ func GetCustomHeadersString(customHeaders []*models.CustomHeader) string {
 	return headersStr
 }
 
+// GetRetryPolicy convert JSON string to RetryPolicy, return nil if empty
+func GetRetryPolicy(retryPolicyStr string) *models.RetryPolicy {
+	if len(retryPolicyStr) == 0 {
+		return nil
+	}
+	retryPolicy := &models.RetryPolicy{}
+	err := json.Unmarshal([]byte(retryPolicyStr), retryPolicy)
+	if err != nil {
+		utils.DebugPrintln("GetRetryPolicy Unmarshal", err)
+		return nil
+	}
+	return retryPolicy
+}
+
+// GetRetryPolicyString convert RetryPolicy to JSON string
+func GetRetryPolicyString(retryPolicy *models.RetryPolicy) string {
+	if retryPolicy == nil {
+		return ""
+	}
+	retryPolicyBytes, err := json.Marshal(retryPolicy)
+	if err != nil {
+		utils.DebugPrintln("GetRetryPolicyString Marshal", err)
+		return ""
+	}
+	return string(retryPolicyBytes)
+}
+
+// GetRequestLimits convert JSON string to RequestLimits, return nil if empty
+func GetRequestLimits(requestLimitsStr string) *models.RequestLimits {
+	if len(requestLimitsStr) == 0 {
+		return nil
+	}
+	requestLimits := &models.RequestLimits{}
+	err := json.Unmarshal([]byte(requestLimitsStr), requestLimits)
+	if err != nil {
+		utils.DebugPrintln("GetRequestLimits Unmarshal", err)
+		return nil
+	}
+	return requestLimits
+}
+
+// GetRequestLimitsString convert RequestLimits to JSON string
+func GetRequestLimitsString(requestLimits *models.RequestLimits) string {
+	if requestLimits == nil {
+		return ""
+	}
+	requestLimitsBytes, err := json.Marshal(requestLimits)
+	if err != nil {
+		utils.DebugPrintln("GetRequestLimitsString Marshal", err)
+		return ""
+	}
+	return string(requestLimitsBytes)
+}
+
+// GetErrorPages convert JSON string to ErrorPageConfig, return nil if empty
+func GetErrorPages(errorPagesStr string) *models.ErrorPageConfig {
+	if len(errorPagesStr) == 0 {
+		return nil
+	}
+	errorPages := &models.ErrorPageConfig{}
+	err := json.Unmarshal([]byte(errorPagesStr), errorPages)
+	if err != nil {
+		utils.DebugPrintln("GetErrorPages Unmarshal", err)
+		return nil
+	}
+	return errorPages
+}
+
+// GetErrorPagesString convert ErrorPageConfig to JSON string
+func GetErrorPagesString(errorPages *models.ErrorPageConfig) string {
+	if errorPages == nil {
+		return ""
+	}
+	errorPagesBytes, err := json.Marshal(errorPages)
+	if err != nil {
+		utils.DebugPrintln("GetErrorPagesString Marshal", err)
+		return ""
+	}
+	return string(errorPagesBytes)
+}
+
+// GetMaintenance convert JSON string to MaintenanceConfig, return nil if empty
+func GetMaintenance(maintenanceStr string) *models.MaintenanceConfig {
+	if len(maintenanceStr) == 0 {
+		return nil
+	}
+	maintenance := &models.MaintenanceConfig{}
+	err := json.Unmarshal([]byte(maintenanceStr), maintenance)
+	if err != nil {
+		utils.DebugPrintln("GetMaintenance Unmarshal", err)
+		return nil
+	}
+	return maintenance
+}
+
+// GetMaintenanceString convert MaintenanceConfig to JSON string
+func GetMaintenanceString(maintenance *models.MaintenanceConfig) string {
+	if maintenance == nil {
+		return ""
+	}
+	maintenanceBytes, err := json.Marshal(maintenance)
+	if err != nil {
+		utils.DebugPrintln("GetMaintenanceString Marshal", err)
+		return ""
+	}
+	return string(maintenanceBytes)
+}
+
+// GetCompression convert JSON string to CompressionConfig, return nil if empty
+func GetCompression(compressionStr string) *models.CompressionConfig {
+	if len(compressionStr) == 0 {
+		return nil
+	}
+	compression := &models.CompressionConfig{}
+	err := json.Unmarshal([]byte(compressionStr), compression)
+	if err != nil {
+		utils.DebugPrintln("GetCompression Unmarshal", err)
+		return nil
+	}
+	return compression
+}
+
+// GetCompressionString convert CompressionConfig to JSON string
+func GetCompressionString(compression *models.CompressionConfig) string {
+	if compression == nil {
+		return ""
+	}
+	compressionBytes, err := json.Marshal(compression)
+	if err != nil {
+		utils.DebugPrintln("GetCompressionString Marshal", err)
+		return ""
+	}
+	return string(compressionBytes)
+}
+
+// GetCachePolicy convert JSON string to CachePolicy, return nil if empty
+func GetCachePolicy(cachePolicyStr string) *models.CachePolicy {
+	if len(cachePolicyStr) == 0 {
+		return nil
+	}
+	cachePolicy := &models.CachePolicy{}
+	err := json.Unmarshal([]byte(cachePolicyStr), cachePolicy)
+	if err != nil {
+		utils.DebugPrintln("GetCachePolicy Unmarshal", err)
+		return nil
+	}
+	return cachePolicy
+}
+
+// GetCachePolicyString convert CachePolicy to JSON string
+func GetCachePolicyString(cachePolicy *models.CachePolicy) string {
+	if cachePolicy == nil {
+		return ""
+	}
+	cachePolicyBytes, err := json.Marshal(cachePolicy)
+	if err != nil {
+		utils.DebugPrintln("GetCachePolicyString Marshal", err)
+		return ""
+	}
+	return string(cachePolicyBytes)
+}
+
+// GetUpstreamTLS convert JSON string to UpstreamTLS, return nil if empty
+func GetUpstreamTLS(upstreamTLSStr string) *models.UpstreamTLS {
+	if len(upstreamTLSStr) == 0 {
+		return nil
+	}
+	upstreamTLS := &models.UpstreamTLS{}
+	err := json.Unmarshal([]byte(upstreamTLSStr), upstreamTLS)
+	if err != nil {
+		utils.DebugPrintln("GetUpstreamTLS Unmarshal", err)
+		return nil
+	}
+	return upstreamTLS
+}
+
+// GetUpstreamTLSString convert UpstreamTLS to JSON string
+func GetUpstreamTLSString(upstreamTLS *models.UpstreamTLS) string {
+	if upstreamTLS == nil {
+		return ""
+	}
+	upstreamTLSBytes, err := json.Marshal(upstreamTLS)
+	if err != nil {
+		utils.DebugPrintln("GetUpstreamTLSString Marshal", err)
+		return ""
+	}
+	return string(upstreamTLSBytes)
+}
+
+// GetClientAuth convert JSON string to ClientAuthConfig, return nil if empty
+func GetClientAuth(clientAuthStr string) *models.ClientAuthConfig {
+	if len(clientAuthStr) == 0 {
+		return nil
+	}
+	clientAuth := &models.ClientAuthConfig{}
+	err := json.Unmarshal([]byte(clientAuthStr), clientAuth)
+	if err != nil {
+		utils.DebugPrintln("GetClientAuth Unmarshal", err)
+		return nil
+	}
+	return clientAuth
+}
+
+// GetClientAuthString convert ClientAuthConfig to JSON string
+func GetClientAuthString(clientAuth *models.ClientAuthConfig) string {
+	if clientAuth == nil {
+		return ""
+	}
+	clientAuthBytes, err := json.Marshal(clientAuth)
+	if err != nil {
+		utils.DebugPrintln("GetClientAuthString Marshal", err)
+		return ""
+	}
+	return string(clientAuthBytes)
+}
+
+// SetAppMaintenance turn on or off the maintenance mode of an application, replicas take effect on the next sync
+func SetAppMaintenance(body []byte, clientIP string, authUser *models.AuthUser) (*models.MaintenanceConfig, error) {
+	var maintenanceRequest models.APIMaintenanceRequest
+	if err := json.Unmarshal(body, &maintenanceRequest); err != nil {
+		utils.DebugPrintln("SetAppMaintenance", err)
+		return nil, err
+	}
+	app, err := GetApplicationByID(maintenanceRequest.ObjectID)
+	if err != nil {
+		return nil, err
+	}
+	if !authUser.IsAppAdmin && !authUser.IsSuperAdmin && app.Owner != authUser.Username {
+		return nil, errors.New("no privilege to perform this operation")
+	}
+	maintenance := maintenanceRequest.Object
+	if maintenance != nil && maintenance.EndTime > 0 && maintenance.EndTime <= maintenance.StartTime {
+		return nil, errors.New("end_time should be later than start_time")
+	}
+	err = data.DAL.UpdateApplicationMaintenance(GetMaintenanceString(maintenance), app.ID)
+	if err != nil {
+		return nil, err
+	}
+	app.Maintenance = maintenance
+	operation := "Disable Maintenance"
+	if maintenance != nil && maintenance.Enabled {
+		operation = "Enable Maintenance"
+	}
+	go utils.OperationLog(clientIP, authUser.Username, operation, app.Name)
+	data.UpdateBackendLastModified()
+	return maintenance, nil
+}
+
+// GetTrafficSplits convert JSON string to traffic splits
+func GetTrafficSplits(trafficSplitsStr string) []*models.TrafficSplit {
+	trafficSplits := []*models.TrafficSplit{}
+	if len(trafficSplitsStr) == 0 {
+		return trafficSplits
+	}
+	err := json.Unmarshal([]byte(trafficSplitsStr), &trafficSplits)
+	if err != nil {
+		utils.DebugPrintln("GetTrafficSplits Unmarshal", err)
+	}
+	return trafficSplits
+}
+
+// GetTrafficSplitsString convert traffic splits to JSON string
+func GetTrafficSplitsString(trafficSplits []*models.TrafficSplit) string {
+	if len(trafficSplits) == 0 {
+		return ""
+	}
+	trafficSplitsBytes, err := json.Marshal(trafficSplits)
+	if err != nil {
+		utils.DebugPrintln("GetTrafficSplitsString Marshal", err)
+		return ""
+	}
+	return string(trafficSplitsBytes)
+}
+
+// GetHeaderRules convert JSON string to header rules
+func GetHeaderRules(headerRulesStr string) []*models.HeaderRule {
+	headerRules := []*models.HeaderRule{}
+	if len(headerRulesStr) == 0 {
+		return headerRules
+	}
+	err := json.Unmarshal([]byte(headerRulesStr), &headerRules)
+	if err != nil {
+		utils.DebugPrintln("GetHeaderRules Unmarshal", err)
+	}
+	return headerRules
+}
+
+// GetHeaderRulesString convert header rules to JSON string
+func GetHeaderRulesString(headerRules []*models.HeaderRule) string {
+	if len(headerRules) == 0 {
+		return ""
+	}
+	headerRulesBytes, err := json.Marshal(headerRules)
+	if err != nil {
+		utils.DebugPrintln("GetHeaderRulesString Marshal", err)
+		return ""
+	}
+	return string(headerRulesBytes)
+}
+
 // LoadDestinations ...
 func LoadDestinations() {
 	for _, app := range Apps {
 		app.Destinations = data.DAL.SelectDestinationsByAppID(app.ID)
-		for _, dest := range app.Destinations {
-			routeI, ok := app.Route.Load(dest.RequestRoute)
-			var route []*models.Destination
-			if ok {
-				route = routeI.([]*models.Destination)
-			}
-			route = append(route, dest)
-			app.Route.Store(dest.RequestRoute, route)
-		}
+		BuildRouteTable(app)
+		InitAppTransports(app)
 	}
 }
 
 // LoadRoute ...
 func LoadRoute() {
 	for _, app := range Apps {
-		for _, dest := range app.Destinations {
-			routeI, ok := app.Route.Load(dest.RequestRoute)
-			var route []*models.Destination
-			if ok {
-				route = routeI.([]*models.Destination)
-			}
-			route = append(route, dest)
-			app.Route.Store(dest.RequestRoute, route)
-		}
+		BuildRouteTable(app)
+		InitAppTransports(app)
 	}
 }
 
@@ -286,7 +581,6 @@ func UpdateDestinations(app *models.Application, destinations []*models.Destinat
 	for _, dest := range app.Destinations {
 		// delete outdated destinations from DB
 		if !ContainsDestinationID(destinations, dest.ID) {
-			app.Route.Delete(dest.RequestRoute)
 			err := data.DAL.DeleteDestinationByID(dest.ID)
 			if err != nil {
 				utils.DebugPrintln("DeleteDestinationByID", err)
@@ -295,16 +589,20 @@ func UpdateDestinations(app *models.Application, destinations []*models.Destinat
 	}
 	var newDestinations = []*models.Destination{}
 	for _, destination := range destinations {
-		if strings.HasPrefix(destination.RequestRoute, "/") && !strings.HasSuffix(destination.RequestRoute, "/") {
+		if destination.RouteMatch == models.RouteMatch_PREFIX && strings.HasPrefix(destination.RequestRoute, "/") && !strings.HasSuffix(destination.RequestRoute, "/") {
 			destination.RequestRoute = strings.Trim(destination.RequestRoute, " ") + "/"
 		}
-		if strings.HasPrefix(destination.BackendRoute, "/") && !strings.HasSuffix(destination.BackendRoute, "/") {
+		if destination.RouteMatch == models.RouteMatch_PREFIX && strings.HasPrefix(destination.BackendRoute, "/") && !strings.HasSuffix(destination.BackendRoute, "/") {
 			destination.BackendRoute = strings.Trim(destination.BackendRoute, " ") + "/"
 		}
+		if destination.Weight <= 0 {
+			destination.Weight = 1
+		}
+		healthCheck := data.MarshalHealthCheck(destination.HealthCheck)
 		var err error
 		if destination.ID == 0 {
 			// new
-			destination.ID, err = data.DAL.InsertDestination(int64(destination.RouteType), destination.RequestRoute, destination.BackendRoute, destination.Destination, destination.PodsAPI, destination.PodPort, app.ID, destination.NodeID)
+			destination.ID, err = data.DAL.InsertDestination(int64(destination.RouteType), destination.RequestRoute, destination.BackendRoute, destination.Destination, destination.PodsAPI, destination.PodPort, app.ID, destination.NodeID, int64(destination.LBMethod), destination.Weight, destination.HashKey, healthCheck, destination.Group, destination.MirrorTarget, destination.MirrorPercent, int64(destination.RouteMatch), destination.Methods, destination.HeaderMatch, destination.Priority, destination.ProxyProtocol)
 			if err != nil {
 				utils.DebugPrintln("InsertDestination", err)
 			} else {
@@ -313,7 +611,7 @@ func UpdateDestinations(app *models.Application, destinations []*models.Destinat
 			}
 		} else {
 			// update
-			err = data.DAL.UpdateDestinationNode(int64(destination.RouteType), destination.RequestRoute, destination.BackendRoute, destination.Destination, destination.PodsAPI, destination.PodPort, app.ID, destination.NodeID, destination.ID)
+			err = data.DAL.UpdateDestinationNode(int64(destination.RouteType), destination.RequestRoute, destination.BackendRoute, destination.Destination, destination.PodsAPI, destination.PodPort, app.ID, destination.NodeID, int64(destination.LBMethod), destination.Weight, destination.HashKey, healthCheck, destination.Group, destination.MirrorTarget, destination.MirrorPercent, int64(destination.RouteMatch), destination.Methods, destination.HeaderMatch, destination.Priority, destination.ProxyProtocol, destination.ID)
 			if err != nil {
 				utils.DebugPrintln("UpdateDestinationNode", err)
 			} else {
@@ -324,20 +622,13 @@ func UpdateDestinations(app *models.Application, destinations []*models.Destinat
 	}
 	app.Destinations = newDestinations
 
-	// Update Route Map
-	app.Route.Range(func(key, value interface{}) bool {
-		app.Route.Delete(key)
-		return true
-	})
-	for _, dest := range app.Destinations {
-		routeI, ok := app.Route.Load(dest.RequestRoute)
-		var route []*models.Destination
-		if ok {
-			route = routeI.([]*models.Destination)
-		}
-		route = append(route, dest)
-		app.Route.Store(dest.RequestRoute, route)
-	}
+	// Update Route Table
+	BuildRouteTable(app)
+
+	// Recreate transports and hash rings for new destinations
+	ResetAppTransports(app.ID)
+	InitAppTransports(app)
+	ResetHashRings()
 }
 
 // UpdateAppDomains ...
@@ -378,17 +669,33 @@ func UpdateApplication(body []byte, clientIP string, authUser *models.AuthUser)
 		return nil, err
 	}
 	app := rpcAppRequest.Object
+	if err := CheckRouteLBMethods(app.Destinations); err != nil {
+		return nil, err
+	}
+	if err := CheckTrafficSplits(app.TrafficSplits, app.Destinations); err != nil {
+		return nil, err
+	}
 	// backup app0 to update destinations and domains
 	var app0 *models.Application
 	customHeaders := GetCustomHeadersString(app.CustomHeaders)
+	retryPolicy := GetRetryPolicyString(app.RetryPolicy)
+	trafficSplits := GetTrafficSplitsString(app.TrafficSplits)
+	headerRules := GetHeaderRulesString(app.HeaderRules)
+	requestLimits := GetRequestLimitsString(app.RequestLimits)
+	errorPages := GetErrorPagesString(app.ErrorPages)
+	maintenance := GetMaintenanceString(app.Maintenance)
+	compression := GetCompressionString(app.Compression)
+	cachePolicy := GetCachePolicyString(app.CachePolicy)
+	upstreamTLS := GetUpstreamTLSString(app.UpstreamTLS)
+	clientAuth := GetClientAuthString(app.ClientAuth)
 	if app.ID == 0 {
 		// new application
-		app.ID = data.DAL.InsertApplication(app.Name, app.InternalScheme, app.RedirectHTTPS, app.HSTSEnabled, app.WAFEnabled, app.ShieldEnabled, app.ClientIPMethod, app.Description, app.OAuthRequired, app.SessionSeconds, app.Owner, app.CSPEnabled, app.CSP, app.CacheEnabled, customHeaders, app.CookieMgmtEnabled, app.ConciseNotice, app.NecessaryNotice, app.FunctionalNotice, app.EnableFunctional, app.AnalyticsNotice, app.EnableAnalytics, app.MarketingNotice, app.EnableMarketing, app.UnclassifiedNotice, app.EnableUnclassified)
+		app.ID = data.DAL.InsertApplication(app.Name, app.InternalScheme, app.RedirectHTTPS, app.HSTSEnabled, app.WAFEnabled, app.ShieldEnabled, app.ClientIPMethod, app.Description, app.OAuthRequired, app.SessionSeconds, app.Owner, app.CSPEnabled, app.CSP, app.CacheEnabled, customHeaders, app.CookieMgmtEnabled, app.ConciseNotice, app.NecessaryNotice, app.FunctionalNotice, app.EnableFunctional, app.AnalyticsNotice, app.EnableAnalytics, app.MarketingNotice, app.EnableMarketing, app.UnclassifiedNotice, app.EnableUnclassified, retryPolicy, trafficSplits, headerRules, app.ForwardedPolicy, app.TrustedProxies, requestLimits, errorPages, maintenance, compression, cachePolicy, upstreamTLS, clientAuth)
 		Apps = append(Apps, app)
 		app0 = app
 		go utils.OperationLog(clientIP, authUser.Username, "Add Application", app.Name)
 	} else {
-		err := data.DAL.UpdateApplication(app.Name, app.InternalScheme, app.RedirectHTTPS, app.HSTSEnabled, app.WAFEnabled, app.ShieldEnabled, app.ClientIPMethod, app.Description, app.OAuthRequired, app.SessionSeconds, app.Owner, app.CSPEnabled, app.CSP, app.CacheEnabled, customHeaders, app.CookieMgmtEnabled, app.ConciseNotice, app.NecessaryNotice, app.FunctionalNotice, app.EnableFunctional, app.AnalyticsNotice, app.EnableAnalytics, app.MarketingNotice, app.EnableMarketing, app.UnclassifiedNotice, app.EnableUnclassified, app.ID)
+		err := data.DAL.UpdateApplication(app.Name, app.InternalScheme, app.RedirectHTTPS, app.HSTSEnabled, app.WAFEnabled, app.ShieldEnabled, app.ClientIPMethod, app.Description, app.OAuthRequired, app.SessionSeconds, app.Owner, app.CSPEnabled, app.CSP, app.CacheEnabled, customHeaders, app.CookieMgmtEnabled, app.ConciseNotice, app.NecessaryNotice, app.FunctionalNotice, app.EnableFunctional, app.AnalyticsNotice, app.EnableAnalytics, app.MarketingNotice, app.EnableMarketing, app.UnclassifiedNotice, app.EnableUnclassified, retryPolicy, trafficSplits, headerRules, app.ForwardedPolicy, app.TrustedProxies, requestLimits, errorPages, maintenance, compression, cachePolicy, upstreamTLS, clientAuth, app.ID)
 		if err != nil {
 			utils.DebugPrintln("UpdateApplication", err)
 		}
@@ -423,6 +730,18 @@ func UpdateApplication(body []byte, clientIP string, authUser *models.AuthUser)
 		app0.EnableUnclassified = app.EnableUnclassified
 
 		app0.CustomHeaders = GetCustomHeaders(customHeaders)
+		app0.RetryPolicy = app.RetryPolicy
+		app0.TrafficSplits = app.TrafficSplits
+		app0.HeaderRules = app.HeaderRules
+		app0.ForwardedPolicy = app.ForwardedPolicy
+		app0.TrustedProxies = app.TrustedProxies
+		app0.RequestLimits = app.RequestLimits
+		app0.ErrorPages = app.ErrorPages
+		app0.Maintenance = app.Maintenance
+		app0.Compression = app.Compression
+		app0.CachePolicy = app.CachePolicy
+		app0.UpstreamTLS = app.UpstreamTLS
+		app0.ClientAuth = app.ClientAuth
 		go utils.OperationLog(clientIP, authUser.Username, "Update Application", app.Name)
 	}
 	UpdateDestinations(app0, app.Destinations)
@@ -457,7 +776,9 @@ func DeleteApplicationByID(appID int64, clientIP string, authUser *models.AuthUs
 	}
 	DeleteDomainsByApp(app)
 	DeleteDestinationsByApp(appID)
+	ResetAppTransports(appID)
 	DeleteCookiesByApp(app)
+	DeleteRewriteRulesByApp(app)
 	err = firewall.DeleteCCPolicyByAppID(appID, clientIP, authUser, false)
 	if err != nil {
 		utils.DebugPrintln("DeleteApplicationByID DeleteCCPolicyByAppID", err)
@@ -9,10 +9,12 @@ package backend
 
 import (
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"errors"
 	"log"
 	"strconv"
+	"strings"
 
 	"janusec/data"
 	"janusec/models"
@@ -44,13 +46,16 @@ func LoadCerts() {
 			if err != nil {
 				utils.DebugPrintln("LoadCerts AES256Decrypt", err)
 			}
-			tlsCert, err := tls.X509KeyPair(pubCert, privKey)
-			if err != nil {
-				utils.DebugPrintln("LoadCerts X509KeyPair", err)
-			}
 			cert.PrivKeyContent = string(privKey)
-			cert.TlsCert = tlsCert
+			if !IsCACertItem(cert) {
+				tlsCert, err := tls.X509KeyPair(pubCert, privKey)
+				if err != nil {
+					utils.DebugPrintln("LoadCerts X509KeyPair", err)
+				}
+				cert.TlsCert = tlsCert
+			}
 			cert.ExpireTime = dbCert.ExpireTime
+			cert.ACMEDomains = parseACMEDomains(dbCert.ACMEDomains)
 			if dbCert.Description.Valid {
 				cert.Description = dbCert.Description.String
 			} else {
@@ -72,11 +77,19 @@ func LoadCerts() {
 func GetCertificateByDomain(helloInfo *tls.ClientHelloInfo) (*tls.Certificate, error) {
 	domain := helloInfo.ServerName
 	if domainRelation, ok := DomainsMap.Load(domain); ok {
-		certItem := domainRelation.(models.DomainRelation).Cert
+		relation := domainRelation.(models.DomainRelation)
+		certItem := relation.Cert
+		if len(relation.Certs) > 1 {
+			// v1.5.3 ECDSA and RSA certificates
+			return selectCertificate(helloInfo, relation.Certs), nil
+		}
 		if certItem == nil {
 			// autocert
 			return AcmeCertManager.GetCertificate(helloInfo)
 		}
+		if IsCACertItem(certItem) {
+			return nil, errors.New("CA certificate can not be used by " + domain)
+		}
 		return &(certItem.TlsCert), nil
 	}
 	return nil, errors.New("Unknown Host: " + domain)
@@ -84,21 +97,17 @@ func GetCertificateByDomain(helloInfo *tls.ClientHelloInfo) (*tls.Certificate, e
 
 // GetCertificates ...
 func GetCertificates(authUser *models.AuthUser) ([]*models.CertItem, error) {
-	if authUser.IsCertAdmin {
-		return Certs, nil
-	}
-	// Remove private key
 	var simpleCerts = []*models.CertItem{}
 	for _, cert := range Certs {
-		simpleCert := &models.CertItem{
-			ID:             cert.ID,
-			CommonName:     cert.CommonName,
-			CertContent:    "",
-			PrivKeyContent: "You have no privilege to view the private key.",
-			ExpireTime:     cert.ExpireTime,
-			Description:    cert.Description,
-		}
-		simpleCerts = append(simpleCerts, simpleCert)
+		// v1.5.3 domains using the certificate
+		cert = withCertDomains(cert)
+		if !authUser.IsCertAdmin {
+			// Remove private key
+			cert.CertContent = ""
+			cert.PrivKeyContent = "You have no privilege to view the private key."
+			cert.TlsCert = tls.Certificate{}
+		}
+		simpleCerts = append(simpleCerts, cert)
 	}
 	return simpleCerts, nil
 }
@@ -117,18 +126,12 @@ func SysCallGetCertByID(certID int64) (*models.CertItem, error) {
 func GetCertificateByID(certID int64, authUser *models.AuthUser) (*models.CertItem, error) {
 	for _, cert := range Certs {
 		if cert.ID == certID {
-			if authUser.IsCertAdmin {
-				return cert, nil
-			}
-			simpleCert := &models.CertItem{
-				ID:             cert.ID,
-				CommonName:     cert.CommonName,
-				CertContent:    cert.CertContent,
-				PrivKeyContent: "You have no privilege to view the private key.",
-				ExpireTime:     cert.ExpireTime,
-				Description:    cert.Description,
+			cert = withCertDomains(cert)
+			if !authUser.IsCertAdmin {
+				cert.PrivKeyContent = "You have no privilege to view the private key."
+				cert.TlsCert = tls.Certificate{}
 			}
-			return simpleCert, nil
+			return cert, nil
 		}
 	}
 	return nil, errors.New("certificate id error")
@@ -164,27 +167,35 @@ func UpdateCertificate(body []byte, clientIP string, authUser *models.AuthUser)
 	certItem := rpcCertRequest.Object
 	encryptedPrivKey := data.AES256Encrypt([]byte(certItem.PrivKeyContent), false)
 	expireTime := data.GetCertificateExpiryTime(certItem.CertContent)
-	tlsCert, err := tls.X509KeyPair([]byte(certItem.CertContent), []byte(certItem.PrivKeyContent))
-	if err != nil {
-		utils.DebugPrintln("UpdateCertificate X509KeyPair", err)
-		return nil, err
+	if IsCACertItem(certItem) {
+		// v1.5.3 CA certificate for upstream TLS verification
+		if !x509.NewCertPool().AppendCertsFromPEM([]byte(certItem.CertContent)) {
+			return nil, errors.New("invalid CA certificate")
+		}
+	} else {
+		tlsCert, err := tls.X509KeyPair([]byte(certItem.CertContent), []byte(certItem.PrivKeyContent))
+		if err != nil {
+			utils.DebugPrintln("UpdateCertificate X509KeyPair", err)
+			return nil, err
+		}
+		certItem.TlsCert = tlsCert
 	}
-	certItem.TlsCert = tlsCert
 	certItem.ExpireTime = expireTime
 	if certItem.ID == 0 {
 		//new certificate
-		newID := data.DAL.InsertCertificate(certItem.CommonName, certItem.CertContent, encryptedPrivKey, expireTime, certItem.Description)
+		newID := data.DAL.InsertCertificate(certItem.CommonName, certItem.CertContent, encryptedPrivKey, expireTime, certItem.Description, strings.Join(certItem.ACMEDomains, ","))
 		//certItem = &models.CertItem{}
 		certItem.ID = newID
 		Certs = append(Certs, certItem)
 		go utils.OperationLog(clientIP, authUser.Username, "Add Certificate", certItem.CommonName)
 	} else {
 		// update
-		err = data.DAL.UpdateCertificate(certItem.CommonName, certItem.CertContent, encryptedPrivKey, expireTime, certItem.Description, certItem.ID)
+		err := data.DAL.UpdateCertificate(certItem.CommonName, certItem.CertContent, encryptedPrivKey, expireTime, certItem.Description, strings.Join(certItem.ACMEDomains, ","), certItem.ID)
 		if err != nil {
 			return nil, err
 		}
 		UpdateCerts(certItem)
+		refreshCertRelations(certItem)
 		go utils.OperationLog(clientIP, authUser.Username, "Update Certificate", certItem.CommonName)
 	}
 	data.UpdateBackendLastModified()
@@ -197,6 +208,19 @@ func DeleteCertificateByID(certID int64, clientIP string, authUser *models.AuthU
 	if certDomainsCount > 0 {
 		return errors.New("this certificate is in use, please delete relevant applications at first")
 	}
+	for _, domain := range Domains {
+		if isCertUsedByDomain(domain, certID) {
+			return errors.New("this certificate is used by " + domain.Name)
+		}
+	}
+	for _, app := range Apps {
+		if app.UpstreamTLS != nil && (app.UpstreamTLS.CACertID == certID || app.UpstreamTLS.ClientCertID == certID) {
+			return errors.New("this certificate is used by upstream TLS of " + app.Name)
+		}
+		if app.ClientAuth != nil && app.ClientAuth.CACertID == certID {
+			return errors.New("this certificate is used by client authentication of " + app.Name)
+		}
+	}
 	err := data.DAL.DeleteCertificate(certID)
 	if err != nil {
 		return err
@@ -0,0 +1,28 @@
+/*
+ * @Copyright Reserved By Janusec (https://www.janusec.com/).
+ * @Author: U2
+ * @Date: 2026-10-18 22:48:26
+ * @Last Modified: U2, 2026-10-18 22:48:26
+ */
+
+package backend
+
+import (
+	"crypto/tls"
+
+	"janusec/models"
+)
+
+// applyClientAuth request client certificates issued by the CA certificate of the application
+func applyClientAuth(cfg *tls.Config, clientAuth *models.ClientAuthConfig) error {
+	clientCAs, err := getCACertPool(clientAuth.CACertID)
+	if err != nil {
+		return err
+	}
+	cfg.ClientCAs = clientCAs
+	cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	if clientAuth.Mode == models.ClientAuth_REQUIRED {
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return nil
+}
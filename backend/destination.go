@@ -38,6 +38,10 @@ func ContainsDestinationID(destinations []*models.Destination, destID int64) boo
 func CheckOfflineDestinations(nowTimeStamp int64) {
 	for _, app := range Apps {
 		for _, dest := range app.Destinations {
+			if IsHealthCheckEnabled(dest.HealthCheck) && dest.RouteType == models.ReverseProxyRoute {
+				// checked by RoutineHealthCheckTick
+				continue
+			}
 			dest.Mutex.Lock()
 			defer dest.Mutex.Unlock()
 			if dest.RouteType == models.ReverseProxyRoute && !dest.Online {
@@ -74,6 +78,7 @@ func CheckOfflineDestinations(nowTimeStamp int64) {
 						dest.Pods += podItem.Status.PodIP + ":" + dest.PodPort
 					}
 				}
+				EvictPodTransports(dest, dest.Pods)
 				dest.CheckTime = nowTimeStamp
 				dest.Online = true
 			}
@@ -93,6 +98,9 @@ func SetDestinationOffline(dest *models.Destination) {
 		nowCount := count.(int64) + int64(1)
 		if nowCount > 5 {
 			// more than 5 requests timeout
+			if dest.Online {
+				addHealthEvent(&dest.HealthState, false, "dial failed more than 5 times", time.Now().Unix())
+			}
 			dest.Online = false
 			app, err := GetApplicationByID(dest.AppID)
 			if err == nil {
@@ -41,16 +41,19 @@ func LoadDomains() {
 		pApp, _ := GetApplicationByID(dbDomain.AppID)
 		pCert, _ := SysCallGetCertByID(dbDomain.CertID)
 		domain := &models.Domain{
-			ID:       dbDomain.ID,
-			Name:     dbDomain.Name,
-			AppID:    dbDomain.AppID,
-			CertID:   dbDomain.CertID,
-			Redirect: dbDomain.Redirect,
-			Location: dbDomain.Location,
-			App:      pApp,
-			Cert:     pCert}
+			ID:           dbDomain.ID,
+			Name:         dbDomain.Name,
+			AppID:        dbDomain.AppID,
+			CertID:       dbDomain.CertID,
+			Redirect:     dbDomain.Redirect,
+			Location:     dbDomain.Location,
+			App:          pApp,
+			Cert:         pCert,
+			TLSProfile:   GetTLSProfile(dbDomain.TLSProfile),
+			ExtraCertIDs: dbDomain.ExtraCertIDs}
 		Domains = append(Domains, domain)
-		DomainsMap.Store(domain.Name, models.DomainRelation{App: pApp, Cert: pCert, Redirect: dbDomain.Redirect, Location: dbDomain.Location})
+		certs := getDomainCerts(domain)
+		DomainsMap.Store(domain.Name, models.DomainRelation{App: pApp, Cert: pCert, Certs: certs, Redirect: dbDomain.Redirect, Location: dbDomain.Location, TLSProfile: domain.TLSProfile})
 	}
 }
 
@@ -78,13 +81,15 @@ func GetDomainByName(domainName string) *models.Domain {
 func UpdateDomain(app *models.Application, newDomain *models.Domain) *models.Domain {
 	// First convert domain name to lowercase
 	newDomain.Name = strings.ToLower(strings.TrimSpace(newDomain.Name))
+	tlsProfile := GetTLSProfileString(newDomain.TLSProfile)
+	newDomain.ExtraCertIDs = normalizeCertIDs(newDomain.ExtraCertIDs, newDomain.CertID)
 	if newDomain.ID == 0 {
 		// New domain
-		newDomain.ID = data.DAL.InsertDomain(newDomain.Name, app.ID, newDomain.CertID, newDomain.Redirect, newDomain.Location)
+		newDomain.ID = data.DAL.InsertDomain(newDomain.Name, app.ID, newDomain.CertID, newDomain.Redirect, newDomain.Location, tlsProfile, newDomain.ExtraCertIDs)
 		Domains = append(Domains, newDomain)
 	} else {
 		oldDomain := GetDomainByID(newDomain.ID)
-		err := data.DAL.UpdateDomain(newDomain.Name, app.ID, newDomain.CertID, newDomain.Redirect, newDomain.Location, oldDomain.ID)
+		err := data.DAL.UpdateDomain(newDomain.Name, app.ID, newDomain.CertID, newDomain.Redirect, newDomain.Location, tlsProfile, newDomain.ExtraCertIDs, oldDomain.ID)
 		if err != nil {
 			utils.DebugPrintln("UpdateDomain", err)
 		}
@@ -94,7 +99,12 @@ func UpdateDomain(app *models.Application, newDomain *models.Domain) *models.Dom
 	newDomain.App = app
 	pCert, _ := SysCallGetCertByID(newDomain.CertID)
 	newDomain.Cert = pCert
-	DomainsMap.Store(newDomain.Name, models.DomainRelation{App: app, Cert: pCert, Redirect: newDomain.Redirect, Location: newDomain.Location})
+	certs := getDomainCerts(newDomain)
+	newDomain.CertWarning = getCertWarning(newDomain, certs)
+	if len(newDomain.CertWarning) > 0 {
+		utils.DebugPrintln("UpdateDomain", newDomain.CertWarning)
+	}
+	DomainsMap.Store(newDomain.Name, models.DomainRelation{App: app, Cert: pCert, Certs: certs, Redirect: newDomain.Redirect, Location: newDomain.Location, TLSProfile: newDomain.TLSProfile})
 	return newDomain
 }
 
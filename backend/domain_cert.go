@@ -0,0 +1,177 @@
+/*
+ * @Copyright Reserved By Janusec (https://www.janusec.com/).
+ * @Author: U2
+ * @Date: 2026-10-19 00:21:37
+ * @Last Modified: U2, 2026-10-19 00:21:37
+ */
+
+package backend
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/tls"
+	"crypto/x509"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"janusec/models"
+)
+
+// parseCertIDs convert comma separated IDs
+func parseCertIDs(certIDs string) []int64 {
+	ids := []int64{}
+	for _, idStr := range strings.Split(certIDs, ",") {
+		id, err := strconv.ParseInt(strings.TrimSpace(idStr), 10, 64)
+		if err == nil && id > 0 && !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// normalizeCertIDs remove invalid and duplicated IDs, and the main certID
+func normalizeCertIDs(certIDs string, certID int64) string {
+	ids := []string{}
+	for _, id := range parseCertIDs(certIDs) {
+		if id != certID {
+			ids = append(ids, strconv.FormatInt(id, 10))
+		}
+	}
+	return strings.Join(ids, ",")
+}
+
+// getDomainCerts return the certificates of the domain, ECDSA and Ed25519 first, CA certificates are excluded
+func getDomainCerts(domain *models.Domain) []*models.CertItem {
+	certs := []*models.CertItem{}
+	for _, certID := range append([]int64{domain.CertID}, parseCertIDs(domain.ExtraCertIDs)...) {
+		certItem, err := SysCallGetCertByID(certID)
+		if err != nil || IsCACertItem(certItem) || len(certItem.TlsCert.Certificate) == 0 {
+			continue
+		}
+		certs = append(certs, certItem)
+	}
+	sort.SliceStable(certs, func(i, j int) bool {
+		return getCertKeyRank(certs[i]) < getCertKeyRank(certs[j])
+	})
+	return certs
+}
+
+// getCertKeyRank ECDSA and Ed25519 0, RSA 1
+func getCertKeyRank(certItem *models.CertItem) int {
+	switch certItem.TlsCert.PrivateKey.(type) {
+	case *ecdsa.PrivateKey, ed25519.PrivateKey:
+		return 0
+	}
+	return 1
+}
+
+// selectCertificate return the first certificate supported by the signature schemes, curves and
+// cipher suites of the ClientHello, RSA for old clients
+func selectCertificate(helloInfo *tls.ClientHelloInfo, certs []*models.CertItem) *tls.Certificate {
+	for _, certItem := range certs {
+		if helloInfo.SupportsCertificate(&certItem.TlsCert) == nil {
+			return &certItem.TlsCert
+		}
+	}
+	// RSA is sorted at last
+	return &certs[len(certs)-1].TlsCert
+}
+
+// getCertLeaf return the parsed leaf certificate
+func getCertLeaf(certItem *models.CertItem) *x509.Certificate {
+	if certItem.TlsCert.Leaf != nil {
+		return certItem.TlsCert.Leaf
+	}
+	if len(certItem.TlsCert.Certificate) == 0 {
+		return nil
+	}
+	leaf, err := x509.ParseCertificate(certItem.TlsCert.Certificate[0])
+	if err != nil {
+		return nil
+	}
+	return leaf
+}
+
+// isCertMatched check whether the domain name is covered by the SAN of certificate
+func isCertMatched(certItem *models.CertItem, domainName string) bool {
+	leaf := getCertLeaf(certItem)
+	if leaf == nil {
+		return false
+	}
+	if strings.HasPrefix(domainName, "*.") {
+		// wildcard domain must be covered by the wildcard certificate
+		domainName = "janusec-wildcard" + domainName[1:]
+	}
+	return leaf.VerifyHostname(domainName) == nil
+}
+
+// getCertWarning return the warning if the certificates do not match the domain
+func getCertWarning(domain *models.Domain, certs []*models.CertItem) string {
+	mismatched := []string{}
+	for _, certItem := range certs {
+		if !isCertMatched(certItem, domain.Name) {
+			mismatched = append(mismatched, certItem.CommonName)
+		}
+	}
+	if len(mismatched) == 0 {
+		return ""
+	}
+	return "certificate " + strings.Join(mismatched, ", ") + " does not match " + domain.Name
+}
+
+// isCertUsedByDomain check whether the certificate is the main or extra certificate of the domain
+func isCertUsedByDomain(domain *models.Domain, certID int64) bool {
+	return domain.CertID == certID || slices.Contains(parseCertIDs(domain.ExtraCertIDs), certID)
+}
+
+// withCertDomains return the copy of certificate with the domains using it
+func withCertDomains(certItem *models.CertItem) *models.CertItem {
+	cert := *certItem
+	cert.Domains = []string{}
+	cert.MismatchedDomains = []string{}
+	for _, domain := range Domains {
+		if !isCertUsedByDomain(domain, certItem.ID) {
+			continue
+		}
+		cert.Domains = append(cert.Domains, domain.Name)
+		if !IsCACertItem(certItem) && !isCertMatched(certItem, domain.Name) {
+			cert.MismatchedDomains = append(cert.MismatchedDomains, domain.Name)
+		}
+	}
+	return &cert
+}
+
+// refreshCertRelations replace the updated certificate in domains and DomainsMap
+func refreshCertRelations(certItem *models.CertItem) {
+	for _, domain := range Domains {
+		if domain.CertID == certItem.ID {
+			domain.Cert = certItem
+		}
+	}
+	DomainsMap.Range(func(key, value any) bool {
+		relation := value.(models.DomainRelation)
+		updated := false
+		if relation.Cert != nil && relation.Cert.ID == certItem.ID {
+			relation.Cert = certItem
+			updated = true
+		}
+		certs := slices.Clone(relation.Certs)
+		for i, cert := range certs {
+			if cert.ID == certItem.ID {
+				certs[i] = certItem
+				updated = true
+			}
+		}
+		if updated {
+			sort.SliceStable(certs, func(i, j int) bool {
+				return getCertKeyRank(certs[i]) < getCertKeyRank(certs[j])
+			})
+			relation.Certs = certs
+			DomainsMap.Store(key, relation)
+		}
+		return true
+	})
+}
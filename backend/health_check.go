@@ -0,0 +1,273 @@
+/*
+ * @Copyright Reserved By Janusec (https://www.janusec.com/).
+ * @Author: U2
+ * @Date: 2026-10-18 14:20:41
+ * @Last Modified: U2, 2026-10-18 14:20:41
+ */
+
+package backend
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"janusec/models"
+	"janusec/utils"
+)
+
+// maxHealthEvents is the number of recent status transitions kept in memory
+const maxHealthEvents = 20
+
+var healthCheckTransport = &http.Transport{
+	TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+	DisableKeepAlives: true,
+}
+
+// RoutineHealthCheckTick run active health checks for destinations and VIP targets
+func RoutineHealthCheckTick() {
+	routineTicker := time.NewTicker(time.Second)
+	for range routineTicker.C {
+		nowTimeStamp := time.Now().Unix()
+		for _, app := range Apps {
+			for _, dest := range app.Destinations {
+				hc := dest.HealthCheck
+				if hc == nil || !hc.Enabled || dest.RouteType != models.ReverseProxyRoute {
+					continue
+				}
+				dest.Mutex.Lock()
+				if dest.IsChecking || nowTimeStamp-dest.LastCheck < getInterval(hc) {
+					dest.Mutex.Unlock()
+					continue
+				}
+				dest.IsChecking = true
+				dest.LastCheck = nowTimeStamp
+				dest.Mutex.Unlock()
+				go checkDestinationHealth(app, dest, nowTimeStamp)
+			}
+		}
+		for _, vipApp := range VipApps {
+			for _, target := range vipApp.Targets {
+				hc := target.HealthCheck
+				if hc == nil || !hc.Enabled || target.RouteType == models.K8S_Ingress || !vipApp.IsTCP {
+					continue
+				}
+				target.Mutex.Lock()
+				if target.IsChecking || nowTimeStamp-target.LastCheck < getInterval(hc) {
+					target.Mutex.Unlock()
+					continue
+				}
+				target.IsChecking = true
+				target.LastCheck = nowTimeStamp
+				target.Mutex.Unlock()
+				go checkVipTargetHealth(vipApp, target, nowTimeStamp)
+			}
+		}
+	}
+}
+
+// IsHealthCheckEnabled used to skip the TCP dial check of offline destinations
+func IsHealthCheckEnabled(hc *models.HealthCheck) bool {
+	return hc != nil && hc.Enabled
+}
+
+func checkDestinationHealth(app *models.Application, dest *models.Destination, nowTimeStamp int64) {
+	err := ProbeHealth(dest.HealthCheck, dest.Destination, dest.ProxyProtocol)
+	dest.Mutex.Lock()
+	defer dest.Mutex.Unlock()
+	dest.IsChecking = false
+	dest.CheckTime = nowTimeStamp
+	online, changed := applyHealthResult(dest.HealthCheck, &dest.HealthState, dest.Online, err, nowTimeStamp)
+	if changed {
+		dest.Online = online
+		utils.DebugPrintln("Health check", app.Name, dest.Destination, "online:", online, dest.CheckResult)
+		if !online {
+			go sendOfflineNotification(app, dest.Destination)
+		}
+	}
+}
+
+func checkVipTargetHealth(vipApp *models.VipApp, target *models.VipTarget, nowTimeStamp int64) {
+	err := ProbeHealth(target.HealthCheck, target.Destination, target.ProxyProtocol)
+	target.Mutex.Lock()
+	defer target.Mutex.Unlock()
+	target.IsChecking = false
+	target.CheckTime = nowTimeStamp
+	online, changed := applyHealthResult(target.HealthCheck, &target.HealthState, target.Online, err, nowTimeStamp)
+	if changed {
+		target.Online = online
+		utils.DebugPrintln("Health check", vipApp.Name, target.Destination, "online:", online, target.CheckResult)
+		if !online {
+			go sendVIPOfflineNotification(vipApp, target.Destination)
+		}
+	}
+}
+
+// applyHealthResult count consecutive results and return the new online status
+func applyHealthResult(hc *models.HealthCheck, state *models.HealthState, online bool, err error, nowTimeStamp int64) (newOnline bool, changed bool) {
+	if err == nil {
+		state.Successes++
+		state.Failures = 0
+		state.CheckResult = "OK"
+		if !online && state.Successes >= getThreshold(hc.Rise, 2) {
+			addHealthEvent(state, true, "OK", nowTimeStamp)
+			return true, true
+		}
+	} else {
+		state.Failures++
+		state.Successes = 0
+		state.CheckResult = err.Error()
+		if online && state.Failures >= getThreshold(hc.Fall, 3) {
+			addHealthEvent(state, false, err.Error(), nowTimeStamp)
+			return false, true
+		}
+	}
+	return online, false
+}
+
+func addHealthEvent(state *models.HealthState, online bool, reason string, nowTimeStamp int64) {
+	event := &models.HealthEvent{
+		Time:   nowTimeStamp,
+		Online: online,
+		Reason: reason,
+	}
+	state.HealthEvents = append(state.HealthEvents, event)
+	if len(state.HealthEvents) > maxHealthEvents {
+		state.HealthEvents = state.HealthEvents[len(state.HealthEvents)-maxHealthEvents:]
+	}
+}
+
+func getInterval(hc *models.HealthCheck) int64 {
+	if hc.Interval <= 0 {
+		return 10
+	}
+	return hc.Interval
+}
+
+func getThreshold(value int64, defaultValue int64) int64 {
+	if value <= 0 {
+		return defaultValue
+	}
+	return value
+}
+
+// ProbeHealth send one health check to target (IP:Port), return nil if healthy
+// If the target requires PROXY protocol, a header without client address is sent first
+func ProbeHealth(hc *models.HealthCheck, target string, proxyProtocol int64) error {
+	timeout := time.Duration(getThreshold(hc.Timeout, 3)) * time.Second
+	scheme := strings.ToLower(hc.Scheme)
+	if scheme == "tcp" {
+		conn, err := dialHealthCheck(context.Background(), target, timeout, proxyProtocol)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+	if scheme != "https" {
+		scheme = "http"
+	}
+	method := strings.ToUpper(hc.Method)
+	if len(method) == 0 {
+		method = http.MethodGet
+	}
+	path := hc.Path
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	request, err := http.NewRequest(method, scheme+"://"+target+path, nil)
+	if err != nil {
+		return err
+	}
+	if len(hc.Host) > 0 {
+		request.Host = hc.Host
+	}
+	request.Header.Set("User-Agent", "Janusec-Health-Check")
+	transport := healthCheckTransport
+	if proxyProtocol != utils.ProxyProtocol_NONE {
+		transport = &http.Transport{
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+			DisableKeepAlives: true,
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return dialHealthCheck(ctx, target, timeout, proxyProtocol)
+			},
+		}
+	}
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if !IsExpectedStatus(hc.ExpectedStatus, resp.StatusCode) {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	if len(hc.BodyRegex) > 0 {
+		regex, err := regexp.Compile(hc.BodyRegex)
+		if err != nil {
+			return err
+		}
+		body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		if err != nil {
+			return err
+		}
+		if !regex.Match(body) {
+			return errors.New("body not match " + hc.BodyRegex)
+		}
+	}
+	return nil
+}
+
+func dialHealthCheck(ctx context.Context, target string, timeout time.Duration, proxyProtocol int64) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", target)
+	if err != nil {
+		return nil, err
+	}
+	// LOCAL command (v2) or UNKNOWN (v1), as load balancers do for health checks
+	err = utils.WriteProxyHeader(conn, proxyProtocol, nil, nil)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// IsExpectedStatus expectedStatus example: 200-399,404 , empty means 200-399
+func IsExpectedStatus(expectedStatus string, statusCode int) bool {
+	if len(strings.TrimSpace(expectedStatus)) == 0 {
+		return statusCode >= 200 && statusCode < 400
+	}
+	for _, item := range strings.Split(expectedStatus, ",") {
+		item = strings.TrimSpace(item)
+		bounds := strings.SplitN(item, "-", 2)
+		low, err := strconv.Atoi(strings.TrimSpace(bounds[0]))
+		if err != nil {
+			continue
+		}
+		high := low
+		if len(bounds) == 2 {
+			high, err = strconv.Atoi(strings.TrimSpace(bounds[1]))
+			if err != nil {
+				continue
+			}
+		}
+		if statusCode >= low && statusCode <= high {
+			return true
+		}
+	}
+	return false
+}
@@ -202,6 +202,12 @@ func InitDatabase() {
 	}
 	InitCookieRefs()
 
+	// v1.5.3 Rewrite Rules
+	err = dal.CreateTableIfNotExistsRewriteRules()
+	if err != nil {
+		utils.DebugPrintln("InitDatabase rewrite_rules", err)
+	}
+
 	// v1.4.1 DNS
 	err = dal.CreateTableIfNotExistsDNSDomains()
 	if err != nil {
@@ -238,12 +244,169 @@ func InitDatabase() {
 			utils.DebugPrintln("InitDatabase ALTER TABLE ip_policies add COLUMN", err)
 		}
 	}
+
+	if !dal.ExistColumnInTable("destinations", "lb_method") {
+		// v1.5.3 load balancing method of routes
+		err = dal.ExecSQL(`ALTER TABLE "destinations" ADD COLUMN "lb_method" bigint default 0, ADD COLUMN "weight" bigint default 1, ADD COLUMN "hash_key" VARCHAR(128) DEFAULT ''`)
+		if err != nil {
+			utils.DebugPrintln("InitDatabase ALTER TABLE destinations add lb_method", err)
+		}
+	}
+	if !dal.ExistColumnInTable("destinations", "health_check") {
+		// v1.5.3 active health check
+		err = dal.ExecSQL(`ALTER TABLE "destinations" ADD COLUMN "health_check" VARCHAR(1024) DEFAULT ''`)
+		if err != nil {
+			utils.DebugPrintln("InitDatabase ALTER TABLE destinations add health_check", err)
+		}
+	}
+	if !dal.ExistColumnInTable("vip_targets", "health_check") {
+		// v1.5.3 active health check
+		err = dal.ExecSQL(`ALTER TABLE "vip_targets" ADD COLUMN "health_check" VARCHAR(1024) DEFAULT ''`)
+		if err != nil {
+			utils.DebugPrintln("InitDatabase ALTER TABLE vip_targets add health_check", err)
+		}
+	}
+	if !dal.ExistColumnInTable("applications", "retry_policy") {
+		// v1.5.3 retry and outlier detection
+		err = dal.ExecSQL(`ALTER TABLE "applications" ADD COLUMN "retry_policy" VARCHAR(512) DEFAULT ''`)
+		if err != nil {
+			utils.DebugPrintln("InitDatabase ALTER TABLE applications add retry_policy", err)
+		}
+	}
+	if !dal.ExistColumnInTable("applications", "traffic_splits") {
+		// v1.5.3 traffic split between destination groups
+		err = dal.ExecSQL(`ALTER TABLE "applications" ADD COLUMN "traffic_splits" VARCHAR(4096) DEFAULT ''`)
+		if err != nil {
+			utils.DebugPrintln("InitDatabase ALTER TABLE applications add traffic_splits", err)
+		}
+	}
+	if !dal.ExistColumnInTable("destinations", "dest_group") {
+		// v1.5.3 destination group for traffic split
+		err = dal.ExecSQL(`ALTER TABLE "destinations" ADD COLUMN "dest_group" VARCHAR(128) DEFAULT ''`)
+		if err != nil {
+			utils.DebugPrintln("InitDatabase ALTER TABLE destinations add dest_group", err)
+		}
+	}
+	if !dal.ExistColumnInTable("destinations", "mirror_target") {
+		// v1.5.3 traffic mirroring
+		err = dal.ExecSQL(`ALTER TABLE "destinations" ADD COLUMN "mirror_target" VARCHAR(128) DEFAULT '', ADD COLUMN "mirror_percent" bigint DEFAULT 0`)
+		if err != nil {
+			utils.DebugPrintln("InitDatabase ALTER TABLE destinations add mirror_target", err)
+		}
+	}
+	if !dal.ExistColumnInTable("destinations", "route_match") {
+		// v1.5.3 route table with exact, regex, method and header match
+		err = dal.ExecSQL(`ALTER TABLE "destinations" ADD COLUMN "route_match" bigint DEFAULT 0, ADD COLUMN "methods" VARCHAR(128) DEFAULT '', ADD COLUMN "header_match" VARCHAR(256) DEFAULT '', ADD COLUMN "priority" bigint DEFAULT 0`)
+		if err != nil {
+			utils.DebugPrintln("InitDatabase ALTER TABLE destinations add route_match", err)
+		}
+	}
+	if !dal.ExistColumnInTable("applications", "header_rules") {
+		// v1.5.3 request and response header rules
+		err = dal.ExecSQL(`ALTER TABLE "applications" ADD COLUMN "header_rules" VARCHAR(4096) DEFAULT ''`)
+		if err != nil {
+			utils.DebugPrintln("InitDatabase ALTER TABLE applications add header_rules", err)
+		}
+	}
+	if !dal.ExistColumnInTable("applications", "forwarded_policy") {
+		// v1.5.3 forwarding headers and trusted proxies
+		err = dal.ExecSQL(`ALTER TABLE "applications" ADD COLUMN "forwarded_policy" bigint DEFAULT 0, ADD COLUMN "trusted_proxies" VARCHAR(1024) DEFAULT ''`)
+		if err != nil {
+			utils.DebugPrintln("InitDatabase ALTER TABLE applications add forwarded_policy", err)
+		}
+	}
+	if !dal.ExistColumnInTable("destinations", "proxy_protocol") {
+		// v1.5.3 PROXY protocol towards backends
+		err = dal.ExecSQL(`ALTER TABLE "destinations" ADD COLUMN "proxy_protocol" bigint DEFAULT 0`)
+		if err != nil {
+			utils.DebugPrintln("InitDatabase ALTER TABLE destinations add proxy_protocol", err)
+		}
+	}
+	if !dal.ExistColumnInTable("vip_targets", "proxy_protocol") {
+		err = dal.ExecSQL(`ALTER TABLE "vip_targets" ADD COLUMN "proxy_protocol" bigint DEFAULT 0`)
+		if err != nil {
+			utils.DebugPrintln("InitDatabase ALTER TABLE vip_targets add proxy_protocol", err)
+		}
+	}
+	if !dal.ExistColumnInTable("applications", "request_limits") {
+		// v1.5.3 body size limits and timeouts
+		err = dal.ExecSQL(`ALTER TABLE "applications" ADD COLUMN "request_limits" VARCHAR(512) DEFAULT ''`)
+		if err != nil {
+			utils.DebugPrintln("InitDatabase ALTER TABLE applications add request_limits", err)
+		}
+	}
+	if !dal.ExistColumnInTable("applications", "error_pages") {
+		// v1.5.3 custom error pages
+		err = dal.ExecSQL(`ALTER TABLE "applications" ADD COLUMN "error_pages" VARCHAR(16384) DEFAULT ''`)
+		if err != nil {
+			utils.DebugPrintln("InitDatabase ALTER TABLE applications add error_pages", err)
+		}
+	}
+	if !dal.ExistColumnInTable("applications", "maintenance") {
+		// v1.5.3 maintenance mode
+		err = dal.ExecSQL(`ALTER TABLE "applications" ADD COLUMN "maintenance" VARCHAR(4096) DEFAULT ''`)
+		if err != nil {
+			utils.DebugPrintln("InitDatabase ALTER TABLE applications add maintenance", err)
+		}
+	}
+	if !dal.ExistColumnInTable("applications", "compression") {
+		// v1.5.3 response compression
+		err = dal.ExecSQL(`ALTER TABLE "applications" ADD COLUMN "compression" VARCHAR(1024) DEFAULT ''`)
+		if err != nil {
+			utils.DebugPrintln("InitDatabase ALTER TABLE applications add compression", err)
+		}
+	}
+	if !dal.ExistColumnInTable("applications", "cache_policy") {
+		// v1.5.3 HTTP cache rules
+		err = dal.ExecSQL(`ALTER TABLE "applications" ADD COLUMN "cache_policy" VARCHAR(4096) DEFAULT ''`)
+		if err != nil {
+			utils.DebugPrintln("InitDatabase ALTER TABLE applications add cache_policy", err)
+		}
+	}
+	if !dal.ExistColumnInTable("applications", "upstream_tls") {
+		// v1.5.3 upstream TLS verification and mutual TLS
+		err = dal.ExecSQL(`ALTER TABLE "applications" ADD COLUMN "upstream_tls" VARCHAR(1024) DEFAULT ''`)
+		if err != nil {
+			utils.DebugPrintln("InitDatabase ALTER TABLE applications add upstream_tls", err)
+		}
+	}
+	if !dal.ExistColumnInTable("applications", "client_auth") {
+		// v1.5.3 client certificate authentication
+		err = dal.ExecSQL(`ALTER TABLE "applications" ADD COLUMN "client_auth" VARCHAR(512) DEFAULT ''`)
+		if err != nil {
+			utils.DebugPrintln("InitDatabase ALTER TABLE applications add client_auth", err)
+		}
+	}
+	if !dal.ExistColumnInTable("domains", "tls_profile") {
+		// v1.5.3 TLS profile of domains
+		err = dal.ExecSQL(`ALTER TABLE "domains" ADD COLUMN "tls_profile" VARCHAR(2048) DEFAULT ''`)
+		if err != nil {
+			utils.DebugPrintln("InitDatabase ALTER TABLE domains add tls_profile", err)
+		}
+	}
+	if !dal.ExistColumnInTable("certificates", "acme_domains") {
+		// v1.5.3 certificates obtained by ACME
+		err = dal.ExecSQL(`ALTER TABLE "certificates" ADD COLUMN "acme_domains" VARCHAR(1024) DEFAULT ''`)
+		if err != nil {
+			utils.DebugPrintln("InitDatabase ALTER TABLE certificates add acme_domains", err)
+		}
+	}
+	if !dal.ExistColumnInTable("domains", "extra_cert_ids") {
+		// v1.5.3 multiple certificates of domains
+		err = dal.ExecSQL(`ALTER TABLE "domains" ADD COLUMN "extra_cert_ids" VARCHAR(256) DEFAULT ''`)
+		if err != nil {
+			utils.DebugPrintln("InitDatabase ALTER TABLE domains add extra_cert_ids", err)
+		}
+	}
 }
 
 // LoadAppConfiguration ...
 func LoadAppConfiguration() {
 	utils.DebugPrintln("LoadAppConfiguration")
 	LoadCerts()
+	// Destinations will be reloaded, close the pooled transports
+	ResetTransports()
+	ResetHashRings()
 	LoadApps()
 	LoadCookieRefs()
 	LoadVipApps()
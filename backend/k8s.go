@@ -43,6 +43,7 @@ func UpdatePods(dest *models.Destination, nowTimeStamp int64) {
 			dest.Pods += podItem.Status.PodIP + ":" + dest.PodPort
 		}
 	}
+	EvictPodTransports(dest, dest.Pods)
 	dest.IsUpdating = false
 }
 
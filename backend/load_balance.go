@@ -0,0 +1,190 @@
+/*
+ * @Copyright Reserved By Janusec (https://www.janusec.com/).
+ * @Author: U2
+ * @Date: 2026-10-18 11:05:16
+ * @Last Modified: U2, 2026-10-18 11:05:16
+ */
+
+package backend
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"janusec/models"
+	"janusec/utils"
+)
+
+var (
+	// wrrMutex protect CurrentWeight of destinations
+	wrrMutex sync.Mutex
+
+	// hashRings map[joined destination IDs]*hashRing, built for online destinations of a route
+	hashRings = sync.Map{}
+)
+
+// virtualNodes is the number of virtual nodes for each weight of a destination
+const virtualNodes = 160
+
+type hashRing struct {
+	hashes []uint32
+	dests  []*models.Destination
+}
+
+// SelectDestination select a destination from online destinations by the LBMethod of the route
+func SelectDestination(entry *models.RouteEntry, onlineDests []*models.Destination, r *http.Request, srcIP string) *models.Destination {
+	if len(onlineDests) == 1 {
+		return onlineDests[0]
+	}
+	switch entry.LBMethod {
+	case models.LBMethod_WEIGHTED_ROUND_ROBIN:
+		return selectByWeightedRoundRobin(onlineDests)
+	case models.LBMethod_LEAST_REQUESTS:
+		return selectByLeastRequests(onlineDests)
+	case models.LBMethod_RANDOM_TWO_CHOICES:
+		return selectByRandomTwoChoices(onlineDests)
+	case models.LBMethod_CONSISTENT_HASH:
+		hashValue := getHashKeyValue(entry.HashKey, r, srcIP)
+		return getHashRing(onlineDests).get(hashValue)
+	default:
+		// According to Hash(IP+UA)
+		hashUInt32 := hash32(srcIP + r.UserAgent())
+		destIndex := hashUInt32 % uint32(len(onlineDests))
+		return onlineDests[destIndex]
+	}
+}
+
+// ResetHashRings clear the hash rings, used when destinations changed
+func ResetHashRings() {
+	hashRings.Range(func(key, value interface{}) bool {
+		hashRings.Delete(key)
+		return true
+	})
+}
+
+func getWeight(dest *models.Destination) int64 {
+	if dest.Weight <= 0 {
+		return 1
+	}
+	return dest.Weight
+}
+
+func hash32(value string) uint32 {
+	h := fnv.New32a()
+	_, err := h.Write([]byte(value))
+	if err != nil {
+		utils.DebugPrintln("hash32 h.Write", err)
+	}
+	return h.Sum32()
+}
+
+// selectByWeightedRoundRobin use the smooth weighted round robin, same as nginx
+func selectByWeightedRoundRobin(dests []*models.Destination) *models.Destination {
+	wrrMutex.Lock()
+	defer wrrMutex.Unlock()
+	var totalWeight int64
+	var best *models.Destination
+	for _, dest := range dests {
+		weight := getWeight(dest)
+		dest.CurrentWeight += weight
+		totalWeight += weight
+		if best == nil || dest.CurrentWeight > best.CurrentWeight {
+			best = dest
+		}
+	}
+	best.CurrentWeight -= totalWeight
+	return best
+}
+
+// lessLoaded compare outstanding/weight of two destinations
+func lessLoaded(a *models.Destination, b *models.Destination) bool {
+	return a.Outstanding.Load()*getWeight(b) < b.Outstanding.Load()*getWeight(a)
+}
+
+func selectByLeastRequests(dests []*models.Destination) *models.Destination {
+	best := dests[0]
+	for _, dest := range dests[1:] {
+		if lessLoaded(dest, best) {
+			best = dest
+		}
+	}
+	return best
+}
+
+func selectByRandomTwoChoices(dests []*models.Destination) *models.Destination {
+	i := rand.Intn(len(dests))
+	j := rand.Intn(len(dests) - 1)
+	if j >= i {
+		j++
+	}
+	if lessLoaded(dests[j], dests[i]) {
+		return dests[j]
+	}
+	return dests[i]
+}
+
+// getHashKeyValue hashKey format: ip, header:X-User-ID, cookie:sessionid , use client IP if not found
+func getHashKeyValue(hashKey string, r *http.Request, srcIP string) string {
+	if strings.HasPrefix(hashKey, "header:") {
+		value := r.Header.Get(strings.TrimPrefix(hashKey, "header:"))
+		if len(value) > 0 {
+			return value
+		}
+	} else if strings.HasPrefix(hashKey, "cookie:") {
+		cookie, err := r.Cookie(strings.TrimPrefix(hashKey, "cookie:"))
+		if err == nil && len(cookie.Value) > 0 {
+			return cookie.Value
+		}
+	}
+	return srcIP
+}
+
+func getHashRing(dests []*models.Destination) *hashRing {
+	var ringKey string
+	for _, dest := range dests {
+		ringKey += strconv.FormatInt(dest.ID, 10) + ":" + strconv.FormatInt(getWeight(dest), 10) + "|"
+	}
+	if ringI, ok := hashRings.Load(ringKey); ok {
+		return ringI.(*hashRing)
+	}
+	ring := &hashRing{}
+	for _, dest := range dests {
+		count := int(getWeight(dest)) * virtualNodes
+		for i := 0; i < count; i++ {
+			ring.hashes = append(ring.hashes, hash32(strconv.FormatInt(dest.ID, 10)+"#"+strconv.Itoa(i)))
+			ring.dests = append(ring.dests, dest)
+		}
+	}
+	sort.Sort(ring)
+	hashRings.Store(ringKey, ring)
+	return ring
+}
+
+func (ring *hashRing) get(value string) *models.Destination {
+	h := hash32(value)
+	index := sort.Search(len(ring.hashes), func(i int) bool {
+		return ring.hashes[i] >= h
+	})
+	if index == len(ring.hashes) {
+		index = 0
+	}
+	return ring.dests[index]
+}
+
+func (ring *hashRing) Len() int {
+	return len(ring.hashes)
+}
+
+func (ring *hashRing) Less(i, j int) bool {
+	return ring.hashes[i] < ring.hashes[j]
+}
+
+func (ring *hashRing) Swap(i, j int) {
+	ring.hashes[i], ring.hashes[j] = ring.hashes[j], ring.hashes[i]
+	ring.dests[i], ring.dests[j] = ring.dests[j], ring.dests[i]
+}
@@ -0,0 +1,78 @@
+/*
+ * @Copyright Reserved By Janusec (https://www.janusec.com/).
+ * @Author: U2
+ * @Date: 2026-10-18 16:03:27
+ * @Last Modified: U2, 2026-10-18 16:03:27
+ */
+
+package backend
+
+import (
+	"math/rand"
+	"strconv"
+	"time"
+
+	"janusec/models"
+	"janusec/utils"
+)
+
+// ReportDestinationResult used for passive outlier detection, failed means 5xx or timeout
+func ReportDestinationResult(app *models.Application, dest *models.Destination, failed bool) {
+	policy := app.RetryPolicy
+	if policy == nil || !policy.OutlierDetection {
+		return
+	}
+	nowTimeStamp := time.Now().Unix()
+	dest.Mutex.Lock()
+	defer dest.Mutex.Unlock()
+	if !failed {
+		dest.ConsecutiveErrors = 0
+		if dest.Ejections > 0 && nowTimeStamp-dest.EjectedUntil > getThreshold(policy.MaxEjectionSeconds, 300) {
+			// stable for a while, reset the back-off
+			dest.Ejections = 0
+		}
+		return
+	}
+	dest.ConsecutiveErrors++
+	if dest.ConsecutiveErrors < getThreshold(policy.ConsecutiveErrors, 5) || nowTimeStamp < dest.EjectedUntil {
+		return
+	}
+	dest.ConsecutiveErrors = 0
+	dest.Ejections++
+	ejectionSeconds := getThreshold(policy.BaseEjectionSeconds, 30)
+	maxEjectionSeconds := getThreshold(policy.MaxEjectionSeconds, 300)
+	for i := int64(1); i < dest.Ejections && ejectionSeconds < maxEjectionSeconds; i++ {
+		ejectionSeconds *= 2
+	}
+	if ejectionSeconds > maxEjectionSeconds {
+		ejectionSeconds = maxEjectionSeconds
+	}
+	dest.EjectedUntil = nowTimeStamp + ejectionSeconds
+	targetDest := dest.Destination
+	if dest.RouteType == models.K8S_Ingress {
+		targetDest = dest.PodsAPI
+	}
+	reason := "outlier ejected for " + strconv.FormatInt(ejectionSeconds, 10) + " seconds"
+	addHealthEvent(&dest.HealthState, false, reason, nowTimeStamp)
+	utils.DebugPrintln("ReportDestinationResult", app.Name, targetDest, reason)
+	go sendOfflineNotification(app, targetDest)
+}
+
+// IsDestinationAdmitted return false during ejection, and admit part of requests during slow start
+func IsDestinationAdmitted(app *models.Application, dest *models.Destination, nowTimeStamp int64) bool {
+	if dest.EjectedUntil == 0 {
+		return true
+	}
+	if nowTimeStamp < dest.EjectedUntil {
+		return false
+	}
+	slowStartSeconds := int64(30)
+	if app.RetryPolicy != nil {
+		slowStartSeconds = getThreshold(app.RetryPolicy.SlowStartSeconds, 30)
+	}
+	elapsed := nowTimeStamp - dest.EjectedUntil + 1
+	if elapsed >= slowStartSeconds {
+		return true
+	}
+	return rand.Int63n(slowStartSeconds) < elapsed
+}
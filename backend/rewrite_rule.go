@@ -0,0 +1,217 @@
+/*
+ * @Copyright Reserved By Janusec (https://www.janusec.com/).
+ * @Author: U2
+ * @Date: 2026-10-18 19:24:58
+ * @Last Modified: U2, 2026-10-18 19:24:58
+ */
+
+package backend
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"janusec/data"
+	"janusec/models"
+	"janusec/utils"
+)
+
+// GetRewriteRulesByAppID ...
+func GetRewriteRulesByAppID(appID int64) []*models.RewriteRule {
+	app, err := GetApplicationByID(appID)
+	if err != nil {
+		utils.DebugPrintln("GetRewriteRulesByAppID", err)
+		return []*models.RewriteRule{}
+	}
+	return app.RewriteRules
+}
+
+// UpdateRewriteRule add or update a rewrite rule of application
+func UpdateRewriteRule(body []byte, clientIP string, authUser *models.AuthUser) (*models.RewriteRule, error) {
+	var rpcRuleRequest models.APIRewriteRuleRequest
+	if err := json.Unmarshal(body, &rpcRuleRequest); err != nil {
+		utils.DebugPrintln("UpdateRewriteRule", err)
+		return nil, err
+	}
+	rule := rpcRuleRequest.Object
+	if rule == nil {
+		return nil, errors.New("invalid rewrite rule")
+	}
+	app, err := GetApplicationByID(rule.AppID)
+	if err != nil {
+		return nil, err
+	}
+	if !authUser.IsAppAdmin && !authUser.IsSuperAdmin && app.Owner != authUser.Username {
+		return nil, errors.New("no privilege to perform this operation")
+	}
+	switch rule.Action {
+	case models.RewriteAction_REWRITE:
+	case models.RewriteAction_REDIRECT:
+		switch rule.StatusCode {
+		case 0, http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		default:
+			return nil, errors.New("invalid redirect status code of rewrite rule")
+		}
+	case models.RewriteAction_RETURN:
+		if rule.StatusCode != 0 && (rule.StatusCode < 100 || rule.StatusCode > 599) {
+			return nil, errors.New("invalid status code of rewrite rule")
+		}
+	default:
+		return nil, errors.New("invalid action of rewrite rule")
+	}
+	if _, err := regexp.Compile(rule.Regex); err != nil {
+		return nil, err
+	}
+	if len(rule.HeaderRegex) > 0 {
+		if _, err := regexp.Compile(rule.HeaderRegex); err != nil {
+			return nil, err
+		}
+	}
+	// rules are replaced instead of modified in place, requests in progress still use the old rules
+	rules := []*models.RewriteRule{}
+	if rule.ID == 0 {
+		rule.ID = utils.GenSnowflakeID()
+		err = data.DAL.InsertRewriteRule(rule)
+		if err != nil {
+			utils.DebugPrintln("InsertRewriteRule", err)
+			return nil, err
+		}
+		rules = append(rules, app.RewriteRules...)
+		rules = append(rules, rule)
+		go utils.OperationLog(clientIP, authUser.Username, "Add Rewrite Rule", rule.Regex)
+	} else {
+		oldRule, err := data.DAL.SelectRewriteRuleByID(rule.ID)
+		if err != nil {
+			return nil, err
+		}
+		if oldRule.AppID != rule.AppID {
+			return nil, errors.New("the rewrite rule does not belong to the application")
+		}
+		err = data.DAL.UpdateRewriteRule(rule)
+		if err != nil {
+			utils.DebugPrintln("UpdateRewriteRule", err)
+			return nil, err
+		}
+		for _, obj := range app.RewriteRules {
+			if obj.ID == rule.ID {
+				rules = append(rules, rule)
+			} else {
+				rules = append(rules, obj)
+			}
+		}
+		go utils.OperationLog(clientIP, authUser.Username, "Update Rewrite Rule", rule.Regex)
+	}
+	sort.SliceStable(rules, func(i, j int) bool {
+		return rules[i].Sequence < rules[j].Sequence
+	})
+	app.RewriteRules = rules
+	data.UpdateBackendLastModified()
+	return rule, nil
+}
+
+// DeleteRewriteRule ...
+func DeleteRewriteRule(ruleID int64, clientIP string, authUser *models.AuthUser) error {
+	rule, err := data.DAL.SelectRewriteRuleByID(ruleID)
+	if err != nil {
+		return err
+	}
+	app, _ := GetApplicationByID(rule.AppID)
+	if !authUser.IsAppAdmin && !authUser.IsSuperAdmin && (app == nil || app.Owner != authUser.Username) {
+		return errors.New("no privilege to perform this operation")
+	}
+	err = data.DAL.DeleteRewriteRuleByID(rule.ID)
+	if err != nil {
+		utils.DebugPrintln("DeleteRewriteRule", err)
+		return err
+	}
+	if app != nil {
+		rules := []*models.RewriteRule{}
+		for _, obj := range app.RewriteRules {
+			if obj.ID != rule.ID {
+				rules = append(rules, obj)
+			}
+		}
+		app.RewriteRules = rules
+	}
+	go utils.OperationLog(clientIP, authUser.Username, "Delete Rewrite Rule", rule.Regex)
+	data.UpdateBackendLastModified()
+	return nil
+}
+
+// DeleteRewriteRulesByApp ...
+func DeleteRewriteRulesByApp(app *models.Application) {
+	err := data.DAL.DeleteRewriteRulesByAppID(app.ID)
+	if err != nil {
+		utils.DebugPrintln("DeleteRewriteRulesByAppID", err)
+	}
+	app.RewriteRules = nil
+}
+
+// MatchRewriteRule check the conditions and regex of rule,
+// return the Replacement with capture groups expanded if matched
+func MatchRewriteRule(rule *models.RewriteRule, r *http.Request, srcIP string) (string, bool) {
+	if !rule.Enabled {
+		return "", false
+	}
+	if len(rule.HeaderName) > 0 {
+		value := r.Header.Get(rule.HeaderName)
+		if len(value) == 0 {
+			return "", false
+		}
+		if len(rule.HeaderRegex) > 0 {
+			headerRegex := getRouteRegexp(rule.HeaderRegex)
+			if headerRegex == nil || !headerRegex.MatchString(value) {
+				return "", false
+			}
+		}
+	}
+	if len(rule.IPList) > 0 && !IsIPInList(srcIP, rule.IPList) {
+		return "", false
+	}
+	regex := getRouteRegexp(rule.Regex)
+	if regex == nil {
+		return "", false
+	}
+	var target string
+	switch rule.Target {
+	case "query":
+		target = r.URL.RawQuery
+	case "host":
+		target = r.Host
+	case "uri":
+		target = r.URL.RequestURI()
+	default:
+		target = r.URL.Path
+	}
+	match := regex.FindStringSubmatchIndex(target)
+	if match == nil {
+		return "", false
+	}
+	replacement := regex.ExpandString(nil, rule.Replacement, target, match)
+	return string(replacement), true
+}
+
+// IsIPInList ipList is comma separated IP or CIDR
+func IsIPInList(srcIP string, ipList string) bool {
+	ip := net.ParseIP(srcIP)
+	for _, item := range strings.Split(ipList, ",") {
+		item = strings.TrimSpace(item)
+		if len(item) == 0 {
+			continue
+		}
+		if strings.Contains(item, "/") {
+			_, ipNet, err := net.ParseCIDR(item)
+			if err == nil && ip != nil && ipNet.Contains(ip) {
+				return true
+			}
+		} else if item == srcIP {
+			return true
+		}
+	}
+	return false
+}
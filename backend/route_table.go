@@ -0,0 +1,303 @@
+/*
+ * @Copyright Reserved By Janusec (https://www.janusec.com/).
+ * @Author: U2
+ * @Date: 2026-10-18 18:25:33
+ * @Last Modified: U2, 2026-10-18 18:25:33
+ */
+
+package backend
+
+import (
+	"errors"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"janusec/firewall"
+	"janusec/models"
+	"janusec/utils"
+)
+
+// routeRegexps map[pattern]*regexp.Regexp, compiled regex of routes
+var routeRegexps = sync.Map{}
+
+// BuildRouteTable rebuild the route table of the application from its destinations
+// Entries are sorted by priority (high first), then exact path, path prefix (longest first),
+// file extension, regex, and / at last. For the same path, routes with more method or header predicates
+// are checked before those with fewer or none.
+func BuildRouteTable(app *models.Application) {
+	entriesMap := map[string]*models.RouteEntry{}
+	entries := []*models.RouteEntry{}
+	for _, dest := range app.Destinations {
+		key := getRouteKey(dest)
+		if entry, ok := entriesMap[key]; ok {
+			entry.Destinations = append(entry.Destinations, dest)
+			continue
+		}
+		entry := &models.RouteEntry{
+			RequestRoute: dest.RequestRoute,
+			Match:        dest.RouteMatch,
+			Priority:     dest.Priority,
+			LBMethod:     dest.LBMethod,
+			HashKey:      dest.HashKey,
+			Split:        getTrafficSplit(app, key),
+			Destinations: []*models.Destination{dest},
+		}
+		switch dest.RouteMatch {
+		case models.RouteMatch_REGEX:
+			entry.Regex = getRouteRegexp(dest.RequestRoute)
+			if entry.Regex == nil {
+				continue
+			}
+		case models.RouteMatch_GRPC:
+			entry.RequestRoute = grpcRoutePath(dest.RequestRoute)
+		}
+		for _, method := range strings.Split(dest.Methods, ",") {
+			method = strings.ToUpper(strings.TrimSpace(method))
+			if len(method) > 0 {
+				entry.Methods = append(entry.Methods, method)
+			}
+		}
+		if len(strings.TrimSpace(dest.HeaderMatch)) > 0 {
+			header := strings.SplitN(dest.HeaderMatch, ":", 2)
+			entry.HeaderName = strings.TrimSpace(header[0])
+			if len(header) == 2 {
+				entry.HeaderValue = strings.TrimSpace(header[1])
+			}
+		}
+		entriesMap[key] = entry
+		entries = append(entries, entry)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		rankA, rankB := getRouteRank(a), getRouteRank(b)
+		if rankA != rankB {
+			return rankA < rankB
+		}
+		if len(a.RequestRoute) != len(b.RequestRoute) {
+			return len(a.RequestRoute) > len(b.RequestRoute)
+		}
+		return getPredicateCount(a) > getPredicateCount(b)
+	})
+	app.Routes.Store(&models.RouteTable{Entries: entries})
+}
+
+// GetRouteEntry return the first route which match the request
+// urlPath is the original path before rewriting
+func GetRouteEntry(app *models.Application, r *http.Request, urlPath string) *models.RouteEntry {
+	routeTable := app.Routes.Load()
+	if routeTable == nil {
+		return nil
+	}
+	for _, entry := range routeTable.Entries {
+		if IsRouteMatched(entry, r, urlPath) {
+			return entry
+		}
+	}
+	// lack of route /
+	return nil
+}
+
+// CheckRouteLBMethods check destinations of the same route use the same load balancing method and hash key, v1.5.3
+func CheckRouteLBMethods(destinations []*models.Destination) error {
+	routeDests := map[string]*models.Destination{}
+	for _, dest := range destinations {
+		key := getRouteKey(dest)
+		first, ok := routeDests[key]
+		if !ok {
+			routeDests[key] = dest
+			continue
+		}
+		if dest.LBMethod != first.LBMethod || dest.HashKey != first.HashKey {
+			return errors.New("destinations of route " + dest.RequestRoute + " should use the same load balancing method and hash key")
+		}
+	}
+	return nil
+}
+
+// getRouteKey destinations with the same key belong to the same route entry
+func getRouteKey(dest *models.Destination) string {
+	return newRouteKey(dest.RouteMatch, dest.RequestRoute, dest.Methods, dest.HeaderMatch, dest.Priority)
+}
+
+// newRouteKey the prefix route /api is the same as /api/
+func newRouteKey(match models.RouteMatch, requestRoute string, methods string, headerMatch string, priority int64) string {
+	requestRoute = strings.TrimSpace(requestRoute)
+	if match == models.RouteMatch_PREFIX && strings.HasPrefix(requestRoute, "/") && !strings.HasSuffix(requestRoute, "/") {
+		requestRoute += "/"
+	}
+	return strconv.FormatInt(int64(match), 10) + "|" + requestRoute + "|" + methods + "|" + headerMatch + "|" + strconv.FormatInt(priority, 10)
+}
+
+// IsGRPCBackend check whether the gRPC request is forwarded to a gRPC backend, decided by the h2c or grpc
+// scheme of the application, or the gRPC route matched, the Content-Type of the client is not trusted alone, v1.5.3
+func IsGRPCBackend(app *models.Application, r *http.Request) bool {
+	if !firewall.IsGRPCRequest(r) {
+		return false
+	}
+	if IsH2CScheme(app.InternalScheme) {
+		return true
+	}
+	routeTable := app.Routes.Load()
+	if routeTable == nil {
+		return false
+	}
+	for _, entry := range routeTable.Entries {
+		if IsRouteMatched(entry, r, r.URL.Path) {
+			return entry.Match == models.RouteMatch_GRPC
+		}
+	}
+	return false
+}
+
+// IsRouteMatched check the path, method and header of the route entry
+func IsRouteMatched(entry *models.RouteEntry, r *http.Request, urlPath string) bool {
+	switch entry.Match {
+	case models.RouteMatch_EXACT:
+		if urlPath != entry.RequestRoute {
+			return false
+		}
+	case models.RouteMatch_REGEX:
+		if !entry.Regex.MatchString(urlPath) {
+			return false
+		}
+	case models.RouteMatch_GRPC:
+		if !firewall.IsGRPCRequest(r) {
+			return false
+		}
+		if strings.HasSuffix(entry.RequestRoute, "/") {
+			// all methods of the service
+			if !strings.HasPrefix(urlPath, entry.RequestRoute) {
+				return false
+			}
+		} else if urlPath != entry.RequestRoute {
+			return false
+		}
+	default:
+		if strings.HasPrefix(entry.RequestRoute, ".") {
+			if filepath.Ext(urlPath) != entry.RequestRoute {
+				return false
+			}
+		} else if !strings.HasPrefix(urlPath, entry.RequestRoute) {
+			return false
+		}
+	}
+	if len(entry.Methods) > 0 {
+		matched := false
+		for _, method := range entry.Methods {
+			if method == r.Method {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if len(entry.HeaderName) > 0 {
+		values := r.Header.Values(entry.HeaderName)
+		if len(values) == 0 {
+			return false
+		}
+		if len(entry.HeaderValue) > 0 {
+			for _, value := range values {
+				if value == entry.HeaderValue {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	return true
+}
+
+// RewriteRoutePath replace RequestRoute with BackendRoute for reverse proxy
+func RewriteRoutePath(dest *models.Destination, urlPath string) string {
+	if dest.RouteType != models.ReverseProxyRoute || dest.RequestRoute == dest.BackendRoute {
+		return urlPath
+	}
+	switch dest.RouteMatch {
+	case models.RouteMatch_EXACT:
+		return dest.BackendRoute
+	case models.RouteMatch_REGEX:
+		regex := getRouteRegexp(dest.RequestRoute)
+		if regex == nil || len(dest.BackendRoute) == 0 {
+			return urlPath
+		}
+		return regex.ReplaceAllString(urlPath, dest.BackendRoute)
+	case models.RouteMatch_GRPC:
+		if len(dest.BackendRoute) == 0 {
+			return urlPath
+		}
+		return strings.Replace(urlPath, grpcRoutePath(dest.RequestRoute), grpcRoutePath(dest.BackendRoute), 1)
+	default:
+		if strings.HasPrefix(dest.RequestRoute, ".") {
+			// extension route is not rewritten
+			return urlPath
+		}
+		return strings.Replace(urlPath, dest.RequestRoute, dest.BackendRoute, 1)
+	}
+}
+
+// getRouteRank exact or gRPC method 0, path prefix or gRPC service 1, extension 2, regex 3, / 4
+func getRouteRank(entry *models.RouteEntry) int {
+	switch entry.Match {
+	case models.RouteMatch_EXACT:
+		return 0
+	case models.RouteMatch_REGEX:
+		return 3
+	case models.RouteMatch_GRPC:
+		if strings.HasSuffix(entry.RequestRoute, "/") {
+			return 1
+		}
+		return 0
+	}
+	if entry.RequestRoute == "/" {
+		return 4
+	}
+	if strings.HasPrefix(entry.RequestRoute, ".") {
+		return 2
+	}
+	return 1
+}
+
+// grpcRoutePath convert package.Service/Method to the path /package.Service/Method ,
+// package.Service or package.Service/* to the prefix /package.Service/
+func grpcRoutePath(route string) string {
+	route = strings.TrimSuffix(strings.Trim(strings.TrimSpace(route), "/"), "/*")
+	if strings.Contains(route, "/") {
+		return "/" + route
+	}
+	return "/" + route + "/"
+}
+
+func getPredicateCount(entry *models.RouteEntry) int {
+	count := 0
+	if len(entry.Methods) > 0 {
+		count++
+	}
+	if len(entry.HeaderName) > 0 {
+		count++
+	}
+	return count
+}
+
+func getRouteRegexp(pattern string) *regexp.Regexp {
+	if regexI, ok := routeRegexps.Load(pattern); ok {
+		return regexI.(*regexp.Regexp)
+	}
+	regex, err := regexp.Compile(pattern)
+	if err != nil {
+		utils.DebugPrintln("getRouteRegexp", pattern, err)
+		return nil
+	}
+	routeRegexps.Store(pattern, regex)
+	return regex
+}
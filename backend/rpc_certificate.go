@@ -33,6 +33,10 @@ func RPCSelectCertificates() []*models.CertItem {
 	}
 	certItems := rpcCertItems.Object
 	for _, certItem := range certItems {
+		if IsCACertItem(certItem) {
+			certs = append(certs, certItem)
+			continue
+		}
 		certItem.TlsCert, err = tls.X509KeyPair([]byte(certItem.CertContent), []byte(certItem.PrivKeyContent))
 		if err != nil {
 			utils.DebugPrintln("RPCSelectCertificates X509KeyPair", err)
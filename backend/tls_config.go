@@ -0,0 +1,260 @@
+/*
+ * @Copyright Reserved By Janusec (https://www.janusec.com/).
+ * @Author: U2
+ * @Date: 2026-10-18 23:36:15
+ * @Last Modified: U2, 2026-10-18 23:36:15
+ */
+
+package backend
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"janusec/data"
+	"janusec/models"
+	"janusec/utils"
+)
+
+// tlsConfig is the base config of the HTTPS listeners, tuned by the TLS profile of domain
+var tlsConfig *tls.Config
+
+// intermediateCipherSuites of TLS 1.2, the cipher suites of TLS 1.3 are not configurable
+var intermediateCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+}
+
+// legacyCipherSuites add CBC and RSA key exchange for old clients
+var legacyCipherSuites = append(append([]uint16{}, intermediateCipherSuites...),
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA256,
+	tls.TLS_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_RSA_WITH_AES_128_CBC_SHA,
+	tls.TLS_RSA_WITH_AES_256_CBC_SHA,
+)
+
+var tlsCurves = map[string]tls.CurveID{
+	"X25519": tls.X25519,
+	"P256":   tls.CurveP256,
+	"P384":   tls.CurveP384,
+	"P521":   tls.CurveP521,
+}
+
+// sessionTicketKeysCount is the number of keys kept, tickets are valid for two rotations
+const sessionTicketKeysCount = 3
+
+// InitTLSConfig create the base config of the HTTPS listeners with the intermediate profile, v1.5.3
+func InitTLSConfig() *tls.Config {
+	tlsConfig = &tls.Config{
+		GetCertificate: GetCertificateByDomain,
+		NextProtos:     []string{"h2", "http/1.1"},
+		MinVersion:     tls.VersionTLS12,
+		MaxVersion:     tls.VersionTLS13,
+		CipherSuites:   intermediateCipherSuites,
+	}
+	// client certificates and TLS profiles are selected by SNI
+	tlsConfig.GetConfigForClient = func(helloInfo *tls.ClientHelloInfo) (*tls.Config, error) {
+		return GetConfigForClient(tlsConfig, helloInfo)
+	}
+	UpdateSessionTicketKeys(data.NodeSetting.SessionTicketKeys)
+	return tlsConfig
+}
+
+// GetConfigForClient return the TLS config by the TLS profile of SNI and the client authentication
+// of its application, nil means using baseConfig
+func GetConfigForClient(baseConfig *tls.Config, helloInfo *tls.ClientHelloInfo) (*tls.Config, error) {
+	domainName := strings.ToLower(helloInfo.ServerName)
+	app := GetApplicationByDomain(domainName)
+	tlsProfile := getDomainTLSProfile(domainName)
+	needClientAuth := app != nil && app.ClientAuth != nil && app.ClientAuth.Mode != models.ClientAuth_NONE
+	if tlsProfile == nil && !needClientAuth {
+		return nil, nil
+	}
+	cfg := baseConfig.Clone()
+	cfg.GetConfigForClient = nil
+	if tlsProfile != nil {
+		applyTLSProfile(cfg, tlsProfile)
+	}
+	if needClientAuth {
+		if err := applyClientAuth(cfg, app.ClientAuth); err != nil {
+			utils.DebugPrintln("GetConfigForClient", helloInfo.ServerName, err)
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
+
+// NewAdminTLSConfig return the config of the admin listener which requires client certificates signed by clientCAs,
+// the live base config is cloned for each handshake, so that rotated session ticket keys and TLS profiles apply
+func NewAdminTLSConfig(baseConfig *tls.Config, clientCAs *x509.CertPool) *tls.Config {
+	adminConfig := baseConfig.Clone()
+	adminConfig.ClientCAs = clientCAs
+	adminConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	adminConfig.GetConfigForClient = func(helloInfo *tls.ClientHelloInfo) (*tls.Config, error) {
+		cfg := baseConfig.Clone()
+		cfg.GetConfigForClient = nil
+		if tlsProfile := getDomainTLSProfile(strings.ToLower(helloInfo.ServerName)); tlsProfile != nil {
+			applyTLSProfile(cfg, tlsProfile)
+		}
+		// client authentication of applications is not used by the admin portal
+		cfg.ClientCAs = clientCAs
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		return cfg, nil
+	}
+	return adminConfig
+}
+
+// getDomainTLSProfile return the TLS profile of the domain or its wildcard domain
+func getDomainTLSProfile(domainName string) *models.TLSProfile {
+	if domainRelation, ok := DomainsMap.Load(domainName); ok {
+		return domainRelation.(models.DomainRelation).TLSProfile
+	}
+	if domainRelation, ok := DomainsMap.Load(GetWildDomainName(domainName)); ok {
+		return domainRelation.(models.DomainRelation).TLSProfile
+	}
+	return nil
+}
+
+// applyTLSProfile set versions, cipher suites, curves and ALPN of the profile
+func applyTLSProfile(cfg *tls.Config, tlsProfile *models.TLSProfile) {
+	switch tlsProfile.Name {
+	case models.TLSProfile_MODERN:
+		cfg.MinVersion = tls.VersionTLS13
+	case models.TLSProfile_LEGACY:
+		cfg.MinVersion = tls.VersionTLS10
+		cfg.CipherSuites = legacyCipherSuites
+	case models.TLSProfile_CUSTOM:
+		if version, ok := tlsVersions[tlsProfile.MinVersion]; ok {
+			cfg.MinVersion = version
+		}
+		if version, ok := tlsVersions[tlsProfile.MaxVersion]; ok {
+			cfg.MaxVersion = version
+		}
+		if len(tlsProfile.CipherSuites) > 0 {
+			cfg.CipherSuites = getCipherSuiteIDs(tlsProfile.CipherSuites)
+		}
+		for _, curveName := range tlsProfile.Curves {
+			if curveID, ok := tlsCurves[curveName]; ok {
+				cfg.CurvePreferences = append(cfg.CurvePreferences, curveID)
+			} else {
+				utils.DebugPrintln("applyTLSProfile unknown curve", curveName)
+			}
+		}
+	}
+	if len(tlsProfile.ALPN) > 0 {
+		cfg.NextProtos = tlsProfile.ALPN
+	}
+	cfg.SessionTicketsDisabled = tlsProfile.SessionTicketsDisabled
+}
+
+// getCipherSuiteIDs convert the names of cipher suites, unknown names are ignored
+func getCipherSuiteIDs(names []string) []uint16 {
+	suites := map[string]uint16{}
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		suites[suite.Name] = suite.ID
+	}
+	ids := []uint16{}
+	for _, name := range names {
+		if id, ok := suites[strings.TrimSpace(name)]; ok {
+			ids = append(ids, id)
+		} else {
+			utils.DebugPrintln("getCipherSuiteIDs unknown cipher suite", name)
+		}
+	}
+	return ids
+}
+
+// GetTLSProfile convert JSON string to TLSProfile, return nil if empty
+func GetTLSProfile(tlsProfileStr string) *models.TLSProfile {
+	if len(tlsProfileStr) == 0 {
+		return nil
+	}
+	tlsProfile := &models.TLSProfile{}
+	err := json.Unmarshal([]byte(tlsProfileStr), tlsProfile)
+	if err != nil {
+		utils.DebugPrintln("GetTLSProfile Unmarshal", err)
+		return nil
+	}
+	return tlsProfile
+}
+
+// GetTLSProfileString convert TLSProfile to JSON string
+func GetTLSProfileString(tlsProfile *models.TLSProfile) string {
+	if tlsProfile == nil {
+		return ""
+	}
+	tlsProfileBytes, err := json.Marshal(tlsProfile)
+	if err != nil {
+		utils.DebugPrintln("GetTLSProfileString Marshal", err)
+		return ""
+	}
+	return string(tlsProfileBytes)
+}
+
+// RoutineRotateSessionTicketKeys generate session ticket keys on primary node, replica nodes get them by NodeSetting
+func RoutineRotateSessionTicketKeys() {
+	for {
+		rotateSessionTicketKeys()
+		rotation := data.PrimarySetting.SessionTicketRotation
+		if rotation <= 0 {
+			rotation = 24
+		}
+		time.Sleep(time.Duration(rotation) * time.Hour)
+	}
+}
+
+func rotateSessionTicketKeys() {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		utils.DebugPrintln("rotateSessionTicketKeys", err)
+		return
+	}
+	keys := append([]string{hex.EncodeToString(key)}, data.GetSessionTicketKeys()...)
+	if len(keys) > sessionTicketKeysCount {
+		keys = keys[:sessionTicketKeysCount]
+	}
+	data.SetSessionTicketKeys(keys)
+	UpdateSessionTicketKeys(keys)
+}
+
+// currentTicketKeys is used to check whether the session ticket keys changed
+var currentTicketKeys string
+
+// UpdateSessionTicketKeys set the session ticket keys shared by all nodes, so that sessions
+// can be resumed on other nodes behind DNS load balancing
+func UpdateSessionTicketKeys(hexKeys []string) {
+	if tlsConfig == nil || len(hexKeys) == 0 || strings.Join(hexKeys, ",") == currentTicketKeys {
+		return
+	}
+	keys := [][32]byte{}
+	for _, hexKey := range hexKeys {
+		keyBytes, err := hex.DecodeString(hexKey)
+		if err != nil || len(keyBytes) != 32 {
+			utils.DebugPrintln("UpdateSessionTicketKeys invalid key", err)
+			continue
+		}
+		var key [32]byte
+		copy(key[:], keyBytes)
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return
+	}
+	tlsConfig.SetSessionTicketKeys(keys)
+	currentTicketKeys = strings.Join(hexKeys, ",")
+}
@@ -0,0 +1,212 @@
+/*
+ * @Copyright Reserved By Janusec (https://www.janusec.com/).
+ * @Author: U2
+ * @Date: 2026-10-18 17:12:45
+ * @Last Modified: U2, 2026-10-18 17:12:45
+ */
+
+package backend
+
+import (
+	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"janusec/models"
+)
+
+// splitCookieMaxAge is the lifetime of the sticky cookie of traffic split, in seconds
+const splitCookieMaxAge = 86400
+
+// getTrafficSplit return the traffic split of the route, nil if not configured
+func getTrafficSplit(app *models.Application, routeKey string) *models.TrafficSplit {
+	for _, split := range app.TrafficSplits {
+		if len(split.Groups) > 0 && getSplitRouteKey(split) == routeKey {
+			return split
+		}
+	}
+	return nil
+}
+
+// getSplitRouteKey return the key of the route which the split applies to, same as getRouteKey of destinations
+func getSplitRouteKey(split *models.TrafficSplit) string {
+	return newRouteKey(split.RouteMatch, split.RequestRoute, split.Methods, split.HeaderMatch, split.Priority)
+}
+
+// SelectSplitGroup return the destination group for the request,
+// and the cookie which should be set to the client if the split is sticky
+func SelectSplitGroup(app *models.Application, r *http.Request, srcIP string) (string, *http.Cookie) {
+	if len(app.TrafficSplits) == 0 {
+		return "", nil
+	}
+	entry := GetRouteEntry(app, r, r.URL.Path)
+	if entry == nil || entry.Split == nil {
+		return "", nil
+	}
+	split := entry.Split
+	// override matchers pin the request to a group
+	for _, matcher := range split.Matchers {
+		if IsSplitMatcherMatched(matcher, r, srcIP) {
+			return matcher.Group, nil
+		}
+	}
+	cookieName := getSplitCookieName(getSplitRouteKey(split))
+	if split.Sticky {
+		cookie, err := r.Cookie(cookieName)
+		if err == nil && containsSplitGroup(split, cookie.Value) {
+			return cookie.Value, nil
+		}
+	}
+	var total int64
+	for _, group := range split.Groups {
+		if group.Percent > 0 {
+			total += group.Percent
+		}
+	}
+	if total <= 0 {
+		return split.Groups[0].Name, nil
+	}
+	var bucket int64
+	if split.Sticky {
+		// clients without cookie stay on the same group by Hash(IP+UA)
+		bucket = int64(hash32(srcIP+r.UserAgent()) % uint32(total))
+	} else {
+		bucket = rand.Int63n(total)
+	}
+	groupName := split.Groups[len(split.Groups)-1].Name
+	for _, group := range split.Groups {
+		if group.Percent <= 0 {
+			continue
+		}
+		if bucket < group.Percent {
+			groupName = group.Name
+			break
+		}
+		bucket -= group.Percent
+	}
+	if !split.Sticky {
+		return groupName, nil
+	}
+	cookie := &http.Cookie{
+		Name:     cookieName,
+		Value:    groupName,
+		Path:     "/",
+		MaxAge:   splitCookieMaxAge,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	}
+	return groupName, cookie
+}
+
+// IsSplitMatcherMatched check header, cookie, query parameter or client IP CIDR
+func IsSplitMatcherMatched(matcher *models.SplitMatcher, r *http.Request, srcIP string) bool {
+	var values []string
+	switch strings.ToLower(matcher.Type) {
+	case "header":
+		values = r.Header.Values(matcher.Name)
+	case "cookie":
+		cookie, err := r.Cookie(matcher.Name)
+		if err == nil {
+			values = []string{cookie.Value}
+		}
+	case "query":
+		values = r.URL.Query()[matcher.Name]
+	case "ip":
+		_, ipNet, err := net.ParseCIDR(matcher.Value)
+		if err != nil {
+			// single IP address
+			return matcher.Value == srcIP
+		}
+		ip := net.ParseIP(srcIP)
+		return ip != nil && ipNet.Contains(ip)
+	default:
+		return false
+	}
+	for _, value := range values {
+		if len(matcher.Value) == 0 || value == matcher.Value {
+			return true
+		}
+	}
+	return false
+}
+
+// getGroupDestinations return destinations of the group if the route has traffic split
+func getGroupDestinations(entry *models.RouteEntry, group string) []*models.Destination {
+	if entry.Split == nil {
+		return entry.Destinations
+	}
+	groupDests := []*models.Destination{}
+	for _, dest := range entry.Destinations {
+		if dest.Group == group {
+			groupDests = append(groupDests, dest)
+		}
+	}
+	return groupDests
+}
+
+// getFallbackDestinations return destinations of the remaining groups of the split,
+// used when the selected group has no online destination
+func getFallbackDestinations(entry *models.RouteEntry, group string) []*models.Destination {
+	if entry.Split == nil {
+		return nil
+	}
+	fallbackDests := []*models.Destination{}
+	for _, dest := range entry.Destinations {
+		if dest.Group != group && containsSplitGroup(entry.Split, dest.Group) {
+			fallbackDests = append(fallbackDests, dest)
+		}
+	}
+	return fallbackDests
+}
+
+// CheckTrafficSplits check the routes and group names of traffic splits with the destinations of the application
+func CheckTrafficSplits(splits []*models.TrafficSplit, destinations []*models.Destination) error {
+	routeGroups := map[string]map[string]bool{}
+	for _, dest := range destinations {
+		key := getRouteKey(dest)
+		if routeGroups[key] == nil {
+			routeGroups[key] = map[string]bool{}
+		}
+		routeGroups[key][dest.Group] = true
+	}
+	splitKeys := map[string]bool{}
+	for _, split := range splits {
+		key := getSplitRouteKey(split)
+		groups, ok := routeGroups[key]
+		if !ok {
+			return errors.New("traffic split of " + split.RequestRoute + " does not match any route")
+		}
+		if splitKeys[key] {
+			return errors.New("duplicate traffic split of " + split.RequestRoute)
+		}
+		splitKeys[key] = true
+		for _, group := range split.Groups {
+			if !groups[group.Name] {
+				return errors.New("group " + group.Name + " of traffic split " + split.RequestRoute + " has no destination")
+			}
+		}
+		for _, matcher := range split.Matchers {
+			if !containsSplitGroup(split, matcher.Group) {
+				return errors.New("group " + matcher.Group + " of matcher is not in traffic split " + split.RequestRoute)
+			}
+		}
+	}
+	return nil
+}
+
+func containsSplitGroup(split *models.TrafficSplit, groupName string) bool {
+	for _, group := range split.Groups {
+		if group.Name == groupName {
+			return true
+		}
+	}
+	return false
+}
+
+func getSplitCookieName(routeKey string) string {
+	return "janusec-split-" + strconv.FormatUint(uint64(hash32(routeKey)), 16)
+}
@@ -0,0 +1,328 @@
+/*
+ * @Copyright Reserved By Janusec (https://www.janusec.com/).
+ * @Author: U2
+ * @Date: 2026-10-18 10:12:30
+ * @Last Modified: U2, 2026-10-18 10:12:30
+ */
+
+package backend
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"janusec/data"
+	"janusec/models"
+	"janusec/utils"
+
+	"golang.org/x/net/http2"
+)
+
+// roundTripCloser is implemented by http.Transport and http2.Transport (h2c)
+type roundTripCloser interface {
+	http.RoundTripper
+	CloseIdleConnections()
+}
+
+// upstreamTransport is a long-lived transport to a destination (IP:Port) or a K8S pod
+type upstreamTransport struct {
+	appID     int64
+	transport roundTripCloser
+}
+
+// transports map[destID|IP:Port]*upstreamTransport, keep-alive connections are reused across requests
+var transports = sync.Map{}
+
+func transportKey(destID int64, targetDest string) string {
+	return strconv.FormatInt(destID, 10) + "|" + targetDest
+}
+
+// IsH2CScheme return true if the internal scheme is HTTP/2 without TLS (h2c or grpc), v1.5.3
+func IsH2CScheme(scheme string) bool {
+	return scheme == "h2c" || scheme == "grpc"
+}
+
+// GetTransport return the pooled transport, targetDest is the backend IP:Port of a service or a K8S Pod
+// h2c for the backend which only accept HTTP/2 without TLS, such as gRPC server
+func GetTransport(dest *models.Destination, targetDest string, h2c bool) http.RoundTripper {
+	key := transportKey(dest.ID, targetDest)
+	if h2c {
+		key = "h2c|" + key
+	}
+	if upstreamI, ok := transports.Load(key); ok {
+		return upstreamI.(*upstreamTransport).transport
+	}
+	upstream := &upstreamTransport{appID: dest.AppID}
+	if h2c {
+		upstream.transport = newH2CTransport(dest, targetDest)
+	} else {
+		upstream.transport = newTransport(dest, targetDest)
+	}
+	upstreamI, loaded := transports.LoadOrStore(key, upstream)
+	if loaded {
+		// created by another request at the same time
+		upstream.transport.CloseIdleConnections()
+	}
+	return upstreamI.(*upstreamTransport).transport
+}
+
+// InitAppTransports create transports for the destinations of the application
+func InitAppTransports(app *models.Application) {
+	for _, dest := range app.Destinations {
+		if dest.RouteType == models.ReverseProxyRoute {
+			GetTransport(dest, dest.Destination, IsH2CScheme(app.InternalScheme))
+		}
+		// transports for K8S pods are created when the pod is selected
+	}
+}
+
+// ResetAppTransports close the transports of the application, used when destinations changed
+func ResetAppTransports(appID int64) {
+	transports.Range(func(key, value interface{}) bool {
+		upstream := value.(*upstreamTransport)
+		if upstream.appID == appID {
+			transports.Delete(key)
+			upstream.transport.CloseIdleConnections()
+		}
+		return true
+	})
+}
+
+// EvictPodTransports close the transports of K8S pods which are no longer in the pods of the destination,
+// called after the pods are refreshed, pods format: IP:Port|IP:Port
+func EvictPodTransports(dest *models.Destination, pods string) {
+	runningPods := map[string]bool{}
+	for _, pod := range strings.Split(pods, "|") {
+		runningPods[pod] = true
+	}
+	prefix := transportKey(dest.ID, "")
+	transports.Range(func(key, value interface{}) bool {
+		targetDest, ok := strings.CutPrefix(strings.TrimPrefix(key.(string), "h2c|"), prefix)
+		if ok && !runningPods[targetDest] {
+			transports.Delete(key)
+			value.(*upstreamTransport).transport.CloseIdleConnections()
+		}
+		return true
+	})
+}
+
+// ResetTransports close all transports, used when application configuration reloaded
+func ResetTransports() {
+	transports.Range(func(key, value interface{}) bool {
+		transports.Delete(key)
+		value.(*upstreamTransport).transport.CloseIdleConnections()
+		return true
+	})
+}
+
+// GetMirrorTransport return the pooled transport to the mirror target of the destination
+func GetMirrorTransport(dest *models.Destination) http.RoundTripper {
+	key := "mirror|" + transportKey(dest.ID, dest.MirrorTarget)
+	if upstreamI, ok := transports.Load(key); ok {
+		return upstreamI.(*upstreamTransport).transport
+	}
+	dialer := newDialer()
+	mirrorTarget := dest.MirrorTarget
+	upstream := &upstreamTransport{
+		appID: dest.AppID,
+		// failures of the mirror target do not affect the status of destination
+		transport: buildTransport(dest.AppID, mirrorTarget, func(ctx context.Context) (net.Conn, error) {
+			return dialer.DialContext(ctx, "tcp", mirrorTarget)
+		}),
+	}
+	upstreamI, loaded := transports.LoadOrStore(key, upstream)
+	if loaded {
+		upstream.transport.CloseIdleConnections()
+	}
+	return upstreamI.(*upstreamTransport).transport
+}
+
+func newDialer() *net.Dialer {
+	return &net.Dialer{
+		Timeout:   time.Duration(data.CFG.Upstream.DialTimeout) * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+}
+
+func newTransport(dest *models.Destination, targetDest string) *http.Transport {
+	dialer := newDialer()
+	transport := buildTransport(dest.AppID, targetDest, func(ctx context.Context) (net.Conn, error) {
+		return dialDestination(ctx, dialer, dest, targetDest)
+	})
+	if dest.ProxyProtocol != utils.ProxyProtocol_NONE {
+		// PROXY header describes one client, so the connection can not be shared by other requests
+		transport.DisableKeepAlives = true
+	}
+	return transport
+}
+
+// newH2CTransport create HTTP/2 transport without TLS, requests are multiplexed on the connection
+func newH2CTransport(dest *models.Destination, targetDest string) roundTripCloser {
+	dialer := newDialer()
+	transport := &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, cfg *tls.Config) (net.Conn, error) {
+			return dialDestination(ctx, dialer, dest, targetDest)
+		},
+		IdleConnTimeout: time.Duration(data.CFG.Upstream.IdleConnTimeout) * time.Second,
+		// health check of long-lived connections, such as gRPC streams
+		ReadIdleTimeout: 30 * time.Second,
+		PingTimeout:     15 * time.Second,
+	}
+	if dest.ProxyProtocol != utils.ProxyProtocol_NONE {
+		// PROXY header describes one client, so the connection can not be multiplexed by other requests
+		return &h2cSingleUseTransport{
+			transport: transport,
+			dial: func(ctx context.Context) (net.Conn, error) {
+				return dialDestination(ctx, dialer, dest, targetDest)
+			},
+		}
+	}
+	return transport
+}
+
+// h2cSingleUseTransport send each request on a new h2c connection, which is closed with the response body
+type h2cSingleUseTransport struct {
+	transport *http2.Transport
+	dial      func(ctx context.Context) (net.Conn, error)
+}
+
+func (t *h2cSingleUseTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	conn, err := t.dial(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	clientConn, err := t.transport.NewClientConn(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp, err := clientConn.RoundTrip(req)
+	if err != nil {
+		clientConn.Close()
+		return nil, err
+	}
+	resp.Body = &clientConnBody{ReadCloser: resp.Body, clientConn: clientConn}
+	return resp, nil
+}
+
+// CloseIdleConnections connections are not kept idle
+func (t *h2cSingleUseTransport) CloseIdleConnections() {}
+
+// clientConnBody close the h2c connection after the response body is closed
+type clientConnBody struct {
+	io.ReadCloser
+	clientConn *http2.ClientConn
+}
+
+func (b *clientConnBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.clientConn.Close()
+	return err
+}
+
+// proxyAddrsKey is the context key of the client connection addresses sent by PROXY protocol
+type proxyAddrsKey struct{}
+
+type proxyAddrs struct {
+	src net.Addr
+	dst net.Addr
+}
+
+// WithProxyAddrs return the context carrying the client address and the gateway address of the connection
+func WithProxyAddrs(ctx context.Context, src net.Addr, dst net.Addr) context.Context {
+	return context.WithValue(ctx, proxyAddrsKey{}, &proxyAddrs{src: src, dst: dst})
+}
+
+// buildTransport create transport which connect to targetDest by dial, TLS is set by the application
+func buildTransport(appID int64, targetDest string, dial func(ctx context.Context) (net.Conn, error)) *http.Transport {
+	cfg := data.CFG.Upstream
+	transport := &http.Transport{
+		MaxIdleConns:          cfg.MaxIdleConns,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       cfg.MaxConnsPerHost,
+		IdleConnTimeout:       time.Duration(cfg.IdleConnTimeout) * time.Second,
+		TLSHandshakeTimeout:   time.Duration(cfg.TLSHandshakeTimeout) * time.Second,
+		ResponseHeaderTimeout: time.Duration(cfg.ResponseHeaderTimeout) * time.Second,
+		ExpectContinueTimeout: 30 * time.Second,
+		// addr is the Host of the request, the real backend is targetDest
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return dial(ctx)
+		},
+		DialTLSContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			serverName, _, err := net.SplitHostPort(addr)
+			if err != nil {
+				serverName = addr
+			}
+			cfg, err := newUpstreamTLSConfig(appID, serverName)
+			if err != nil {
+				utils.DebugPrintln("Upstream TLS config error", targetDest, err)
+				return nil, fmt.Errorf("%w: %w", ErrUpstreamTLS, err)
+			}
+			conn, err := dial(ctx)
+			if err != nil {
+				return nil, err
+			}
+			tlsConn := tls.Client(conn, cfg)
+			if err := tlsConn.HandshakeContext(ctx); err != nil {
+				// v1.5.3 certificate verification and mutual TLS failures, the destination is still online
+				utils.DebugPrintln("Upstream TLS handshake error", targetDest, cfg.ServerName, err)
+				conn.Close()
+				return nil, fmt.Errorf("%w: %w", ErrUpstreamTLS, err)
+			}
+			return tlsConn, nil
+		},
+	}
+	err := http2.ConfigureTransport(transport)
+	if err != nil {
+		utils.DebugPrintln("http2.ConfigureTransport error", err)
+	}
+	return transport
+}
+
+// connectTimeoutKey is the context key of the connect timeout of the application
+type connectTimeoutKey struct{}
+
+// WithConnectTimeout return the context carrying the timeout of connecting to the destination
+func WithConnectTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, connectTimeoutKey{}, timeout)
+}
+
+func dialDestination(ctx context.Context, dialer *net.Dialer, dest *models.Destination, targetDest string) (net.Conn, error) {
+	nowTimeStamp := time.Now().Unix()
+	if timeout, ok := ctx.Value(connectTimeoutKey{}).(time.Duration); ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", targetDest)
+	dest.Mutex.Lock()
+	defer dest.Mutex.Unlock()
+	dest.CheckTime = nowTimeStamp
+	if err != nil {
+		utils.DebugPrintln("DialContext error", targetDest, err, time.Now().Unix()-nowTimeStamp, "seconds")
+		SetDestinationOffline(dest)
+		return conn, err
+	}
+	if dest.ProxyProtocol != utils.ProxyProtocol_NONE {
+		var src, dst net.Addr
+		if addrs, ok := ctx.Value(proxyAddrsKey{}).(*proxyAddrs); ok {
+			src, dst = addrs.src, addrs.dst
+		}
+		err = utils.WriteProxyHeader(conn, dest.ProxyProtocol, src, dst)
+		if err != nil {
+			utils.DebugPrintln("WriteProxyHeader error", targetDest, err)
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
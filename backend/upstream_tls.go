@@ -0,0 +1,93 @@
+/*
+ * @Copyright Reserved By Janusec (https://www.janusec.com/).
+ * @Author: U2
+ * @Date: 2026-10-18 22:05:12
+ * @Last Modified: U2, 2026-10-18 22:05:12
+ */
+
+package backend
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+
+	"janusec/models"
+)
+
+// ErrUpstreamTLS is wrapped by the errors of TLS handshake with backends, distinct from dial errors
+var ErrUpstreamTLS = errors.New("upstream TLS handshake failed")
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// newUpstreamTLSConfig return the TLS config of connections to the backends of the application,
+// serverName is the Host of request, overridden by UpstreamTLS
+func newUpstreamTLSConfig(appID int64, serverName string) (*tls.Config, error) {
+	cfg := &tls.Config{
+		ServerName:         serverName,
+		NextProtos:         []string{"h2", "http/1.1"},
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: true,
+	}
+	app, err := GetApplicationByID(appID)
+	if err != nil || app.UpstreamTLS == nil {
+		return cfg, nil
+	}
+	upstreamTLS := app.UpstreamTLS
+	if len(upstreamTLS.ServerName) > 0 {
+		cfg.ServerName = upstreamTLS.ServerName
+	}
+	if version, ok := tlsVersions[upstreamTLS.MinVersion]; ok {
+		cfg.MinVersion = version
+	}
+	if upstreamTLS.Verify {
+		cfg.InsecureSkipVerify = false
+		if upstreamTLS.CACertID > 0 {
+			cfg.RootCAs, err = getCACertPool(upstreamTLS.CACertID)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if upstreamTLS.ClientCertID > 0 {
+		certItem, err := SysCallGetCertByID(upstreamTLS.ClientCertID)
+		if err != nil {
+			return nil, err
+		}
+		if len(certItem.TlsCert.Certificate) == 0 {
+			return nil, errors.New("client certificate without private key: " + certItem.CommonName)
+		}
+		cfg.Certificates = []tls.Certificate{certItem.TlsCert}
+	}
+	return cfg, nil
+}
+
+// IsUpstreamTLSError return true if the error is caused by TLS with backends, including the alerts
+// sent by backends after the handshake, such as the client certificate is rejected in TLS 1.3
+func IsUpstreamTLSError(err error) bool {
+	var alertErr tls.AlertError
+	return errors.Is(err, ErrUpstreamTLS) || errors.As(err, &alertErr)
+}
+
+// getCACertPool return the pool of CA certificates in the certificate item
+func getCACertPool(certID int64) (*x509.CertPool, error) {
+	certItem, err := SysCallGetCertByID(certID)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM([]byte(certItem.CertContent)) {
+		return nil, errors.New("no CA certificate in " + certItem.CommonName)
+	}
+	return pool, nil
+}
+
+// IsCACertItem return true if the certificate has no private key, used for verification only
+func IsCACertItem(certItem *models.CertItem) bool {
+	return len(certItem.PrivKeyContent) == 0
+}
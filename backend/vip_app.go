@@ -64,7 +64,7 @@ func ListenOnVIP(vipApp *models.VipApp) {
 	vipApp.ExitChan = make(chan bool)
 	address := ":" + strconv.FormatInt(vipApp.ListenPort, 10)
 	if vipApp.IsTCP {
-		vipListener, err := net.Listen("tcp", address)
+		vipListener, err := utils.Listen(address)
 		if err != nil {
 			utils.DebugPrintln("could not start server on port ", vipApp.ListenPort, err)
 		}
@@ -77,12 +77,7 @@ func ListenOnVIP(vipApp *models.VipApp) {
 		return
 	}
 	// UDP
-	udpAddr, err := net.ResolveUDPAddr("udp", address)
-	if err != nil {
-		utils.DebugPrintln("ResolveUDPAddr", address, err)
-		return
-	}
-	udpListenConn, err := net.ListenUDP("udp", udpAddr)
+	udpListenConn, err := utils.ListenUDP(address)
 	if err != nil {
 		utils.DebugPrintln("ListenOnVIP could not start udp port ", vipApp.ListenPort, err)
 	}
@@ -95,6 +90,17 @@ func ListenOnVIP(vipApp *models.VipApp) {
 	<-vipApp.ExitChan
 }
 
+// StopVipApps stop listening on the ports of port forwarding, used when shutting down
+func StopVipApps() {
+	for _, vipApp := range VipApps {
+		select {
+		case vipApp.ExitChan <- true:
+		default:
+			// not listening
+		}
+	}
+}
+
 // UDPForwarding with DialUDP
 func UDPForwarding(vipApp *models.VipApp, udpListenConn *net.UDPConn) {
 	for {
@@ -106,7 +112,7 @@ func UDPForwarding(vipApp *models.VipApp, udpListenConn *net.UDPConn) {
 		}
 		vipTarget := SelectVipTarget(vipApp, clientAddr.String())
 		if vipTarget != nil {
-			vipTarget.CheckTime = time.Now().Unix()
+			setVipTargetCheckTime(vipTarget, time.Now().Unix())
 			targetAddr, _ := net.ResolveUDPAddr("udp", vipTarget.Destination)
 			udpTargetConn, err := net.DialUDP("udp", nil, targetAddr)
 			if err != nil {
@@ -176,13 +182,23 @@ func TCPForwarding(vipApp *models.VipApp, vipListener net.Listener) {
 			}
 			// Reverse Proxy
 			target, err := net.Dial("tcp", targetDest)
-			vipTarget.CheckTime = time.Now().Unix()
 			if err != nil {
 				utils.DebugPrintln("TCPForwarding could not connect to target", targetDest, err)
+				setVipTargetCheckTime(vipTarget, time.Now().Unix())
 				SetVipTargetOffline(vipTarget)
 				continue
 			}
-			vipTarget.Online = true
+			setVipTargetOnline(vipTarget, time.Now().Unix())
+			if vipTarget.ProxyProtocol != utils.ProxyProtocol_NONE {
+				// v1.5.3 pass the client address to the target
+				err = utils.WriteProxyHeader(target, vipTarget.ProxyProtocol, remoteAddr, proxy.LocalAddr())
+				if err != nil {
+					utils.DebugPrintln("TCPForwarding WriteProxyHeader", targetDest, err)
+					proxy.Close()
+					target.Close()
+					continue
+				}
+			}
 			// Log to file
 			utils.VipAccessLog(vipApp.Name, remoteAddr.String(), proxy.LocalAddr().String(), targetDest)
 			// stream copy
@@ -204,7 +220,7 @@ func TCPForwarding(vipApp *models.VipApp, vipListener net.Listener) {
 func SelectVipTarget(vipApp *models.VipApp, srcIP string) *models.VipTarget {
 	var onlineTargets = []*models.VipTarget{}
 	for _, target := range vipApp.Targets {
-		if target.Online {
+		if isVipTargetOnline(target) {
 			onlineTargets = append(onlineTargets, target)
 		}
 	}
@@ -326,13 +342,14 @@ func UpdateTargets(vipApp *models.VipApp, targets []*models.VipTarget) {
 	for _, target := range targets {
 		// add new destinations to DB and app
 		var err error
+		healthCheck := data.MarshalHealthCheck(target.HealthCheck)
 		if target.ID == 0 {
-			target.ID, err = data.DAL.InsertVipTarget(vipApp.ID, int64(target.RouteType), target.Destination, target.PodsAPI, target.PodPort)
+			target.ID, err = data.DAL.InsertVipTarget(vipApp.ID, int64(target.RouteType), target.Destination, target.PodsAPI, target.PodPort, healthCheck, target.ProxyProtocol)
 			if err != nil {
 				utils.DebugPrintln("InsertVipTarget", err)
 			}
 		} else {
-			err = data.DAL.UpdateVipTarget(vipApp.ID, int64(target.RouteType), target.Destination, target.PodsAPI, target.PodPort, target.ID)
+			err = data.DAL.UpdateVipTarget(vipApp.ID, int64(target.RouteType), target.Destination, target.PodsAPI, target.PodPort, healthCheck, target.ProxyProtocol, target.ID)
 			if err != nil {
 				utils.DebugPrintln("UpdateVipTarget", err)
 			}
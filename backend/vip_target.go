@@ -29,7 +29,11 @@ func DeleteVipTargetsByAppID(id int64) {
 func CheckOfflineVipTargets(nowTimeStamp int64) {
 	for _, vipApp := range VipApps {
 		for _, target := range vipApp.Targets {
-			if !target.Online {
+			if vipApp.IsTCP && IsHealthCheckEnabled(target.HealthCheck) && target.RouteType != models.K8S_Ingress {
+				// checked by RoutineHealthCheckTick
+				continue
+			}
+			if !isVipTargetOnline(target) {
 				go func(vApp *models.VipApp, vTarget *models.VipTarget) {
 					var conn net.Conn
 					var err error
@@ -37,8 +41,7 @@ func CheckOfflineVipTargets(nowTimeStamp int64) {
 						conn, err = net.DialTimeout("tcp", vTarget.Destination, time.Second)
 						if err == nil {
 							defer conn.Close()
-							vTarget.Online = true
-							vTarget.CheckTime = nowTimeStamp
+							setVipTargetOnline(vTarget, nowTimeStamp)
 						}
 					} else {
 						targetAddr, _ := net.ResolveUDPAddr("udp", vTarget.Destination)
@@ -55,7 +58,7 @@ func CheckOfflineVipTargets(nowTimeStamp int64) {
 							if err != nil {
 								SetVipTargetOffline(vipTarget)
 							} else {
-								vipTarget.Online = true
+								setVipTargetOnline(vipTarget, time.Now().Unix())
 							}
 							udpConn.Close()
 						}(udpTargetConn, vTarget)
@@ -82,6 +85,28 @@ func ContainsTargetID(targets []*models.VipTarget, targetID int64) bool {
 	return false
 }
 
+// isVipTargetOnline read the online status under lock, v1.5.3
+func isVipTargetOnline(target *models.VipTarget) bool {
+	target.Mutex.RLock()
+	defer target.Mutex.RUnlock()
+	return target.Online
+}
+
+// setVipTargetOnline mark the target online after a successful connection, v1.5.3
+func setVipTargetOnline(target *models.VipTarget, checkTime int64) {
+	target.Mutex.Lock()
+	target.Online = true
+	target.CheckTime = checkTime
+	target.Mutex.Unlock()
+}
+
+// setVipTargetCheckTime record the last time the target was used, v1.5.3
+func setVipTargetCheckTime(target *models.VipTarget, checkTime int64) {
+	target.Mutex.Lock()
+	target.CheckTime = checkTime
+	target.Mutex.Unlock()
+}
+
 func SetVipTargetOffline(dest *models.VipTarget) {
 	target := dest.Destination
 	if dest.RouteType == models.K8S_Ingress {
@@ -93,7 +118,9 @@ func SetVipTargetOffline(dest *models.VipTarget) {
 		nowCount := count.(int64) + int64(1)
 		if nowCount > 5 {
 			// more than 5 requests timeout
+			dest.Mutex.Lock()
 			dest.Online = false
+			dest.Mutex.Unlock()
 			app, err := GetVipAppByID(dest.VipAppID)
 			if err == nil {
 				sendVIPOfflineNotification(app, target)
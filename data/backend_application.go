@@ -14,14 +14,14 @@ import (
 
 // CreateTableIfNotExistsApplications ...
 func (dal *MyDAL) CreateTableIfNotExistsApplications() error {
-	const sqlCreateTableIfNotExistsApplications = `CREATE TABLE IF NOT EXISTS "applications"("id" bigserial PRIMARY KEY,"name" VARCHAR(128) NOT NULL,"internal_scheme" VARCHAR(8) NOT NULL,"redirect_https" boolean,"hsts_enabled" boolean,"waf_enabled" boolean,"shield_enabled" boolean,"ip_method" bigint,"description" VARCHAR(256) NOT NULL,"oauth_required" boolean,"session_seconds" bigint default 7200,"owner" VARCHAR(128) NOT NULL,"csp_enabled" boolean default false,"csp" VARCHAR(1024) NOT NULL DEFAULT 'default-src ''self''',"cache_enabled" boolean default true,"custom_headers" VARCHAR(1024) DEFAULT '')`
+	const sqlCreateTableIfNotExistsApplications = `CREATE TABLE IF NOT EXISTS "applications"("id" bigserial PRIMARY KEY,"name" VARCHAR(128) NOT NULL,"internal_scheme" VARCHAR(8) NOT NULL,"redirect_https" boolean,"hsts_enabled" boolean,"waf_enabled" boolean,"shield_enabled" boolean,"ip_method" bigint,"description" VARCHAR(256) NOT NULL,"oauth_required" boolean,"session_seconds" bigint default 7200,"owner" VARCHAR(128) NOT NULL,"csp_enabled" boolean default false,"csp" VARCHAR(1024) NOT NULL DEFAULT 'default-src ''self''',"cache_enabled" boolean default true,"custom_headers" VARCHAR(1024) DEFAULT '',"retry_policy" VARCHAR(512) DEFAULT '',"traffic_splits" VARCHAR(4096) DEFAULT '',"header_rules" VARCHAR(4096) DEFAULT '',"forwarded_policy" bigint DEFAULT 0,"trusted_proxies" VARCHAR(1024) DEFAULT '',"request_limits" VARCHAR(512) DEFAULT '',"error_pages" VARCHAR(16384) DEFAULT '',"maintenance" VARCHAR(4096) DEFAULT '',"compression" VARCHAR(1024) DEFAULT '',"cache_policy" VARCHAR(4096) DEFAULT '',"upstream_tls" VARCHAR(1024) DEFAULT '',"client_auth" VARCHAR(512) DEFAULT '')`
 	_, err := dal.db.Exec(sqlCreateTableIfNotExistsApplications)
 	return err
 }
 
 // SelectApplications ...
 func (dal *MyDAL) SelectApplications() []*models.DBApplication {
-	const sqlSelectApplications = `SELECT "id","name","internal_scheme","redirect_https","hsts_enabled","waf_enabled","shield_enabled","ip_method","description","oauth_required","session_seconds","owner","csp_enabled","csp","cache_enabled","custom_headers","cookie_mgmt_enabled","concise_notice","necessary_notice","functional_notice","enable_functional","analytics_notice","enable_analytics","marketing_notice","enable_marketing","unclassified_notice","enable_unclassified" FROM "applications"`
+	const sqlSelectApplications = `SELECT "id","name","internal_scheme","redirect_https","hsts_enabled","waf_enabled","shield_enabled","ip_method","description","oauth_required","session_seconds","owner","csp_enabled","csp","cache_enabled","custom_headers","cookie_mgmt_enabled","concise_notice","necessary_notice","functional_notice","enable_functional","analytics_notice","enable_analytics","marketing_notice","enable_marketing","unclassified_notice","enable_unclassified","retry_policy","traffic_splits","header_rules","forwarded_policy","trusted_proxies","request_limits","error_pages","maintenance","compression","cache_policy","upstream_tls","client_auth" FROM "applications"`
 	rows, err := dal.db.Query(sqlSelectApplications)
 	if err != nil {
 		utils.DebugPrintln("SelectApplications", err)
@@ -59,6 +59,18 @@ func (dal *MyDAL) SelectApplications() []*models.DBApplication {
 			&dbApp.EnableMarketing,
 			&dbApp.UnclassifiedNotice,
 			&dbApp.EnableUnclassified,
+			&dbApp.RetryPolicy,
+			&dbApp.TrafficSplits,
+			&dbApp.HeaderRules,
+			&dbApp.ForwardedPolicy,
+			&dbApp.TrustedProxies,
+			&dbApp.RequestLimits,
+			&dbApp.ErrorPages,
+			&dbApp.Maintenance,
+			&dbApp.Compression,
+			&dbApp.CachePolicy,
+			&dbApp.UpstreamTLS,
+			&dbApp.ClientAuth,
 		)
 		if err != nil {
 			utils.DebugPrintln("SelectApplications rows.Scan", err)
@@ -69,10 +81,10 @@ func (dal *MyDAL) SelectApplications() []*models.DBApplication {
 }
 
 // InsertApplication insert an Application to DB
-func (dal *MyDAL) InsertApplication(appName string, internalScheme string, redirectHTTPS bool, hstsEnabled bool, wafEnabled bool, shieldEnabled bool, ipMethod models.IPMethod, description string, oauthRequired bool, sessionSeconds int64, owner string, cspEnabled bool, csp string, cacheEnabled bool, customHeaders string, cookieMgmtEnabled bool, conciseNotice string, necessaryNotice string, functionalNotice string, enableFunctional bool, analyticsNotice string, enableAnalytics bool, marketingNotice string, enableMarketing bool, unclassifiedNotice string, enableUnclassified bool) (newID int64) {
-	const sqlInsertApplication = `INSERT INTO "applications"("id","name","internal_scheme","redirect_https","hsts_enabled","waf_enabled","shield_enabled","ip_method","description","oauth_required","session_seconds","owner","csp_enabled","csp","cache_enabled","custom_headers","cookie_mgmt_enabled","concise_notice","necessary_notice","functional_notice","enable_functional","analytics_notice","enable_analytics","marketing_notice","enable_marketing","unclassified_notice","enable_unclassified") VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19,$20,$21,$22,$23,$24,$25,$26,$27) RETURNING "id"`
+func (dal *MyDAL) InsertApplication(appName string, internalScheme string, redirectHTTPS bool, hstsEnabled bool, wafEnabled bool, shieldEnabled bool, ipMethod models.IPMethod, description string, oauthRequired bool, sessionSeconds int64, owner string, cspEnabled bool, csp string, cacheEnabled bool, customHeaders string, cookieMgmtEnabled bool, conciseNotice string, necessaryNotice string, functionalNotice string, enableFunctional bool, analyticsNotice string, enableAnalytics bool, marketingNotice string, enableMarketing bool, unclassifiedNotice string, enableUnclassified bool, retryPolicy string, trafficSplits string, headerRules string, forwardedPolicy models.ForwardedPolicy, trustedProxies string, requestLimits string, errorPages string, maintenance string, compression string, cachePolicy string, upstreamTLS string, clientAuth string) (newID int64) {
+	const sqlInsertApplication = `INSERT INTO "applications"("id","name","internal_scheme","redirect_https","hsts_enabled","waf_enabled","shield_enabled","ip_method","description","oauth_required","session_seconds","owner","csp_enabled","csp","cache_enabled","custom_headers","cookie_mgmt_enabled","concise_notice","necessary_notice","functional_notice","enable_functional","analytics_notice","enable_analytics","marketing_notice","enable_marketing","unclassified_notice","enable_unclassified","retry_policy","traffic_splits","header_rules","forwarded_policy","trusted_proxies","request_limits","error_pages","maintenance","compression","cache_policy","upstream_tls","client_auth") VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19,$20,$21,$22,$23,$24,$25,$26,$27,$28,$29,$30,$31,$32,$33,$34,$35,$36,$37,$38,$39) RETURNING "id"`
 	id := utils.GenSnowflakeID()
-	err := dal.db.QueryRow(sqlInsertApplication, id, appName, internalScheme, redirectHTTPS, hstsEnabled, wafEnabled, shieldEnabled, ipMethod, description, oauthRequired, sessionSeconds, owner, cspEnabled, csp, cacheEnabled, customHeaders, cookieMgmtEnabled, conciseNotice, necessaryNotice, functionalNotice, enableFunctional, analyticsNotice, enableAnalytics, marketingNotice, enableMarketing, unclassifiedNotice, enableUnclassified).Scan(&newID)
+	err := dal.db.QueryRow(sqlInsertApplication, id, appName, internalScheme, redirectHTTPS, hstsEnabled, wafEnabled, shieldEnabled, ipMethod, description, oauthRequired, sessionSeconds, owner, cspEnabled, csp, cacheEnabled, customHeaders, cookieMgmtEnabled, conciseNotice, necessaryNotice, functionalNotice, enableFunctional, analyticsNotice, enableAnalytics, marketingNotice, enableMarketing, unclassifiedNotice, enableUnclassified, retryPolicy, trafficSplits, headerRules, forwardedPolicy, trustedProxies, requestLimits, errorPages, maintenance, compression, cachePolicy, upstreamTLS, clientAuth).Scan(&newID)
 	if err != nil {
 		utils.DebugPrintln("InsertApplication", err)
 	}
@@ -80,17 +92,29 @@ func (dal *MyDAL) InsertApplication(appName string, internalScheme string, redir
 }
 
 // UpdateApplication update an Application
-func (dal *MyDAL) UpdateApplication(appName string, internalScheme string, redirectHTTPS bool, hstsEnabled bool, wafEnabled bool, shieldEnabled bool, ipMethod models.IPMethod, description string, oauthRequired bool, sessionSeconds int64, owner string, cspEnabled bool, csp string, cacheEnabled bool, customHeaders string, cookieMgmtEnabled bool, conciseNotice string, necessaryNotice string, functionalNotice string, enableFunctional bool, analyticsNotice string, enableAnalytics bool, marketingNotice string, enableMarketing bool, unclassifiedNotice string, enableUnclassified bool, appID int64) error {
-	const sqlUpdateApplication = `UPDATE "applications" SET "name"=$1,"internal_scheme"=$2,"redirect_https"=$3,"hsts_enabled"=$4,"waf_enabled"=$5,"shield_enabled"=$6,"ip_method"=$7,"description"=$8,"oauth_required"=$9,"session_seconds"=$10,"owner"=$11,"csp_enabled"=$12,"csp"=$13,"cache_enabled"=$14,"custom_headers"=$15,"cookie_mgmt_enabled"=$16,"concise_notice"=$17,"necessary_notice"=$18,"functional_notice"=$19,"enable_functional"=$20,"analytics_notice"=$21,"enable_analytics"=$22,"marketing_notice"=$23,"enable_marketing"=$24,"unclassified_notice"=$25,"enable_unclassified"=$26 WHERE "id"=$27`
+func (dal *MyDAL) UpdateApplication(appName string, internalScheme string, redirectHTTPS bool, hstsEnabled bool, wafEnabled bool, shieldEnabled bool, ipMethod models.IPMethod, description string, oauthRequired bool, sessionSeconds int64, owner string, cspEnabled bool, csp string, cacheEnabled bool, customHeaders string, cookieMgmtEnabled bool, conciseNotice string, necessaryNotice string, functionalNotice string, enableFunctional bool, analyticsNotice string, enableAnalytics bool, marketingNotice string, enableMarketing bool, unclassifiedNotice string, enableUnclassified bool, retryPolicy string, trafficSplits string, headerRules string, forwardedPolicy models.ForwardedPolicy, trustedProxies string, requestLimits string, errorPages string, maintenance string, compression string, cachePolicy string, upstreamTLS string, clientAuth string, appID int64) error {
+	const sqlUpdateApplication = `UPDATE "applications" SET "name"=$1,"internal_scheme"=$2,"redirect_https"=$3,"hsts_enabled"=$4,"waf_enabled"=$5,"shield_enabled"=$6,"ip_method"=$7,"description"=$8,"oauth_required"=$9,"session_seconds"=$10,"owner"=$11,"csp_enabled"=$12,"csp"=$13,"cache_enabled"=$14,"custom_headers"=$15,"cookie_mgmt_enabled"=$16,"concise_notice"=$17,"necessary_notice"=$18,"functional_notice"=$19,"enable_functional"=$20,"analytics_notice"=$21,"enable_analytics"=$22,"marketing_notice"=$23,"enable_marketing"=$24,"unclassified_notice"=$25,"enable_unclassified"=$26,"retry_policy"=$27,"traffic_splits"=$28,"header_rules"=$29,"forwarded_policy"=$30,"trusted_proxies"=$31,"request_limits"=$32,"error_pages"=$33,"maintenance"=$34,"compression"=$35,"cache_policy"=$36,"upstream_tls"=$37,"client_auth"=$38 WHERE "id"=$39`
 	stmt, _ := dal.db.Prepare(sqlUpdateApplication)
 	defer stmt.Close()
-	_, err := stmt.Exec(appName, internalScheme, redirectHTTPS, hstsEnabled, wafEnabled, shieldEnabled, ipMethod, description, oauthRequired, sessionSeconds, owner, cspEnabled, csp, cacheEnabled, customHeaders, cookieMgmtEnabled, conciseNotice, necessaryNotice, functionalNotice, enableFunctional, analyticsNotice, enableAnalytics, marketingNotice, enableMarketing, unclassifiedNotice, enableUnclassified, appID)
+	_, err := stmt.Exec(appName, internalScheme, redirectHTTPS, hstsEnabled, wafEnabled, shieldEnabled, ipMethod, description, oauthRequired, sessionSeconds, owner, cspEnabled, csp, cacheEnabled, customHeaders, cookieMgmtEnabled, conciseNotice, necessaryNotice, functionalNotice, enableFunctional, analyticsNotice, enableAnalytics, marketingNotice, enableMarketing, unclassifiedNotice, enableUnclassified, retryPolicy, trafficSplits, headerRules, forwardedPolicy, trustedProxies, requestLimits, errorPages, maintenance, compression, cachePolicy, upstreamTLS, clientAuth, appID)
 	if err != nil {
 		utils.DebugPrintln("UpdateApplication", err)
 	}
 	return err
 }
 
+// UpdateApplicationMaintenance update the maintenance mode of an Application, v1.5.3
+func (dal *MyDAL) UpdateApplicationMaintenance(maintenance string, appID int64) error {
+	const sqlUpdateApplicationMaintenance = `UPDATE "applications" SET "maintenance"=$1 WHERE "id"=$2`
+	stmt, _ := dal.db.Prepare(sqlUpdateApplicationMaintenance)
+	defer stmt.Close()
+	_, err := stmt.Exec(maintenance, appID)
+	if err != nil {
+		utils.DebugPrintln("UpdateApplicationMaintenance", err)
+	}
+	return err
+}
+
 // DeleteApplication delete an Application
 func (dal *MyDAL) DeleteApplication(appID int64) error {
 	const sqlDeleteApplication = `DELETE FROM "applications" WHERE "id"=$1`
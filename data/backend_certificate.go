@@ -16,10 +16,10 @@ import (
 )
 
 const (
-	sqlCreateTableIfNotExistsCertificates = `CREATE TABLE IF NOT EXISTS "certificates"("id" bigserial primary key,"common_name" VARCHAR(256) not null,"pub_cert" VARCHAR(16384) not null,"priv_key" bytea not null,"expire_time" bigint,"description" VARCHAR(256))`
-	sqlSelectCertificates                 = `SELECT "id","common_name","pub_cert","priv_key","expire_time","description" FROM "certificates"`
-	sqlInsertCertificate                  = `INSERT INTO "certificates"("id","common_name","pub_cert","priv_key","expire_time","description") VALUES($1,$2,$3,$4,$5,$6) RETURNING "id"`
-	sqlUpdateCertificate                  = `UPDATE "certificates" SET "common_name"=$1,"pub_cert"=$2,"priv_key"=$3,"expire_time"=$4,"description"=$5 WHERE "id"=$6`
+	sqlCreateTableIfNotExistsCertificates = `CREATE TABLE IF NOT EXISTS "certificates"("id" bigserial primary key,"common_name" VARCHAR(256) not null,"pub_cert" VARCHAR(16384) not null,"priv_key" bytea not null,"expire_time" bigint,"description" VARCHAR(256),"acme_domains" VARCHAR(1024) DEFAULT '')`
+	sqlSelectCertificates                 = `SELECT "id","common_name","pub_cert","priv_key","expire_time","description","acme_domains" FROM "certificates"`
+	sqlInsertCertificate                  = `INSERT INTO "certificates"("id","common_name","pub_cert","priv_key","expire_time","description","acme_domains") VALUES($1,$2,$3,$4,$5,$6,$7) RETURNING "id"`
+	sqlUpdateCertificate                  = `UPDATE "certificates" SET "common_name"=$1,"pub_cert"=$2,"priv_key"=$3,"expire_time"=$4,"description"=$5,"acme_domains"=$6 WHERE "id"=$7`
 	sqlDeleteCertificate                  = `DELETE FROM "certificates" WHERE "id"=$1`
 )
 
@@ -41,16 +41,16 @@ func (dal *MyDAL) SelectCertificates() []*models.DBCertItem {
 		dbCert := &models.DBCertItem{}
 		_ = rows.Scan(&dbCert.ID, &dbCert.CommonName,
 			&dbCert.CertContent, &dbCert.EncryptedPrivKey,
-			&dbCert.ExpireTime, &dbCert.Description)
+			&dbCert.ExpireTime, &dbCert.Description, &dbCert.ACMEDomains)
 		dbCerts = append(dbCerts, dbCert)
 	}
 	return dbCerts
 }
 
 // InsertCertificate ...
-func (dal *MyDAL) InsertCertificate(commonName string, certContent string, encryptedPrivKey []byte, expireTime int64, description string) (newID int64) {
+func (dal *MyDAL) InsertCertificate(commonName string, certContent string, encryptedPrivKey []byte, expireTime int64, description string, acmeDomains string) (newID int64) {
 	id := utils.GenSnowflakeID()
-	err := dal.db.QueryRow(sqlInsertCertificate, id, commonName, certContent, encryptedPrivKey, expireTime, description).Scan(&newID)
+	err := dal.db.QueryRow(sqlInsertCertificate, id, commonName, certContent, encryptedPrivKey, expireTime, description, acmeDomains).Scan(&newID)
 	if err != nil {
 		utils.DebugPrintln("InsertCertificate", err)
 	}
@@ -58,10 +58,10 @@ func (dal *MyDAL) InsertCertificate(commonName string, certContent string, encry
 }
 
 // UpdateCertificate ...
-func (dal *MyDAL) UpdateCertificate(commonName string, certContent string, encryptedPrivKey []byte, expireTime int64, description string, id int64) error {
+func (dal *MyDAL) UpdateCertificate(commonName string, certContent string, encryptedPrivKey []byte, expireTime int64, description string, acmeDomains string, id int64) error {
 	stmt, _ := dal.db.Prepare(sqlUpdateCertificate)
 	defer stmt.Close()
-	_, err := stmt.Exec(commonName, certContent, encryptedPrivKey, expireTime, description, id)
+	_, err := stmt.Exec(commonName, certContent, encryptedPrivKey, expireTime, description, acmeDomains, id)
 	if err != nil {
 		utils.DebugPrintln("UpdateCertificate", err)
 	}
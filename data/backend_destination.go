@@ -8,16 +8,18 @@
 package data
 
 import (
+	"encoding/json"
+
 	"janusec/models"
 	"janusec/utils"
 )
 
 // UpdateDestinationNode ...
-func (dal *MyDAL) UpdateDestinationNode(routeType int64, requestRoute string, backendRoute string, destination string, podsAPI string, podPort string, appID int64, nodeID int64, id int64) error {
-	const sqlUpdateDestinationNode = `UPDATE "destinations" SET "route_type"=$1,"request_route"=$2,"backend_route"=$3,"destination"=$4,"pods_api"=$5,"pod_port"=$6,"app_id"=$7,"node_id"=$8 WHERE "id"=$9`
+func (dal *MyDAL) UpdateDestinationNode(routeType int64, requestRoute string, backendRoute string, destination string, podsAPI string, podPort string, appID int64, nodeID int64, lbMethod int64, weight int64, hashKey string, healthCheck string, group string, mirrorTarget string, mirrorPercent int64, routeMatch int64, methods string, headerMatch string, priority int64, proxyProtocol int64, id int64) error {
+	const sqlUpdateDestinationNode = `UPDATE "destinations" SET "route_type"=$1,"request_route"=$2,"backend_route"=$3,"destination"=$4,"pods_api"=$5,"pod_port"=$6,"app_id"=$7,"node_id"=$8,"lb_method"=$9,"weight"=$10,"hash_key"=$11,"health_check"=$12,"dest_group"=$13,"mirror_target"=$14,"mirror_percent"=$15,"route_match"=$16,"methods"=$17,"header_match"=$18,"priority"=$19,"proxy_protocol"=$20 WHERE "id"=$21`
 	stmt, _ := dal.db.Prepare(sqlUpdateDestinationNode)
 	defer stmt.Close()
-	_, err := stmt.Exec(routeType, requestRoute, backendRoute, destination, podsAPI, podPort, appID, nodeID, id)
+	_, err := stmt.Exec(routeType, requestRoute, backendRoute, destination, podsAPI, podPort, appID, nodeID, lbMethod, weight, hashKey, healthCheck, group, mirrorTarget, mirrorPercent, routeMatch, methods, headerMatch, priority, proxyProtocol, id)
 	if err != nil {
 		utils.DebugPrintln("UpdateDestinationNode", err)
 	}
@@ -37,7 +39,7 @@ func (dal *MyDAL) ExistsDestinationID(id int64) bool {
 
 // CreateTableIfNotExistsDestinations ...
 func (dal *MyDAL) CreateTableIfNotExistsDestinations() error {
-	const sqlCreateTableIfNotExistsDestinations = `CREATE TABLE IF NOT EXISTS "destinations"("id" bigserial PRIMARY KEY,"route_type" bigint default 1,"request_route" VARCHAR(128) NOT NULL DEFAULT '/',"backend_route" VARCHAR(128) NOT NULL DEFAULT '/',"destination" VARCHAR(128) DEFAULT '',"pods_api" VARCHAR(512) DEFAULT '',"pod_port" VARCHAR(128) DEFAULT '',"pods" VARCHAR(1024) DEFAULT '',"app_id" bigint NOT NULL,"node_id" bigint NOT NULL)`
+	const sqlCreateTableIfNotExistsDestinations = `CREATE TABLE IF NOT EXISTS "destinations"("id" bigserial PRIMARY KEY,"route_type" bigint default 1,"request_route" VARCHAR(128) NOT NULL DEFAULT '/',"backend_route" VARCHAR(128) NOT NULL DEFAULT '/',"destination" VARCHAR(128) DEFAULT '',"pods_api" VARCHAR(512) DEFAULT '',"pod_port" VARCHAR(128) DEFAULT '',"pods" VARCHAR(1024) DEFAULT '',"app_id" bigint NOT NULL,"node_id" bigint NOT NULL,"lb_method" bigint default 0,"weight" bigint default 1,"hash_key" VARCHAR(128) DEFAULT '',"health_check" VARCHAR(1024) DEFAULT '',"dest_group" VARCHAR(128) DEFAULT '',"mirror_target" VARCHAR(128) DEFAULT '',"mirror_percent" bigint DEFAULT 0,"route_match" bigint DEFAULT 0,"methods" VARCHAR(128) DEFAULT '',"header_match" VARCHAR(256) DEFAULT '',"priority" bigint DEFAULT 0,"proxy_protocol" bigint DEFAULT 0)`
 	_, err := dal.db.Exec(sqlCreateTableIfNotExistsDestinations)
 	if err != nil {
 		utils.DebugPrintln("CreateTableIfNotExistsDestinations", err)
@@ -48,7 +50,7 @@ func (dal *MyDAL) CreateTableIfNotExistsDestinations() error {
 // SelectDestinationsByAppID ...
 func (dal *MyDAL) SelectDestinationsByAppID(appID int64) []*models.Destination {
 	dests := []*models.Destination{}
-	const sqlSelectDestinationsByAppID = `SELECT "id","route_type","request_route","backend_route","destination","pods_api","pod_port","node_id" FROM "destinations" WHERE "app_id"=$1`
+	const sqlSelectDestinationsByAppID = `SELECT "id","route_type","request_route","backend_route","destination","pods_api","pod_port","node_id","lb_method","weight","hash_key","health_check","dest_group","mirror_target","mirror_percent","route_match","methods","header_match","priority","proxy_protocol" FROM "destinations" WHERE "app_id"=$1`
 	rows, err := dal.db.Query(sqlSelectDestinationsByAppID, appID)
 	if err != nil {
 		utils.DebugPrintln("SelectDestinationsByAppID", err)
@@ -57,20 +59,22 @@ func (dal *MyDAL) SelectDestinationsByAppID(appID int64) []*models.Destination {
 	defer rows.Close()
 	for rows.Next() {
 		dest := &models.Destination{AppID: appID, Online: true}
-		err = rows.Scan(&dest.ID, &dest.RouteType, &dest.RequestRoute, &dest.BackendRoute, &dest.Destination, &dest.PodsAPI, &dest.PodPort, &dest.NodeID)
+		var healthCheck string
+		err = rows.Scan(&dest.ID, &dest.RouteType, &dest.RequestRoute, &dest.BackendRoute, &dest.Destination, &dest.PodsAPI, &dest.PodPort, &dest.NodeID, &dest.LBMethod, &dest.Weight, &dest.HashKey, &healthCheck, &dest.Group, &dest.MirrorTarget, &dest.MirrorPercent, &dest.RouteMatch, &dest.Methods, &dest.HeaderMatch, &dest.Priority, &dest.ProxyProtocol)
 		if err != nil {
 			utils.DebugPrintln("SelectDestinationsByAppID rows.Scan", err)
 		}
+		dest.HealthCheck = UnmarshalHealthCheck(healthCheck)
 		dests = append(dests, dest)
 	}
 	return dests
 }
 
 // InsertDestination ...
-func (dal *MyDAL) InsertDestination(routeType int64, requestRoute string, backendRoute string, dest string, podsAPI string, podPort string, appID int64, nodeID int64) (newID int64, err error) {
-	const sqlInsertDestination = `INSERT INTO "destinations"("id","route_type","request_route","backend_route","destination","pods_api","pod_port","app_id","node_id") VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9) RETURNING "id"`
+func (dal *MyDAL) InsertDestination(routeType int64, requestRoute string, backendRoute string, dest string, podsAPI string, podPort string, appID int64, nodeID int64, lbMethod int64, weight int64, hashKey string, healthCheck string, group string, mirrorTarget string, mirrorPercent int64, routeMatch int64, methods string, headerMatch string, priority int64, proxyProtocol int64) (newID int64, err error) {
+	const sqlInsertDestination = `INSERT INTO "destinations"("id","route_type","request_route","backend_route","destination","pods_api","pod_port","app_id","node_id","lb_method","weight","hash_key","health_check","dest_group","mirror_target","mirror_percent","route_match","methods","header_match","priority","proxy_protocol") VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19,$20,$21) RETURNING "id"`
 	id := utils.GenSnowflakeID()
-	err = dal.db.QueryRow(sqlInsertDestination, id, routeType, requestRoute, backendRoute, dest, podsAPI, podPort, appID, nodeID).Scan(&newID)
+	err = dal.db.QueryRow(sqlInsertDestination, id, routeType, requestRoute, backendRoute, dest, podsAPI, podPort, appID, nodeID, lbMethod, weight, hashKey, healthCheck, group, mirrorTarget, mirrorPercent, routeMatch, methods, headerMatch, priority, proxyProtocol).Scan(&newID)
 	if err != nil {
 		utils.DebugPrintln("InsertDestination", err)
 	}
@@ -100,3 +104,30 @@ func (dal *MyDAL) DeleteDestinationsByAppID(appID int64) error {
 	}
 	return err
 }
+
+// UnmarshalHealthCheck convert JSON string to HealthCheck, return nil if empty
+func UnmarshalHealthCheck(healthCheckStr string) *models.HealthCheck {
+	if len(healthCheckStr) == 0 {
+		return nil
+	}
+	healthCheck := &models.HealthCheck{}
+	err := json.Unmarshal([]byte(healthCheckStr), healthCheck)
+	if err != nil {
+		utils.DebugPrintln("UnmarshalHealthCheck", err)
+		return nil
+	}
+	return healthCheck
+}
+
+// MarshalHealthCheck convert HealthCheck to JSON string, return empty string if nil
+func MarshalHealthCheck(healthCheck *models.HealthCheck) string {
+	if healthCheck == nil {
+		return ""
+	}
+	healthCheckBytes, err := json.Marshal(healthCheck)
+	if err != nil {
+		utils.DebugPrintln("MarshalHealthCheck", err)
+		return ""
+	}
+	return string(healthCheckBytes)
+}
@@ -13,11 +13,11 @@ import (
 )
 
 const (
-	sqlCreateTableIfNotExistsDomains = `CREATE TABLE IF NOT EXISTS "domains"("id" bigserial PRIMARY KEY, "name" VARCHAR(256) NOT NULL, "app_id" bigint NOT NULL, "cert_id" bigint, "redirect" boolean, "location" VARCHAR(256))`
+	sqlCreateTableIfNotExistsDomains = `CREATE TABLE IF NOT EXISTS "domains"("id" bigserial PRIMARY KEY, "name" VARCHAR(256) NOT NULL, "app_id" bigint NOT NULL, "cert_id" bigint, "redirect" boolean, "location" VARCHAR(256), "tls_profile" VARCHAR(2048) DEFAULT '', "extra_cert_ids" VARCHAR(256) DEFAULT '')`
 	sqlSelectDomainsCountByCertID    = `SELECT COUNT(1) FROM "domains" WHERE "cert_id"=$1`
-	sqlSelectDomains                 = `SELECT "id", "name", "app_id", "cert_id", "redirect", "location" FROM "domains"`
-	sqlInsertDomain                  = `INSERT INTO "domains"("id","name", "app_id", "cert_id", "redirect", "location") VALUES($1,$2,$3,$4,$5,$6) RETURNING "id"`
-	sqlUpdateDomain                  = `UPDATE "domains" SET "name"=$1,"app_id"=$2,"cert_id"=$3,"redirect"=$4,"location"=$5 WHERE "id"=$6`
+	sqlSelectDomains                 = `SELECT "id", "name", "app_id", "cert_id", "redirect", "location", "tls_profile", "extra_cert_ids" FROM "domains"`
+	sqlInsertDomain                  = `INSERT INTO "domains"("id","name", "app_id", "cert_id", "redirect", "location", "tls_profile", "extra_cert_ids") VALUES($1,$2,$3,$4,$5,$6,$7,$8) RETURNING "id"`
+	sqlUpdateDomain                  = `UPDATE "domains" SET "name"=$1,"app_id"=$2,"cert_id"=$3,"redirect"=$4,"location"=$5,"tls_profile"=$6,"extra_cert_ids"=$7 WHERE "id"=$8`
 	sqlDeleteDomainByDomainID        = `DELETE FROM "domains" WHERE "id"=$1`
 	sqlDeleteDomainByAppID           = `DELETE FROM "domains" WHERE "app_id"=$1`
 )
@@ -38,7 +38,7 @@ func (dal *MyDAL) SelectDomains() []*models.DBDomain {
 	dbDomains := []*models.DBDomain{}
 	for rows.Next() {
 		dbDomain := &models.DBDomain{}
-		_ = rows.Scan(&dbDomain.ID, &dbDomain.Name, &dbDomain.AppID, &dbDomain.CertID, &dbDomain.Redirect, &dbDomain.Location)
+		_ = rows.Scan(&dbDomain.ID, &dbDomain.Name, &dbDomain.AppID, &dbDomain.CertID, &dbDomain.Redirect, &dbDomain.Location, &dbDomain.TLSProfile, &dbDomain.ExtraCertIDs)
 		dbDomains = append(dbDomains, dbDomain)
 	}
 	return dbDomains
@@ -55,9 +55,9 @@ func (dal *MyDAL) SelectDomainsCountByCertID(certID int64) int64 {
 }
 
 // InsertDomain ...
-func (dal *MyDAL) InsertDomain(name string, appID int64, certID int64, redirect bool, location string) (newID int64) {
+func (dal *MyDAL) InsertDomain(name string, appID int64, certID int64, redirect bool, location string, tlsProfile string, extraCertIDs string) (newID int64) {
 	id := utils.GenSnowflakeID()
-	err := dal.db.QueryRow(sqlInsertDomain, id, name, appID, certID, redirect, location).Scan(&newID)
+	err := dal.db.QueryRow(sqlInsertDomain, id, name, appID, certID, redirect, location, tlsProfile, extraCertIDs).Scan(&newID)
 	if err != nil {
 		utils.DebugPrintln("InsertDomain", err)
 	}
@@ -65,8 +65,8 @@ func (dal *MyDAL) InsertDomain(name string, appID int64, certID int64, redirect
 }
 
 // UpdateDomain ...
-func (dal *MyDAL) UpdateDomain(name string, appID int64, certID int64, redirect bool, location string, domainID int64) error {
-	_, err := dal.db.Exec(sqlUpdateDomain, name, appID, certID, redirect, location, domainID)
+func (dal *MyDAL) UpdateDomain(name string, appID int64, certID int64, redirect bool, location string, tlsProfile string, extraCertIDs string, domainID int64) error {
+	_, err := dal.db.Exec(sqlUpdateDomain, name, appID, certID, redirect, location, tlsProfile, extraCertIDs, domainID)
 	if err != nil {
 		utils.DebugPrintln("UpdateDomain", err)
 	}
@@ -0,0 +1,78 @@
+/*
+ * @Copyright Reserved By Janusec (https://www.janusec.com/).
+ * @Author: U2
+ * @Date: 2026-10-18 19:10:41
+ * @Last Modified: U2, 2026-10-18 19:10:41
+ */
+
+package data
+
+import (
+	"janusec/models"
+	"janusec/utils"
+)
+
+// CreateTableIfNotExistsRewriteRules ...
+func (dal *MyDAL) CreateTableIfNotExistsRewriteRules() error {
+	const sqlCreateTableIfNotExistsRewriteRules = `CREATE TABLE IF NOT EXISTS "rewrite_rules"("id" bigserial PRIMARY KEY,"app_id" bigint NOT NULL,"sequence" bigint DEFAULT 0,"target" VARCHAR(16) DEFAULT 'path',"regex" VARCHAR(512) NOT NULL,"action" bigint DEFAULT 1,"replacement" VARCHAR(2048) DEFAULT '',"status_code" bigint DEFAULT 0,"header_name" VARCHAR(128) DEFAULT '',"header_regex" VARCHAR(512) DEFAULT '',"ip_list" VARCHAR(1024) DEFAULT '',"last" boolean DEFAULT false,"enabled" boolean DEFAULT true,"description" VARCHAR(256) DEFAULT '')`
+	_, err := dal.db.Exec(sqlCreateTableIfNotExistsRewriteRules)
+	return err
+}
+
+// SelectRewriteRulesByAppID ...
+func (dal *MyDAL) SelectRewriteRulesByAppID(appID int64) []*models.RewriteRule {
+	rules := []*models.RewriteRule{}
+	const sqlSelectRewriteRules = `SELECT "id","sequence","target","regex","action","replacement","status_code","header_name","header_regex","ip_list","last","enabled","description" FROM "rewrite_rules" WHERE "app_id"=$1 ORDER BY "sequence","id"`
+	rows, err := dal.db.Query(sqlSelectRewriteRules, appID)
+	if err != nil {
+		utils.DebugPrintln("SelectRewriteRulesByAppID", err)
+		return rules
+	}
+	defer rows.Close()
+	for rows.Next() {
+		rule := &models.RewriteRule{AppID: appID}
+		err = rows.Scan(&rule.ID, &rule.Sequence, &rule.Target, &rule.Regex, &rule.Action, &rule.Replacement, &rule.StatusCode, &rule.HeaderName, &rule.HeaderRegex, &rule.IPList, &rule.Last, &rule.Enabled, &rule.Description)
+		if err != nil {
+			utils.DebugPrintln("SelectRewriteRulesByAppID rows.Scan", err)
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// SelectRewriteRuleByID ...
+func (dal *MyDAL) SelectRewriteRuleByID(id int64) (*models.RewriteRule, error) {
+	rule := &models.RewriteRule{ID: id}
+	const sqlSelectRewriteRule = `SELECT "app_id","sequence","target","regex","action","replacement","status_code","header_name","header_regex","ip_list","last","enabled","description" FROM "rewrite_rules" WHERE "id"=$1`
+	err := dal.db.QueryRow(sqlSelectRewriteRule, id).Scan(&rule.AppID, &rule.Sequence, &rule.Target, &rule.Regex, &rule.Action, &rule.Replacement, &rule.StatusCode, &rule.HeaderName, &rule.HeaderRegex, &rule.IPList, &rule.Last, &rule.Enabled, &rule.Description)
+	return rule, err
+}
+
+// InsertRewriteRule ...
+func (dal *MyDAL) InsertRewriteRule(rule *models.RewriteRule) error {
+	const sqlInsertRewriteRule = `INSERT INTO "rewrite_rules"("id","app_id","sequence","target","regex","action","replacement","status_code","header_name","header_regex","ip_list","last","enabled","description") VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14)`
+	_, err := dal.db.Exec(sqlInsertRewriteRule, rule.ID, rule.AppID, rule.Sequence, rule.Target, rule.Regex, rule.Action, rule.Replacement, rule.StatusCode, rule.HeaderName, rule.HeaderRegex, rule.IPList, rule.Last, rule.Enabled, rule.Description)
+	return err
+}
+
+// UpdateRewriteRule ...
+func (dal *MyDAL) UpdateRewriteRule(rule *models.RewriteRule) error {
+	const sqlUpdateRewriteRule = `UPDATE "rewrite_rules" SET "sequence"=$1,"target"=$2,"regex"=$3,"action"=$4,"replacement"=$5,"status_code"=$6,"header_name"=$7,"header_regex"=$8,"ip_list"=$9,"last"=$10,"enabled"=$11,"description"=$12 WHERE "id"=$13`
+	_, err := dal.db.Exec(sqlUpdateRewriteRule, rule.Sequence, rule.Target, rule.Regex, rule.Action, rule.Replacement, rule.StatusCode, rule.HeaderName, rule.HeaderRegex, rule.IPList, rule.Last, rule.Enabled, rule.Description, rule.ID)
+	return err
+}
+
+// DeleteRewriteRuleByID ...
+func (dal *MyDAL) DeleteRewriteRuleByID(id int64) error {
+	const sqlDeleteRewriteRule = `DELETE FROM "rewrite_rules" WHERE "id"=$1`
+	_, err := dal.db.Exec(sqlDeleteRewriteRule, id)
+	return err
+}
+
+// DeleteRewriteRulesByAppID ...
+func (dal *MyDAL) DeleteRewriteRulesByAppID(appID int64) error {
+	const sqlDeleteRewriteRules = `DELETE FROM "rewrite_rules" WHERE "app_id"=$1`
+	_, err := dal.db.Exec(sqlDeleteRewriteRules, appID)
+	return err
+}
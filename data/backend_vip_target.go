@@ -14,7 +14,7 @@ import (
 
 // CreateTableIfNotExistsVipTargets create vip_targets
 func (dal *MyDAL) CreateTableIfNotExistsVipTargets() error {
-	const sqlCreateTableIfNotExistsVipTargets = `CREATE TABLE IF NOT EXISTS "vip_targets"("id" bigserial PRIMARY KEY, "vip_app_id" bigint NOT NULL,"route_type" bigint default 1, "destination" VARCHAR(128) DEFAULT '',"pods_api" VARCHAR(512) DEFAULT '',"pod_port" VARCHAR(128) DEFAULT '',"pods" VARCHAR(1024) DEFAULT '')`
+	const sqlCreateTableIfNotExistsVipTargets = `CREATE TABLE IF NOT EXISTS "vip_targets"("id" bigserial PRIMARY KEY, "vip_app_id" bigint NOT NULL,"route_type" bigint default 1, "destination" VARCHAR(128) DEFAULT '',"pods_api" VARCHAR(512) DEFAULT '',"pod_port" VARCHAR(128) DEFAULT '',"pods" VARCHAR(1024) DEFAULT '',"health_check" VARCHAR(1024) DEFAULT '',"proxy_protocol" bigint DEFAULT 0)`
 	_, err := dal.db.Exec(sqlCreateTableIfNotExistsVipTargets)
 	return err
 }
@@ -22,7 +22,7 @@ func (dal *MyDAL) CreateTableIfNotExistsVipTargets() error {
 // SelectVipTargetsByAppID ...
 func (dal *MyDAL) SelectVipTargetsByAppID(vipAppID int64) []*models.VipTarget {
 	targets := []*models.VipTarget{}
-	const sqlSelectVipTargetsByAppID = `SELECT "id","route_type","destination","pods_api","pod_port" FROM "vip_targets" WHERE "vip_app_id"=$1`
+	const sqlSelectVipTargetsByAppID = `SELECT "id","route_type","destination","pods_api","pod_port","health_check","proxy_protocol" FROM "vip_targets" WHERE "vip_app_id"=$1`
 	rows, err := dal.db.Query(sqlSelectVipTargetsByAppID, vipAppID)
 	if err != nil {
 		utils.DebugPrintln("SelectVipTargetsByAppID", err)
@@ -31,19 +31,21 @@ func (dal *MyDAL) SelectVipTargetsByAppID(vipAppID int64) []*models.VipTarget {
 	defer rows.Close()
 	for rows.Next() {
 		vipTarget := &models.VipTarget{VipAppID: vipAppID, Online: true}
-		err = rows.Scan(&vipTarget.ID, &vipTarget.RouteType, &vipTarget.Destination, &vipTarget.PodsAPI, &vipTarget.PodPort)
+		var healthCheck string
+		err = rows.Scan(&vipTarget.ID, &vipTarget.RouteType, &vipTarget.Destination, &vipTarget.PodsAPI, &vipTarget.PodPort, &healthCheck, &vipTarget.ProxyProtocol)
 		if err != nil {
 			utils.DebugPrintln("SelectVipTargetsByAppID rows.Scan", err)
 		}
+		vipTarget.HealthCheck = UnmarshalHealthCheck(healthCheck)
 		targets = append(targets, vipTarget)
 	}
 	return targets
 }
 
 // UpdateVipTarget ... update port forwarding target
-func (dal *MyDAL) UpdateVipTarget(vipAppID int64, routeType int64, destination string, podsAPI string, podPort string, id int64) error {
-	const sqlUpdateTarget = `UPDATE "vip_targets" SET "vip_app_id"=$1,"route_type"=$2,"destination"=$3,"pods_api"=$4,"pod_port"=$5 WHERE "id"=$6`
-	_, err := dal.db.Exec(sqlUpdateTarget, vipAppID, routeType, destination, podsAPI, podPort, id)
+func (dal *MyDAL) UpdateVipTarget(vipAppID int64, routeType int64, destination string, podsAPI string, podPort string, healthCheck string, proxyProtocol int64, id int64) error {
+	const sqlUpdateTarget = `UPDATE "vip_targets" SET "vip_app_id"=$1,"route_type"=$2,"destination"=$3,"pods_api"=$4,"pod_port"=$5,"health_check"=$6,"proxy_protocol"=$7 WHERE "id"=$8`
+	_, err := dal.db.Exec(sqlUpdateTarget, vipAppID, routeType, destination, podsAPI, podPort, healthCheck, proxyProtocol, id)
 	if err != nil {
 		utils.DebugPrintln("UpdateVipTarget", err)
 	}
@@ -51,10 +53,10 @@ func (dal *MyDAL) UpdateVipTarget(vipAppID int64, routeType int64, destination s
 }
 
 // InsertVipTarget create new VipTarget
-func (dal *MyDAL) InsertVipTarget(vipAppID int64, routeType int64, destination string, podsAPI string, podPort string) (newID int64, err error) {
-	const sqlInsertTarget = `INSERT INTO "vip_targets"("id","vip_app_id", "route_type", "destination", "pods_api", "pod_port") VALUES($1,$2,$3,$4,$5,$6) RETURNING "id"`
+func (dal *MyDAL) InsertVipTarget(vipAppID int64, routeType int64, destination string, podsAPI string, podPort string, healthCheck string, proxyProtocol int64) (newID int64, err error) {
+	const sqlInsertTarget = `INSERT INTO "vip_targets"("id","vip_app_id", "route_type", "destination", "pods_api", "pod_port", "health_check", "proxy_protocol") VALUES($1,$2,$3,$4,$5,$6,$7,$8) RETURNING "id"`
 	snowID := utils.GenSnowflakeID()
-	err = dal.db.QueryRow(sqlInsertTarget, snowID, vipAppID, routeType, destination, podsAPI, podPort).Scan(&newID)
+	err = dal.db.QueryRow(sqlInsertTarget, snowID, vipAppID, routeType, destination, podsAPI, podPort, healthCheck, proxyProtocol).Scan(&newID)
 	if err != nil {
 		utils.DebugPrintln("InsertVipTarget", err)
 	}
@@ -35,7 +35,7 @@ var (
 	// IsPrimary i.e. Is Primary Node
 	IsPrimary bool
 	// Version of JANUSEC
-	Version = "1.5.2"
+	Version = "1.5.3"
 )
 
 // InitConfig init Data Access Layer
@@ -94,6 +94,27 @@ func InitConfig() {
 	}
 }
 
+// ReloadConfig reload config.json when SIGHUP received,
+// node role, database, listen addresses and PROXY protocol take effect after restart or upgrade
+func ReloadConfig() error {
+	cfg, err := NewConfig("./config.json")
+	if err != nil {
+		return err
+	}
+	cfg.NodeRole = CFG.NodeRole
+	cfg.ListenHTTP = CFG.ListenHTTP
+	cfg.ListenHTTPS = CFG.ListenHTTPS
+	cfg.ListenHTTP3 = CFG.ListenHTTP3
+	cfg.PrimaryNode = CFG.PrimaryNode
+	cfg.ProxyProtocol = CFG.ProxyProtocol
+	cfg.Cache = CFG.Cache
+	if !IsPrimary {
+		NodesKey = NodeHexKeyToCryptKey(cfg.ReplicaNode.NodeKey)
+	}
+	CFG = cfg
+	return nil
+}
+
 // ExecSQL Exec SQL Directly
 func (dal *MyDAL) ExecSQL(sql string) error {
 	_, err := dal.db.Exec(sql)
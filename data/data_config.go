@@ -57,5 +57,76 @@ func NewConfig(filename string) (*models.Config, error) {
 	if len(config.ListenHTTPS) == 0 {
 		config.ListenHTTPS = ":443"
 	}
+	// Init default upstream connection pool
+	if config.Upstream == nil {
+		config.Upstream = &models.UpstreamConfig{}
+	}
+	if config.Upstream.MaxIdleConns == 0 {
+		config.Upstream.MaxIdleConns = 1000
+	}
+	if config.Upstream.MaxIdleConnsPerHost == 0 {
+		config.Upstream.MaxIdleConnsPerHost = 100
+	}
+	if config.Upstream.DialTimeout == 0 {
+		config.Upstream.DialTimeout = 30
+	}
+	if config.Upstream.IdleConnTimeout == 0 {
+		config.Upstream.IdleConnTimeout = 90
+	}
+	if config.Upstream.TLSHandshakeTimeout == 0 {
+		config.Upstream.TLSHandshakeTimeout = 60
+	}
+	if config.Upstream.ResponseHeaderTimeout == 0 {
+		config.Upstream.ResponseHeaderTimeout = 60
+	}
+	// Init default PROXY protocol setting
+	if config.ProxyProtocol == nil {
+		config.ProxyProtocol = &models.ProxyProtocolConfig{}
+	}
+	if config.ProxyProtocol.HeaderTimeout == 0 {
+		config.ProxyProtocol.HeaderTimeout = 5
+	}
+	if config.ShutdownTimeout == 0 {
+		config.ShutdownTimeout = 30
+	}
+	// Init default server timeouts
+	if config.Server == nil {
+		config.Server = &models.ServerConfig{}
+	}
+	if config.Server.ReadHeaderTimeout == 0 {
+		config.Server.ReadHeaderTimeout = 10
+	}
+	if config.Server.IdleTimeout == 0 {
+		config.Server.IdleTimeout = 120
+	}
+	if config.Server.MaxHeaderBytes == 0 {
+		config.Server.MaxHeaderBytes = 1 << 20
+	}
+	// Init default cache size
+	if config.Cache == nil {
+		config.Cache = &models.CacheStoreConfig{}
+	}
+	if config.Cache.MemorySize == 0 {
+		config.Cache.MemorySize = 64
+	}
+	if config.Cache.DiskSize == 0 {
+		config.Cache.DiskSize = 1024
+	}
+	if config.Cache.MaxObjectSize == 0 {
+		config.Cache.MaxObjectSize = 10
+	}
+	if config.Cache.MaxMemoryObjectSize == 0 {
+		config.Cache.MaxMemoryObjectSize = 512
+	}
+	// Init default ACME setting
+	if config.ACME == nil {
+		config.ACME = &models.ACMEConfig{}
+	}
+	if len(config.ACME.DirectoryURL) == 0 {
+		config.ACME.DirectoryURL = "https://acme-v02.api.letsencrypt.org/directory"
+	}
+	if config.ACME.RenewBefore == 0 {
+		config.ACME.RenewBefore = 30
+	}
 	return config, nil
 }
@@ -15,6 +15,7 @@ import (
 	"html/template"
 	"net"
 	"net/http"
+	"sync"
 	"time"
 
 	"janusec/models"
@@ -124,6 +125,37 @@ var (
 	</script>
 	</body>
 	</html>`
+
+	// errorPageHTML is the default HTML error page, v1.5.3
+	errorPageHTML string = `<!DOCTYPE html>
+	<html>
+	<head>
+	<title>{{ .StatusCode }} {{ .StatusText }}</title>
+	<style>
+	body {
+		font-family: Arial, Helvetica, sans-serif;
+		text-align: center;
+	}
+	.error_div {
+		padding: 10px;
+		width: 70%;
+		margin: auto;
+	}
+	</style>
+	</head>
+	<body>
+	<div class="error_div">
+	<h1>{{ .StatusCode }} {{ .StatusText }}</h1>
+	<hr>
+	<p>{{ .Description }}</p>
+	<p>Request ID: {{ .RequestID }}<br>Time: {{ .Time }}</p>
+	{{ if .SupportContact }}<p>Support: {{ .SupportContact }}</p>{{ end }}
+	</div>
+	</body>
+	</html>`
+
+	// DefaultErrorPageJSON is the default JSON error page, also used if a custom one is not valid JSON, v1.5.3
+	DefaultErrorPageJSON string = `{"status":{{ .StatusCode }},"error":{{ json .StatusText }},"description":{{ json .Description }},"request_id":{{ json .RequestID }},"support_contact":{{ json .SupportContact }}}`
 )
 
 // UpdateBackendLastModified ...
@@ -205,6 +237,22 @@ func InitDefaultSettings() {
 		// v1.4.1 added
 		_ = DAL.SaveStringSetting("shield_html", shieldHTML)
 	}
+	if !DAL.ExistsSetting("hidden_headers") {
+		// v1.5.3 added
+		_ = DAL.SaveStringSetting("hidden_headers", "Server,X-AspNet-Version,X-AspNetMvc-Version,X-Runtime")
+	}
+	if !DAL.ExistsSetting("error_page_html") {
+		// v1.5.3 added
+		_ = DAL.SaveStringSetting("error_page_html", errorPageHTML)
+	}
+	if !DAL.ExistsSetting("error_page_json") {
+		// v1.5.3 added
+		_ = DAL.SaveStringSetting("error_page_json", DefaultErrorPageJSON)
+	}
+	if !DAL.ExistsSetting("support_contact") {
+		// v1.5.3 added
+		_ = DAL.SaveStringSetting("support_contact", "")
+	}
 
 	// SMTP shared with PrimarySetting
 	if !DAL.ExistsSetting("smtp_enabled") {
@@ -380,7 +428,12 @@ func LoadSettings() {
 		PrimarySetting.SearchEngines = DAL.SelectStringSetting("search_engines")
 		PrimarySetting.WebSSHEnabled = DAL.SelectBoolSetting("webssh_enabled")
 		PrimarySetting.BlockHTML = DAL.SelectStringSetting("block_html")
-		PrimarySetting.ShieldHTML = DAL.SelectStringSetting("shield_html") // v1.4.1 added
+		PrimarySetting.ShieldHTML = DAL.SelectStringSetting("shield_html")       // v1.4.1 added
+		PrimarySetting.HiddenHeaders = DAL.SelectStringSetting("hidden_headers") // v1.5.3 added
+		// v1.5.3 custom error pages
+		PrimarySetting.ErrorPageHTML = DAL.SelectStringSetting("error_page_html")
+		PrimarySetting.ErrorPageJSON = DAL.SelectStringSetting("error_page_json")
+		PrimarySetting.SupportContact = DAL.SelectStringSetting("support_contact")
 		// v1.2.0 add SMTP
 		smtpSetting := &models.SMTPSetting{}
 		smtpSetting.SMTPEnabled = DAL.SelectBoolSetting("smtp_enabled")
@@ -400,6 +453,8 @@ func LoadSettings() {
 		PrimarySetting.DataDiscoveryKey = DAL.SelectStringSetting("data_discovery_key")
 		// v1.4.1 DNS
 		PrimarySetting.DNSEnabled = DAL.SelectBoolSetting("dns_enabled")
+		// v1.5.3 TLS session ticket keys rotation
+		PrimarySetting.SessionTicketRotation = DAL.SelectIntSetting("session_ticket_rotation")
 
 		// NodeSetting
 		NodeSetting = &models.NodeShareSetting{}
@@ -411,6 +466,10 @@ func LoadSettings() {
 		NodeSetting.SearchEnginesPattern = UpdateSecondShieldPattern(PrimarySetting.SearchEngines)
 		NodeSetting.BlockHTML = PrimarySetting.BlockHTML
 		NodeSetting.ShieldHTML = PrimarySetting.ShieldHTML
+		NodeSetting.HiddenHeaders = PrimarySetting.HiddenHeaders
+		NodeSetting.ErrorPageHTML = PrimarySetting.ErrorPageHTML
+		NodeSetting.ErrorPageJSON = PrimarySetting.ErrorPageJSON
+		NodeSetting.SupportContact = PrimarySetting.SupportContact
 		// NodeSetting.SMTP and PrimarySetting.SMTP point to the same SMTP setting
 		NodeSetting.SMTP = smtpSetting
 		// LoadAuthConfig
@@ -738,6 +797,14 @@ func UpdatePrimarySetting(r *http.Request, body []byte, clientIP string, authUse
 	DAL.SaveStringSetting("shield_html", PrimarySetting.ShieldHTML)
 	NodeSetting.ShieldHTML = PrimarySetting.ShieldHTML
 	UpdateShieldTemplate()
+	DAL.SaveStringSetting("hidden_headers", PrimarySetting.HiddenHeaders)
+	NodeSetting.HiddenHeaders = PrimarySetting.HiddenHeaders
+	DAL.SaveStringSetting("error_page_html", PrimarySetting.ErrorPageHTML)
+	NodeSetting.ErrorPageHTML = PrimarySetting.ErrorPageHTML
+	DAL.SaveStringSetting("error_page_json", PrimarySetting.ErrorPageJSON)
+	NodeSetting.ErrorPageJSON = PrimarySetting.ErrorPageJSON
+	DAL.SaveStringSetting("support_contact", PrimarySetting.SupportContact)
+	NodeSetting.SupportContact = PrimarySetting.SupportContact
 	DAL.SaveBoolSetting("smtp_enabled", PrimarySetting.SMTP.SMTPEnabled)
 	DAL.SaveStringSetting("smtp_server", PrimarySetting.SMTP.SMTPServer)
 	DAL.SaveStringSetting("smtp_port", PrimarySetting.SMTP.SMTPPort)
@@ -748,6 +815,7 @@ func UpdatePrimarySetting(r *http.Request, body []byte, clientIP string, authUse
 	DAL.SaveStringSetting("data_discovery_tenant_id", PrimarySetting.DataDiscoveryTenantID)
 	DAL.SaveStringSetting("data_discovery_key", PrimarySetting.DataDiscoveryKey)
 	DAL.SaveBoolSetting("dns_enabled", PrimarySetting.DNSEnabled)
+	DAL.SaveIntSetting("session_ticket_rotation", PrimarySetting.SessionTicketRotation)
 	go utils.OperationLog(clientIP, authUser.Username, "Update Settings", "Global Settings")
 	UpdateBackendLastModified()
 	return PrimarySetting, nil
@@ -774,8 +842,29 @@ func UpdateSecondShieldPattern(searchEngines string) string {
 	return fmt.Sprintf(`(?i)(%s)`, searchEngines)
 }
 
+// nodeSettingMutex guard the fields of NodeSetting updated by routines, such as SessionTicketKeys
+var nodeSettingMutex sync.RWMutex
+
+// GetNodeSetting return a copy, so that the routines can update NodeSetting while it is serialized to replicas
 func GetNodeSetting() *models.NodeShareSetting {
-	return NodeSetting
+	nodeSettingMutex.RLock()
+	defer nodeSettingMutex.RUnlock()
+	nodeSetting := *NodeSetting
+	return &nodeSetting
+}
+
+// GetSessionTicketKeys return the session ticket keys of NodeSetting
+func GetSessionTicketKeys() []string {
+	nodeSettingMutex.RLock()
+	defer nodeSettingMutex.RUnlock()
+	return NodeSetting.SessionTicketKeys
+}
+
+// SetSessionTicketKeys replace the session ticket keys of NodeSetting, keys should not be modified after set
+func SetSessionTicketKeys(keys []string) {
+	nodeSettingMutex.Lock()
+	defer nodeSettingMutex.Unlock()
+	NodeSetting.SessionTicketKeys = keys
 }
 
 func RPCGetNodeSetting() *models.NodeShareSetting {
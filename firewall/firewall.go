@@ -62,6 +62,11 @@ func IsStaticResource(r *http.Request) bool {
 	return true
 }
 
+// IsGRPCRequest check Content-Type of gRPC and gRPC-Web requests, v1.5.3
+func IsGRPCRequest(r *http.Request) bool {
+	return strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc")
+}
+
 // UnEscapeRawValue ...
 func UnEscapeRawValue(rawQuery string) string {
 	rawQuery = strings.Replace(rawQuery, "%%", "%25%", -1)
@@ -82,8 +87,17 @@ func UnEscapeRawValue(rawQuery string) string {
 	return decodeQuery
 }
 
+// restoreBody replay the inspected bytes and then the remaining body
+func restoreBody(r *http.Request, bodyBuf []byte, body io.ReadCloser) {
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(bodyBuf), body), body}
+}
+
 // IsRequestHitPolicy ...
-func IsRequestHitPolicy(r *http.Request, appID int64, srcIP string) (bool, *models.GroupPolicy) {
+// isGRPCBackend is decided by the configuration of the application, the body of gRPC calls is not inspected
+func IsRequestHitPolicy(r *http.Request, appID int64, srcIP string, isGRPCBackend bool) (bool, *models.GroupPolicy) {
 	ctxMap := r.Context().Value(models.PolicyKey("groupPolicyHitValue")).(*sync.Map)
 
 	// ChkPoint_Host
@@ -128,45 +142,59 @@ func IsRequestHitPolicy(r *http.Request, appID int64, srcIP string) (bool, *mode
 		}
 	}
 
-	bodyBuf, _ := io.ReadAll(r.Body)
+	// v1.5.3 the body is limited by the gateway if configured, larger bodies are rejected instead of forwarded without inspection
+	body := r.Body
+	var bodyBuf []byte
+	if !isGRPCBackend {
+		// gRPC messages are binary and may be streamed, reading them would block the call
+		var err error
+		bodyBuf, err = io.ReadAll(body)
+		if err != nil {
+			// such as body too large or timeout, checked by the gateway after inspection
+			utils.DebugPrintln("IsRequestHitPolicy read body", err)
+		}
+	}
+	defer restoreBody(r, bodyBuf, body)
 	r.Body = io.NopCloser(bytes.NewBuffer(bodyBuf))
 	contentType := r.Header.Get("Content-Type")
 
 	mediaType, mediaParams, _ := mime.ParseMediaType(contentType)
 	if strings.HasPrefix(mediaType, "multipart/form-data") {
-		// ChkPoint_UploadFileExt
-		err := r.ParseMultipartForm(1024)
-		if err != nil {
-			utils.DebugPrintln("IsRequestHitPolicy ParseMultipartForm", err)
-		}
-		if r.MultipartForm != nil {
-			for _, filesHeader := range r.MultipartForm.File {
-				for _, fileHeader := range filesHeader {
-					fileExtension := filepath.Ext(fileHeader.Filename) // .php
-					matched, policy = IsMatchGroupPolicy(ctxMap, appID, fileExtension, models.ChkPointUploadFileExt, "", false)
-					if matched {
-						return matched, policy
-					}
-				}
+		// v1.5.3 parse the buffered parts instead of ParseMultipartForm, large files are not stored in temp files
+		multiReader := multipart.NewReader(bytes.NewReader(bodyBuf), mediaParams["boundary"])
+		for {
+			p, err := multiReader.NextPart()
+			if err != nil {
+				// io.EOF, or truncated by read error
+				break
 			}
-
-			// Multipart Content
-			body1 := io.NopCloser(bytes.NewBuffer(bodyBuf))
-			multiReader := multipart.NewReader(body1, mediaParams["boundary"])
-			for {
-				p, err := multiReader.NextPart()
-				if err == io.EOF {
-					break
+			if fileName := p.FileName(); len(fileName) > 0 {
+				// ChkPoint_UploadFileExt
+				fileExtension := filepath.Ext(fileName) // .php
+				matched, policy = IsMatchGroupPolicy(ctxMap, appID, fileExtension, models.ChkPointUploadFileExt, "", false)
+				if matched {
+					return matched, policy
 				}
-				partContent, _ := io.ReadAll(p)
-				// fmt.Println("partContent=", string(partContent))
-				matched, policy = IsMatchGroupPolicy(ctxMap, appID, string(partContent), models.ChkPointGetPostValue, "", true)
+			} else if formName := p.FormName(); len(formName) > 0 {
+				// ChkPoint_GetPostKey of multipart non-file values
+				matched, policy = IsMatchGroupPolicy(ctxMap, appID, formName, models.ChkPointGetPostKey, "", false)
 				if matched {
 					return matched, policy
 				}
 			}
+			// Multipart Content
+			partContent, _ := io.ReadAll(p)
+			// fmt.Println("partContent=", string(partContent))
+			matched, policy = IsMatchGroupPolicy(ctxMap, appID, string(partContent), models.ChkPointGetPostValue, "", true)
+			if matched {
+				return matched, policy
+			}
+		}
+		// query parameters
+		err := r.ParseForm()
+		if err != nil {
+			utils.DebugPrintln("IsRequestHitPolicy r.ParseForm", err)
 		}
-
 	} else if strings.HasPrefix(mediaType, "application/json") {
 		// Request Content-Type: application/json
 		var params interface{}
@@ -180,6 +208,11 @@ func IsRequestHitPolicy(r *http.Request, appID int64, srcIP string) (bool, *mode
 				return matched, policy
 			}
 		}
+		// query parameters
+		err := r.ParseForm()
+		if err != nil {
+			utils.DebugPrintln("IsRequestHitPolicy r.ParseForm", err)
+		}
 	} else {
 		err := r.ParseForm()
 		if err != nil {
@@ -187,10 +220,9 @@ func IsRequestHitPolicy(r *http.Request, appID int64, srcIP string) (bool, *mode
 		}
 	}
 
-	params := r.Form // include GET/POST/ Multipart non-File , but not include json
+	params := r.Form // include GET/POST, but not include json and multipart, which are checked above
 
 	//fmt.Println("IsRequestHitPolicy params:", params, "count:", len(params))
-	r.Body = io.NopCloser(bytes.NewBuffer(bodyBuf))
 	for key, values := range params {
 		//fmt.Println("IsRequestHitPolicy param", key, ":", values)
 		// ChkPoint_GetPostKey
@@ -289,7 +321,8 @@ func IsRequestHitPolicy(r *http.Request, appID int64, srcIP string) (bool, *mode
 }
 
 // IsResponseHitPolicy ...
-func IsResponseHitPolicy(resp *http.Response, appID int64) (bool, *models.GroupPolicy) {
+// isGRPCBackend is decided by the configuration of the application, the body of gRPC responses is not inspected
+func IsResponseHitPolicy(resp *http.Response, appID int64, isGRPCBackend bool) (bool, *models.GroupPolicy) {
 	if resp.StatusCode == http.StatusSwitchingProtocols {
 		return false, nil
 	}
@@ -320,6 +353,11 @@ func IsResponseHitPolicy(resp *http.Response, appID int64) (bool, *models.GroupP
 		}
 	}
 
+	if isGRPCBackend {
+		// v1.5.3 streamed gRPC messages, trailers are sent after the body
+		return false, nil
+	}
+
 	// ChkPoint_ResponseBody
 	bodyBuf, err := io.ReadAll(resp.Body)
 	if err != nil {
@@ -31,5 +31,4 @@ func InitFirewall() {
 	InitHitLog()
 	InitNFTables()
 	go RoutineCleanLogTick()
-	go RoutineCleanCacheTick()
 }
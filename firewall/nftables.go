@@ -14,6 +14,7 @@ import (
 
 	"github.com/google/nftables"
 	"github.com/google/nftables/expr"
+	"github.com/patrickmn/go-cache"
 )
 
 var conn *nftables.Conn
@@ -21,6 +22,10 @@ var table *nftables.Table
 var chain *nftables.Chain
 var set *nftables.Set
 
+// blockedIPs is the block list checked by gateway listeners, v1.5.3
+// Clients behind PROXY protocol can not be blocked by nftables, their packets come from the load balancer
+var blockedIPs = cache.New(5*time.Minute, 5*time.Minute)
+
 // InitNFTables Create Table janusec, chain input
 // nft add table inet janusec
 // nft add chain inet janusec input  { type filter hook input priority 0\; }
@@ -84,6 +89,7 @@ func InitNFTables() {
 // nft add element inet janusec blocklist { 192.168.100.1 timeout 300s }
 func AddIP2NFTables(ip string, blockSeconds float64) {
 	//fmt.Println("AddIP2NFTables", ip)
+	blockedIPs.Set(ip, true, time.Duration(blockSeconds)*time.Second)
 	rules, _ := conn.GetRules(table, chain)
 	if len(rules) == 0 {
 		InitNFTables()
@@ -99,3 +105,9 @@ func AddIP2NFTables(ip string, blockSeconds float64) {
 		utils.DebugPrintln("AddIP2NFTables flush error", err)
 	}
 }
+
+// IsBlockedIP check whether the client IP carried by PROXY protocol header is blocked
+func IsBlockedIP(ip string) bool {
+	_, found := blockedIPs.Get(ip)
+	return found
+}
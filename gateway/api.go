@@ -121,6 +121,12 @@ func AdminAPIHandlerFunc(w http.ResponseWriter, r *http.Request) {
 		obj, err = backend.UpdateApplication(bodyBuf, clientIP, authUser)
 	case "update_vip_app":
 		obj, err = backend.UpdateVipApp(bodyBuf, clientIP, authUser)
+	case "set_app_maintenance":
+		obj, err = backend.SetAppMaintenance(bodyBuf, clientIP, authUser)
+	case "purge_cache":
+		obj, err = PurgeCache(bodyBuf, clientIP, authUser)
+	case "get_cache_stats":
+		obj, err = GetCacheStats(authUser)
 	case "del_app":
 		obj = nil
 		err = backend.DeleteApplicationByID(apiRequest.ObjectID, clientIP, authUser)
@@ -136,6 +142,8 @@ func AdminAPIHandlerFunc(w http.ResponseWriter, r *http.Request) {
 	case "del_cert":
 		obj = nil
 		err = backend.DeleteCertificateByID(apiRequest.ObjectID, clientIP, authUser)
+	case "apply_acme_cert":
+		obj, err = backend.ApplyACMECertificate(bodyBuf, clientIP, authUser)
 	case "self_sign_cert":
 		obj, err = utils.GenerateRSACertificate(bodyBuf)
 	case "get_domains":
@@ -265,6 +273,13 @@ func AdminAPIHandlerFunc(w http.ResponseWriter, r *http.Request) {
 		obj, err = backend.UpdateCookie(bodyBuf, clientIP, authUser)
 	case "del_cookie":
 		obj, err = nil, backend.DeleteCookie(apiRequest.ObjectID, clientIP, authUser)
+	case "get_rewrite_rules":
+		obj = backend.GetRewriteRulesByAppID(apiRequest.ObjectID)
+		err = nil
+	case "update_rewrite_rule":
+		obj, err = backend.UpdateRewriteRule(bodyBuf, clientIP, authUser)
+	case "del_rewrite_rule":
+		obj, err = nil, backend.DeleteRewriteRule(apiRequest.ObjectID, clientIP, authUser)
 	case "get_cookie_refs":
 		obj = backend.GetCookieRefs()
 		err = nil
@@ -379,6 +394,11 @@ func ReplicaAPIHandlerFunc(w http.ResponseWriter, r *http.Request) {
 	case "get_discovery_rules":
 		obj = firewall.GetDiscoveryRules()
 		err = nil
+	case "get_cache_purges":
+		obj, err = RPCGetCachePurges(r)
+	case "update_cache_stats":
+		obj = nil
+		err = RPCUpdateCacheStats(r)
 	default:
 		//fmt.Println("undefined action:", action)
 		utils.DebugPrintln("undefined action:", action)
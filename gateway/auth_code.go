@@ -8,6 +8,7 @@
 package gateway
 
 import (
+	"bytes"
 	"encoding/base64"
 	"fmt"
 	"net/http"
@@ -66,8 +67,15 @@ func ShowAuthCodeRegisterUI(w http.ResponseWriter, r *http.Request) {
 		TOTPKey:   totpItem.TOTPKey,
 		ImageData: codeImageText,
 	}
-	if err := authCodeUITemplate.Execute(w, &authCodeContext); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	buf := &bytes.Buffer{}
+	if err := authCodeUITemplate.Execute(buf, &authCodeContext); err != nil {
+		utils.DebugPrintln("ShowAuthCodeRegisterUI Execute", err)
+		writeErrorPage(w, r, nil, getRemoteIP(r), http.StatusInternalServerError, "")
+		return
+	}
+	_, err = w.Write(buf.Bytes())
+	if err != nil {
+		utils.DebugPrintln("ShowAuthCodeRegisterUI w.Write", err)
 	}
 }
 
@@ -8,7 +8,9 @@
 package gateway
 
 import (
+	"bytes"
 	"janusec/data"
+	"janusec/utils"
 	"net/http"
 	"text/template"
 )
@@ -31,8 +33,15 @@ func ShowLDAPLoginUI(w http.ResponseWriter, r *http.Request) {
 		DisplayName:     data.NodeSetting.AuthConfig.LDAP.DisplayName,
 		State:           state,
 		AuthCodeEnabled: data.NodeSetting.AuthConfig.LDAP.AuthenticatorEnabled}
-	if err := ldapLoginTemplate.Execute(w, &ldapContext); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	buf := &bytes.Buffer{}
+	if err := ldapLoginTemplate.Execute(buf, &ldapContext); err != nil {
+		utils.DebugPrintln("ShowLDAPLoginUI Execute", err)
+		writeErrorPage(w, r, nil, getRemoteIP(r), http.StatusInternalServerError, "")
+		return
+	}
+	_, err := w.Write(buf.Bytes())
+	if err != nil {
+		utils.DebugPrintln("ShowLDAPLoginUI w.Write", err)
 	}
 }
 
@@ -0,0 +1,433 @@
+/*
+ * @Copyright Reserved By Janusec (https://www.janusec.com/).
+ * @Author: U2
+ * @Date: 2026-10-18 20:52:16
+ * @Last Modified: U2, 2026-10-18 20:52:16
+ */
+
+package gateway
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"janusec/data"
+	"janusec/models"
+	"janusec/utils"
+)
+
+// cacheDir of the disk tier, entries are stored as ./static/cdncache/<appID>/<xx>/<sha256 of key>.cache
+const cacheDir = "./static/cdncache/"
+
+// cacheEntry is a stored response, the body is kept in memory for small and hot entries
+type cacheEntry struct {
+	Key string `json:"key"`
+	// PrimaryKey is the key without Vary headers, the key of cachePrimary
+	PrimaryKey string      `json:"primary_key"`
+	AppID      int64       `json:"app_id"`
+	URL        string      `json:"url"`
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	// Vary is the request header names listed in Vary of the response
+	Vary []string `json:"vary"`
+	// RequestTime and ResponseTime are unix timestamps of the request to the backend
+	RequestTime  int64 `json:"request_time"`
+	ResponseTime int64 `json:"response_time"`
+	// TTL is the freshness lifetime in seconds, stale entries may be served in the stale windows
+	TTL                  int64 `json:"ttl"`
+	StaleWhileRevalidate int64 `json:"stale_while_revalidate"`
+	StaleIfError         int64 `json:"stale_if_error"`
+	Size                 int64 `json:"size"`
+	// Tags from Surrogate-Key header of the backend, used for purge
+	Tags []string `json:"tags"`
+
+	body       []byte
+	onDisk     bool
+	lastAccess int64
+	element    *list.Element
+	memElement *list.Element
+}
+
+// cachePrimary is the Vary of the latest response and the variants of a primary key
+type cachePrimary struct {
+	vary []string
+	// variants map[variant key]*cacheEntry
+	variants map[string]*cacheEntry
+}
+
+// cacheStore is a two-tier LRU cache, memory tier for hot entries and disk tier bounded by size
+type cacheStore struct {
+	mutex sync.Mutex
+	// entries map[variant key]*cacheEntry
+	entries map[string]*cacheEntry
+	// primaries map[primary key]*cachePrimary
+	primaries map[string]*cachePrimary
+	// lru of all entries, memLRU of entries with body in memory, front is the most recently used
+	lru    *list.List
+	memLRU *list.List
+
+	diskSize      int64
+	memSize       int64
+	maxDiskSize   int64
+	maxMemSize    int64
+	maxObjectSize int64
+	maxMemObject  int64
+}
+
+var httpCache *cacheStore
+
+// InitCache create the cache store and load entries on disk, should be called after the config is loaded
+func InitCache() {
+	cfg := data.CFG.Cache
+	httpCache = &cacheStore{
+		entries:       map[string]*cacheEntry{},
+		primaries:     map[string]*cachePrimary{},
+		lru:           list.New(),
+		memLRU:        list.New(),
+		maxDiskSize:   cfg.DiskSize << 20,
+		maxMemSize:    cfg.MemorySize << 20,
+		maxObjectSize: cfg.MaxObjectSize << 20,
+		maxMemObject:  cfg.MaxMemoryObjectSize << 10,
+	}
+	if httpCache.maxDiskSize > 0 {
+		httpCache.loadDisk()
+	}
+	go RoutineCleanCacheTick()
+}
+
+// loadDisk rebuild the index from the files, files of the old version without metadata are removed
+func (store *cacheStore) loadDisk() {
+	loaded := []*cacheEntry{}
+	err := filepath.WalkDir(cacheDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if !strings.HasSuffix(path, ".cache") {
+			_ = os.Remove(path)
+			return nil
+		}
+		entry, err := readCacheMeta(path)
+		if err != nil || len(entry.PrimaryKey) == 0 || cacheFilePath(entry.AppID, entry.Key) != filepath.Clean(path) {
+			utils.DebugPrintln("InitCache remove", path, err)
+			_ = os.Remove(path)
+			return nil
+		}
+		entry.onDisk = true
+		info, err := d.Info()
+		if err == nil {
+			entry.lastAccess = info.ModTime().Unix()
+		}
+		loaded = append(loaded, entry)
+		return nil
+	})
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		utils.DebugPrintln("InitCache WalkDir", err)
+	}
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	for _, entry := range loaded {
+		store.insert(entry)
+	}
+	store.evictDisk()
+	utils.DebugPrintln("InitCache loaded entries:", len(store.entries), "size:", store.diskSize)
+}
+
+func cacheFilePath(appID int64, key string) string {
+	hash := sha256.Sum256([]byte(key))
+	name := hex.EncodeToString(hash[:])
+	return filepath.Join(cacheDir, strconv.FormatInt(appID, 10), name[:2], name+".cache")
+}
+
+// readCacheMeta read the metadata at the head of the file, format: 4-byte length + JSON + body
+func readCacheMeta(path string) (*cacheEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	entry, _, err := decodeCacheMeta(file)
+	return entry, err
+}
+
+func decodeCacheMeta(reader io.Reader) (*cacheEntry, int64, error) {
+	lengthBuf := make([]byte, 4)
+	if _, err := io.ReadFull(reader, lengthBuf); err != nil {
+		return nil, 0, err
+	}
+	length := binary.BigEndian.Uint32(lengthBuf)
+	if length > 1<<20 {
+		return nil, 0, errors.New("invalid cache file")
+	}
+	metaBuf := make([]byte, length)
+	if _, err := io.ReadFull(reader, metaBuf); err != nil {
+		return nil, 0, err
+	}
+	entry := &cacheEntry{}
+	if err := json.Unmarshal(metaBuf, entry); err != nil {
+		return nil, 0, err
+	}
+	return entry, int64(4 + length), nil
+}
+
+func writeCacheFile(entry *cacheEntry, body []byte) error {
+	path := cacheFilePath(entry.AppID, entry.Key)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	metaBuf, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	// write to a temporary file and rename, so that readers never see a partial file
+	tmpFile, err := os.CreateTemp(filepath.Dir(path), "tmp-*")
+	if err != nil {
+		return err
+	}
+	lengthBuf := make([]byte, 4)
+	binary.BigEndian.PutUint32(lengthBuf, uint32(len(metaBuf)))
+	_, err = tmpFile.Write(append(append(lengthBuf, metaBuf...), body...))
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpFile.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tmpFile.Name())
+	}
+	return err
+}
+
+// Lookup return the entry of the primary key matching the Vary headers of the request
+func (store *cacheStore) Lookup(primaryKey string, r *http.Request) *cacheEntry {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	primary, ok := store.primaries[primaryKey]
+	if !ok {
+		return nil
+	}
+	entry, ok := store.entries[variantKey(primaryKey, primary.vary, r)]
+	if !ok {
+		return nil
+	}
+	entry.lastAccess = time.Now().Unix()
+	store.lru.MoveToFront(entry.element)
+	if entry.memElement != nil {
+		store.memLRU.MoveToFront(entry.memElement)
+	}
+	return entry
+}
+
+// Body return the body of the entry, read from disk if not in memory
+func (store *cacheStore) Body(entry *cacheEntry) ([]byte, error) {
+	store.mutex.Lock()
+	body := entry.body
+	store.mutex.Unlock()
+	if body != nil {
+		return body, nil
+	}
+	file, err := os.Open(cacheFilePath(entry.AppID, entry.Key))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	if _, _, err = decodeCacheMeta(file); err != nil {
+		return nil, err
+	}
+	body, err = io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) != entry.Size {
+		return nil, errors.New("cache file size mismatch")
+	}
+	// promote to memory tier
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if current, ok := store.entries[entry.Key]; ok && current == entry && entry.body == nil && entry.Size <= store.maxMemObject {
+		store.setMemBody(entry, body)
+	}
+	return body, nil
+}
+
+// Store add or replace the entry, the body is written to disk
+func (store *cacheStore) Store(entry *cacheEntry, body []byte) {
+	entry.Size = int64(len(body))
+	if entry.Size > store.maxObjectSize {
+		return
+	}
+	if store.maxDiskSize > 0 {
+		if err := writeCacheFile(entry, body); err != nil {
+			utils.DebugPrintln("cacheStore writeCacheFile", entry.URL, err)
+		} else {
+			entry.onDisk = true
+		}
+	}
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if old, ok := store.entries[entry.Key]; ok {
+		// the file has been replaced unless failed to write
+		store.remove(old, !entry.onDisk)
+	}
+	entry.lastAccess = time.Now().Unix()
+	store.insert(entry)
+	// the latest Vary is used for lookup
+	store.primaries[entry.PrimaryKey].vary = entry.Vary
+	if entry.Size <= store.maxMemObject {
+		store.setMemBody(entry, body)
+	}
+	if !entry.onDisk && entry.body == nil {
+		store.remove(entry, false)
+		return
+	}
+	store.evictDisk()
+}
+
+// Delete remove the entry if it is not replaced
+func (store *cacheStore) Delete(entry *cacheEntry) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if current, ok := store.entries[entry.Key]; ok && current == entry {
+		store.remove(entry, true)
+	}
+}
+
+// DeletePrimary remove all variants of the primary key, return the number of entries removed
+func (store *cacheStore) DeletePrimary(primaryKey string) int {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	primary, ok := store.primaries[primaryKey]
+	if !ok {
+		return 0
+	}
+	count := 0
+	for _, entry := range primary.variants {
+		store.remove(entry, true)
+		count++
+	}
+	return count
+}
+
+// RemoveMatched remove the entries matching the function, return the number of entries removed
+func (store *cacheStore) RemoveMatched(match func(entry *cacheEntry) bool) int {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	count := 0
+	for _, entry := range store.entries {
+		if match(entry) {
+			store.remove(entry, true)
+			count++
+		}
+	}
+	return count
+}
+
+// Usage return the number of entries and the size of bodies by application
+func (store *cacheStore) Usage() map[int64]*models.CacheStat {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	usage := map[int64]*models.CacheStat{}
+	for _, entry := range store.entries {
+		stat, ok := usage[entry.AppID]
+		if !ok {
+			stat = &models.CacheStat{AppID: entry.AppID}
+			usage[entry.AppID] = stat
+		}
+		stat.Entries++
+		stat.Size += entry.Size
+		if entry.body != nil {
+			stat.MemorySize += entry.Size
+		}
+	}
+	return usage
+}
+
+func (store *cacheStore) insert(entry *cacheEntry) {
+	store.entries[entry.Key] = entry
+	entry.element = store.lru.PushFront(entry)
+	if entry.onDisk {
+		store.diskSize += entry.Size
+	}
+	primary, ok := store.primaries[entry.PrimaryKey]
+	if !ok {
+		primary = &cachePrimary{vary: entry.Vary, variants: map[string]*cacheEntry{}}
+		store.primaries[entry.PrimaryKey] = primary
+	}
+	primary.variants[entry.Key] = entry
+}
+
+func (store *cacheStore) setMemBody(entry *cacheEntry, body []byte) {
+	entry.body = body
+	entry.memElement = store.memLRU.PushFront(entry)
+	store.memSize += entry.Size
+	for store.memSize > store.maxMemSize && store.memLRU.Len() > 0 {
+		oldest := store.memLRU.Back().Value.(*cacheEntry)
+		if !oldest.onDisk {
+			// memory only
+			store.remove(oldest, true)
+			continue
+		}
+		store.dropMemBody(oldest)
+	}
+}
+
+func (store *cacheStore) dropMemBody(entry *cacheEntry) {
+	if entry.memElement == nil {
+		return
+	}
+	store.memLRU.Remove(entry.memElement)
+	entry.memElement = nil
+	entry.body = nil
+	store.memSize -= entry.Size
+}
+
+// remove the entry from the index, and the file if removeFile
+func (store *cacheStore) remove(entry *cacheEntry, removeFile bool) {
+	delete(store.entries, entry.Key)
+	store.lru.Remove(entry.element)
+	store.dropMemBody(entry)
+	if entry.onDisk {
+		store.diskSize -= entry.Size
+		if removeFile {
+			_ = os.Remove(cacheFilePath(entry.AppID, entry.Key))
+		}
+	}
+	if primary, ok := store.primaries[entry.PrimaryKey]; ok {
+		delete(primary.variants, entry.Key)
+		if len(primary.variants) == 0 {
+			delete(store.primaries, entry.PrimaryKey)
+		}
+	}
+}
+
+func (store *cacheStore) evictDisk() {
+	for store.maxDiskSize > 0 && store.diskSize > store.maxDiskSize && store.lru.Len() > 0 {
+		oldest := store.lru.Back().Value.(*cacheEntry)
+		store.remove(oldest, true)
+	}
+}
+
+// variantKey = primary key + values of Vary headers of the request
+func variantKey(primaryKey string, vary []string, r *http.Request) string {
+	if len(vary) == 0 {
+		return primaryKey
+	}
+	var builder strings.Builder
+	builder.WriteString(primaryKey)
+	for _, name := range vary {
+		builder.WriteString("\n" + name + ":" + strings.Join(r.Header.Values(name), ","))
+	}
+	return builder.String()
+}
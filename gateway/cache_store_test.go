@@ -0,0 +1,72 @@
+/*
+ * @Copyright Reserved By Janusec (https://www.janusec.com/).
+ * @Author: U2
+ * @Date: 2026-10-19 15:20:06
+ * @Last Modified: U2, 2026-10-19 15:20:06
+ */
+
+package gateway
+
+import (
+	"container/list"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"janusec/models"
+)
+
+func newTestCacheStore() *cacheStore {
+	return &cacheStore{
+		entries:       map[string]*cacheEntry{},
+		primaries:     map[string]*cachePrimary{},
+		lru:           list.New(),
+		memLRU:        list.New(),
+		maxMemSize:    1 << 20,
+		maxObjectSize: 1 << 20,
+		maxMemObject:  1 << 10,
+	}
+}
+
+// TestCacheStoreNewlineInPath a decoded path with %0A must not break the primary key of the entry
+func TestCacheStoreNewlineInPath(t *testing.T) {
+	oldCache := httpCache
+	httpCache = newTestCacheStore()
+	defer func() { httpCache = oldCache }()
+	app := &models.Application{ID: 1, CacheEnabled: true}
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/a%0Ab.js", nil)
+	req.Header.Set("Accept-Language", "en")
+	cacheReq := newCacheRequest(req, app, "example.com")
+	if cacheReq == nil || !cacheReq.cacheable {
+		t.Fatal("static resource should be cacheable")
+	}
+	if strings.Contains(cacheReq.primaryKey, "\n") {
+		t.Fatalf("primary key contains newline: %q", cacheReq.primaryKey)
+	}
+	for _, vary := range []string{"", "Accept-Language"} {
+		resp := &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Cache-Control": {"max-age=60"}},
+			Request:    req,
+		}
+		if len(vary) > 0 {
+			resp.Header.Set("Vary", vary)
+		}
+		entry := newCacheEntry(resp, app, cacheReq, resp.Header, time.Now().Unix())
+		if entry == nil {
+			t.Fatal("response should be stored")
+		}
+		httpCache.Store(entry, []byte("body"))
+		if httpCache.Lookup(cacheReq.primaryKey, req) != entry {
+			t.Fatalf("entry not found, vary %q", vary)
+		}
+	}
+	if count := httpCache.DeletePrimary(cacheReq.primaryKey); count != 2 {
+		t.Fatalf("DeletePrimary removed %d entries, expected 2", count)
+	}
+	if len(httpCache.entries) != 0 || len(httpCache.primaries) != 0 {
+		t.Fatal("entries are not removed")
+	}
+}
@@ -108,13 +108,26 @@ func DNSHandler(writer dns.ResponseWriter, reqMsg *dns.Msg) {
 					Hdr: dns.RR_Header{
 						Name:   question.Name,
 						Rrtype: question.Qtype,
-						Class:  dns.ClassNONE,
+						Class:  dns.ClassINET,
 						Ttl:    dnsRecord.TTL,
 					},
 					Txt: []string{dnsRecord.Value},
 				}
 				respMsg.Answer = append(respMsg.Answer, &recordTxt)
 			}
+			// v1.5.3 DNS-01 challenges of ACME, not cached by resolvers
+			for _, value := range backend.GetACMEChallengeValues(question.Name) {
+				recordTxt := dns.TXT{
+					Hdr: dns.RR_Header{
+						Name:   question.Name,
+						Rrtype: question.Qtype,
+						Class:  dns.ClassINET,
+						Ttl:    0,
+					},
+					Txt: []string{value},
+				}
+				respMsg.Answer = append(respMsg.Answer, &recordTxt)
+			}
 		case dns.TypeNS:
 			for _, dnsRecord := range dnsRecords {
 				recordNS := dns.NS{
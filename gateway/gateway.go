@@ -8,13 +8,9 @@
 package gateway
 
 import (
-	"compress/gzip"
-	"context"
-	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"net"
 	"net/http"
 	"net/http/httputil"
@@ -22,7 +18,6 @@ import (
 	"os/exec"
 	"strconv"
 	"strings"
-	"syscall"
 	"time"
 
 	"janusec/backend"
@@ -32,11 +27,9 @@ import (
 	"janusec/usermgmt"
 	"janusec/utils"
 
-	"github.com/andybalholm/brotli"
 	"github.com/gorilla/sessions"
 	"github.com/patrickmn/go-cache"
 	"github.com/yookoala/gofast"
-	"golang.org/x/net/http2"
 )
 
 var (
@@ -86,8 +79,7 @@ func ReverseHandlerFunc(w http.ResponseWriter, r *http.Request) {
 		}
 		if _, err := os.Stat(targetFile); os.IsNotExist(err) {
 			// targetFile not exists
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write([]byte("400 Bad Request"))
+			writeErrorPage(w, r, nil, getRemoteIP(r), http.StatusBadRequest, "Unknown Host")
 			return
 		}
 		http.ServeFile(w, r, targetFile)
@@ -104,13 +96,23 @@ func ReverseHandlerFunc(w http.ResponseWriter, r *http.Request) {
 	}
 
 	r.URL.Scheme = app.InternalScheme
+	if backend.IsH2CScheme(app.InternalScheme) {
+		// v1.5.3 h2c and grpc are HTTP/2 without TLS
+		r.URL.Scheme = "http"
+	}
 	r.URL.Host = r.Host
+	isGRPC := firewall.IsGRPCRequest(r)
 
 	nowTimeStamp := time.Now().Unix()
 	// dynamic
 	srcIP := GetClientIP(r, app)
 	ua := r.UserAgent()
 
+	// v1.5.3 client certificate authentication
+	if !checkClientCert(w, r, app, srcIP, domainStr) {
+		return
+	}
+
 	// IP Policy
 	isAllowIP := false
 	if app.ClientIPMethod == models.IPMethod_REMOTE_ADDR {
@@ -148,6 +150,10 @@ func ReverseHandlerFunc(w http.ResponseWriter, r *http.Request) {
 					return
 				}
 				// not search engine, not crawler, show 5-second shield
+				if isGRPC {
+					writeGRPCError(w, grpcPermissionDenied, "shield verification required")
+					return
+				}
 				GenerateShieldPage(w, r, r.URL.Path)
 				return
 			}
@@ -178,7 +184,7 @@ func ReverseHandlerFunc(w http.ResponseWriter, r *http.Request) {
 				if app.ClientIPMethod == models.IPMethod_REMOTE_ADDR {
 					go firewall.AddIP2NFTables(srcIP, ccPolicy.BlockSeconds)
 				}
-				GenerateBlockPage(w, hitInfo)
+				writeErrorPage(w, r, app, srcIP, http.StatusTooManyRequests, "")
 				return
 			case models.Action_BypassAndLog_200:
 				if needLog {
@@ -188,6 +194,11 @@ func ReverseHandlerFunc(w http.ResponseWriter, r *http.Request) {
 				if needLog {
 					go firewall.LogCCRequest(r, app.ID, srcIP, ccPolicy)
 				}
+				if isGRPC {
+					// gRPC clients can not pass the CAPTCHA
+					writeErrorPage(w, r, app, srcIP, http.StatusTooManyRequests, "")
+					return
+				}
 				captchaHitInfo.Store(hitInfo.ClientID, hitInfo)
 				captchaURL := CaptchaEntrance + "?id=" + hitInfo.ClientID
 				http.Redirect(w, r, captchaURL, http.StatusFound)
@@ -198,15 +209,29 @@ func ReverseHandlerFunc(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// v1.5.3 body size and slow client protection, before reading the body by WAF
+	if !limitRequestBody(w, r, app, srcIP) {
+		return
+	}
+
 	// WAF Check
+	// v1.5.3 the body of gRPC calls is not inspected only if the application is configured as gRPC backend
+	isGRPCBackend := backend.IsGRPCBackend(app, r)
+	if isGRPCBackend {
+		r = withGRPCBackend(r)
+	}
 	if !isAllowIP && app.WAFEnabled {
-		if isHit, policy := firewall.IsRequestHitPolicy(r, app.ID, srcIP); isHit {
+		body, ok := limitInspectBody(w, r, app, srcIP, isGRPCBackend)
+		if !ok {
+			return
+		}
+		if isHit, policy := firewall.IsRequestHitPolicy(r, app.ID, srcIP, isGRPCBackend); isHit {
 			switch policy.Action {
 			case models.Action_Block_100:
 				vulnName, _ := firewall.VulnMap.Load(policy.VulnID)
 				hitInfo := &models.HitInfo{TypeID: 2, PolicyID: policy.ID, VulnName: vulnName.(string)}
 				go firewall.LogGroupHitRequest(r, app.ID, srcIP, policy)
-				GenerateBlockPage(w, hitInfo)
+				writeBlockResponse(w, r, hitInfo)
 				return
 			case models.Action_BypassAndLog_200:
 				go firewall.LogGroupHitRequest(r, app.ID, srcIP, policy)
@@ -221,6 +246,10 @@ func ReverseHandlerFunc(w http.ResponseWriter, r *http.Request) {
 					PolicyID: policy.ID, VulnName: "Group Policy Hit",
 					Action: policy.Action, ClientID: clientID,
 					TargetURL: targetURL, BlockTime: nowTimeStamp}
+				if isGRPC {
+					writeBlockResponse(w, r, hitInfo)
+					return
+				}
 				captchaHitInfo.Store(clientID, hitInfo)
 				captchaURL := CaptchaEntrance + "?id=" + clientID
 				http.Redirect(w, r, captchaURL, http.StatusTemporaryRedirect)
@@ -229,6 +258,10 @@ func ReverseHandlerFunc(w http.ResponseWriter, r *http.Request) {
 				// models.Action_Pass_400 do nothing
 			}
 		}
+		// v1.5.3 the body larger than the inspection limit is rejected, not forwarded without inspection
+		if body != nil && writeBodyError(w, r, app, srcIP, body.err) {
+			return
+		}
 	}
 
 	// Check OAuth
@@ -247,6 +280,10 @@ func ReverseHandlerFunc(w http.ResponseWriter, r *http.Request) {
 		}
 		//fmt.Println("1000", usernameI, url)
 		if usernameI == nil {
+			if isGRPC {
+				writeGRPCError(w, grpcUnauthenticated, "authentication required")
+				return
+			}
 			// Exec OAuth2 Authentication
 			state := data.SHA256Hash(srcIP + url + ua)
 			stateSession := session.Values[state]
@@ -254,10 +291,8 @@ func ReverseHandlerFunc(w http.ResponseWriter, r *http.Request) {
 			if stateSession == nil {
 				entranceURL, err := getOAuthEntrance(state)
 				if err != nil {
-					_, err = w.Write([]byte(err.Error()))
-					if err != nil {
-						utils.DebugPrintln("w.Write error", err)
-					}
+					utils.DebugPrintln("getOAuthEntrance", err)
+					writeErrorPage(w, r, app, srcIP, http.StatusInternalServerError, "")
 					return
 				}
 				// Save Application URL for CallBack
@@ -294,10 +329,8 @@ func ReverseHandlerFunc(w http.ResponseWriter, r *http.Request) {
 				session.Values["userid"] = nil
 				entranceURL, err := getOAuthEntrance(state)
 				if err != nil {
-					_, err = w.Write([]byte(err.Error()))
-					if err != nil {
-						utils.DebugPrintln("w.Write error", err)
-					}
+					utils.DebugPrintln("getOAuthEntrance", err)
+					writeErrorPage(w, r, app, srcIP, http.StatusInternalServerError, "")
 					return
 				}
 				http.Redirect(w, r, entranceURL, http.StatusTemporaryRedirect)
@@ -321,14 +354,39 @@ func ReverseHandlerFunc(w http.ResponseWriter, r *http.Request) {
 		r.Header.Set("X-Auth-User", usernameI.(string))
 	}
 
-	dest := backend.SelectBackendRoute(app, r, srcIP)
+	// v1.5.3 maintenance mode, after OAuth so that authenticated users can be bypassed
+	if checkMaintenance(w, r, app, srcIP) {
+		return
+	}
+
+	// v1.5.3 rewrite and redirect rules, before routing
+	if applyRewriteRules(w, r, app, srcIP) {
+		return
+	}
+
+	// v1.5.3 request header rules, variables are kept for response header rules
+	r = applyRequestHeaderRules(r, app, srcIP)
+
+	// urlPath is the path before route rewriting, used for retries
+	urlPath := r.URL.Path
+	// v1.5.3 HTTP cache, checked before routing so that stale responses can be served if the backends are offline
+	cacheReq := newCacheRequest(r, app, domainStr)
+	// v1.5.3 traffic split between destination groups
+	group, splitCookie := backend.SelectSplitGroup(app, r, srcIP)
+	if splitCookie != nil {
+		http.SetCookie(w, splitCookie)
+	}
+	dest := backend.SelectBackendRoute(app, r, srcIP, group)
 	if dest == nil {
-		errInfo := &models.InternalErrorInfo{
-			Description: "Internal Servers Offline",
+		if serveStaleIfError(w, r, app, cacheReq) {
+			return
 		}
-		GenerateInternalErrorResponse(w, errInfo)
+		writeErrorPage(w, r, app, srcIP, http.StatusServiceUnavailable, "Internal Servers Offline")
 		return
 	}
+	// Outstanding requests used for load balancing
+	dest.Outstanding.Add(1)
+	defer dest.Outstanding.Add(-1)
 
 	// Modify Origin if client http and backend https
 	if (r.TLS == nil) && (app.InternalScheme == "https") {
@@ -344,7 +402,12 @@ func ReverseHandlerFunc(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Add access log and statistics
-	go utils.AccessLog(domainStr, r.Method, srcIP, r.RequestURI, ua)
+	if isGRPC {
+		// v1.5.3 gRPC method and grpc-status are logged after the response finished
+		defer logGRPCAccess(w, domainStr, srcIP, urlPath, ua, time.Now())
+	} else {
+		go utils.AccessLog(domainStr, r.Method, srcIP, r.RequestURI, ua)
+	}
 	go IncAccessStat(app.ID, r.URL.Path)
 	referer := r.Referer()
 	if len(referer) > 0 {
@@ -366,7 +429,7 @@ func ReverseHandlerFunc(w http.ResponseWriter, r *http.Request) {
 		targetFile := dest.BackendRoute + strings.Replace(r.URL.Path, dest.RequestRoute, "", 1)
 		if _, err := os.Stat(targetFile); os.IsNotExist(err) {
 			// targetFile not exists
-			http.Redirect(w, r, dest.RequestRoute, http.StatusFound)
+			writeErrorPage(w, r, app, srcIP, http.StatusNotFound, "")
 			return
 		}
 		http.StripPrefix(dest.RequestRoute, staticHandler).ServeHTTP(w, r)
@@ -374,9 +437,8 @@ func ReverseHandlerFunc(w http.ResponseWriter, r *http.Request) {
 	} else if dest.RouteType == models.FastCGIRoute {
 		// FastCGI
 		connFactory := gofast.SimpleConnFactory("tcp", targetDest)
-		urlPath := utils.GetRoutePath(r.URL.Path)
 		newPath := r.URL.Path
-		if urlPath != "/" {
+		if dest.RouteMatch == models.RouteMatch_PREFIX && strings.HasPrefix(dest.RequestRoute, "/") && dest.RequestRoute != "/" {
 			newPath = strings.Replace(r.URL.Path, dest.RequestRoute, "/", 1)
 		}
 		fastCGIHandler := gofast.NewHandler(
@@ -390,177 +452,74 @@ func ReverseHandlerFunc(w http.ResponseWriter, r *http.Request) {
 		targetDest = backend.SelectPodFromDestination(dest, srcIP, r)
 	}
 
-	// var transport http.RoundTripper
-	transport := &http.Transport{
-		TLSHandshakeTimeout:   60 * time.Second,
-		ResponseHeaderTimeout: 60 * time.Second,
-		IdleConnTimeout:       60 * time.Second,
-		ExpectContinueTimeout: 30 * time.Second,
-		MaxIdleConns:          100,
-		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-			dest.Mutex.Lock()
-			defer dest.Mutex.Unlock()
-			dest.CheckTime = nowTimeStamp
-			conn, err := net.Dial("tcp", targetDest)
-			if err != nil {
-				backend.SetDestinationOffline(dest)
-				timeout := time.Now().Unix() - nowTimeStamp
-				utils.DebugPrintln("DialContext error", err, timeout, "seconds")
-				if !dest.Online {
-					errInfo := &models.InternalErrorInfo{
-						Description: "Internal Server Offline",
-					}
-					GenerateInternalErrorResponse(w, errInfo)
-				}
-			}
-			return conn, err
-		},
-		DialTLS: func(network, addr string) (net.Conn, error) {
-			dest.CheckTime = nowTimeStamp
-			conn, err := net.Dial("tcp", targetDest)
-			if err != nil {
-				backend.SetDestinationOffline(dest)
-				timeout := time.Now().Unix() - nowTimeStamp
-				utils.DebugPrintln("DialTLS error", err, timeout, "seconds")
-				if !dest.Online {
-					errInfo := &models.InternalErrorInfo{
-						Description: "Internal Server Offline",
-					}
-					GenerateInternalErrorResponse(w, errInfo)
-				}
-				return nil, err
-			}
-			cfg := &tls.Config{
-				ServerName:         domainStr,
-				NextProtos:         []string{"h2", "http/1.1"},
-				MinVersion:         tls.VersionTLS12,
-				InsecureSkipVerify: true,
-			}
-			tlsConn := tls.Client(conn, cfg)
-			if err := tlsConn.Handshake(); err != nil {
-				utils.DebugPrintln("tlsConn.Handshake error", err)
-			}
-			return tlsConn, err //net.Dial("tcp", dest)
-		},
+	// Send to the selected destination, and retry on other destinations of the same route
+	upstream := &retryTransport{
+		app:        app,
+		srcIP:      srcIP,
+		urlPath:    urlPath,
+		group:      group,
+		dest:       dest,
+		targetDest: targetDest,
 	}
-	err := http2.ConfigureTransport(transport)
-	if err != nil {
-		utils.DebugPrintln("http2.ConfigureTransport error", err)
-	}
-
-	// Check static cache
-	if app.CacheEnabled {
-		isStatic := firewall.IsStaticResource(r)
-		if isStatic {
-			// First check Header Range, not cache for range
-			rangeValue := r.Header.Get("Range")
-			if rangeValue == "" {
-				staticRoot := fmt.Sprintf("./static/cdncache/%d", app.ID)
-				targetFile := staticRoot + r.URL.Path
-				// Check Static Cache
-				fi, err := os.Stat(targetFile)
-				if err == nil {
-					// Found targetFile
-					now := time.Now()
-					fiStat := fi.Sys().(*syscall.Stat_t)
-					// Use ctime fiStat.Ctim.Sec to mark the last check time
-					pastSeconds := now.Unix() - int64(fiStat.Ctim.Sec)
-					if pastSeconds > 1800 {
-						// check update
-						backendAddr := fmt.Sprintf("%s://%s%s", app.InternalScheme, targetDest, r.RequestURI)
-						req, err := http.NewRequest("GET", backendAddr, nil)
-						if err != nil {
-							utils.DebugPrintln("Check Update NewRequest", err)
-						}
-						if err == nil {
-							// copy request headers
-							for k := range r.Header {
-								req.Header.Set(k, r.Header.Get(k))
-							}
-							req.Header.Set("Host", domainStr)
-							modTimeGMT := fi.ModTime().UTC().Format(http.TimeFormat)
-							//If-Modified-Since: Sun, 14 Jun 2020 13:54:20 GMT
-							req.Header.Set("If-Modified-Since", modTimeGMT)
-							client := http.Client{
-								Transport: transport,
-							}
-							resp, err := client.Do(req)
-							if err != nil {
-								utils.DebugPrintln("Cache update Do", err)
-								return
-							}
-							defer resp.Body.Close()
-							if resp.StatusCode == http.StatusOK {
-								//fmt.Println("200", backendAddr)
-								bodyBuf, _ := io.ReadAll(resp.Body)
-								err = os.WriteFile(targetFile, bodyBuf, 0600)
-								if err != nil {
-									utils.DebugPrintln("CDN WriteFile", targetFile, err)
-								}
-								lastModifiedStr := resp.Header.Get("Last-Modified")
-								if len(lastModifiedStr) == 0 {
-									err = os.Chtimes(targetFile, now, now)
-									if err != nil {
-										utils.DebugPrintln("CDN Chtimes", targetFile, err)
-									}
-								} else {
-									lastModified, err := time.Parse(http.TimeFormat, lastModifiedStr)
-									if err != nil {
-										utils.DebugPrintln("CDN Parse Last-Modified", targetFile, err)
-									}
-									err = os.Chtimes(targetFile, now, lastModified)
-									if err != nil {
-										utils.DebugPrintln("CDN Chtimes", targetFile, err)
-									}
-								}
-							} else if resp.StatusCode == http.StatusNotModified {
-								//fmt.Println("304", backendAddr)
-								err := os.Chtimes(targetFile, now, fi.ModTime())
-								if err != nil {
-									utils.DebugPrintln("Cache update access time", err)
-								}
-							}
-						}
-					}
-					acceptEncoding := r.Header.Get("Accept-Encoding")
-					if strings.Contains(acceptEncoding, "br") {
-						w.Header().Set("Content-Encoding", "br")
-						brWriter := brotli.NewWriter(w)
-						defer brWriter.Close()
-						zipResponseWriter := models.ZipResponseWriter{
-							Writer:         brWriter,
-							ResponseWriter: w,
-						}
-						http.ServeFile(zipResponseWriter, r, targetFile)
-					} else if strings.Contains(acceptEncoding, "gzip") {
-						w.Header().Set("Content-Encoding", "gzip")
-						gzWriter := gzip.NewWriter(w)
-						defer gzWriter.Close()
-						zipResponseWriter := models.ZipResponseWriter{
-							Writer:         gzWriter,
-							ResponseWriter: w,
-						}
-						http.ServeFile(zipResponseWriter, r, targetFile)
-					} else {
-						http.ServeFile(w, r, targetFile)
-					}
-					return
-				}
-			}
-			// Has Range Header, or resource Not Found, Continue
-			// For static files, disable compression between gateway and backend
-			r.Header.Set("Accept-Encoding", "")
-		}
+	defer upstream.release()
+
+	// v1.5.3 Accept-Encoding is cleared for cacheable requests below, keep it for response compression
+	r = withAcceptEncoding(r, app)
+
+	// v1.5.3 HTTP cache, fresh responses are served without forwarding, stale ones are revalidated
+	if serveFromCache(w, r, app, cacheReq, upstream) {
+		return
 	}
+	r = withCacheRequest(r, cacheReq)
 
 	// Reverse Proxy
 	proxy := &httputil.ReverseProxy{
-		Director: func(req *http.Request) {
-			//req.URL.Scheme = app.InternalScheme
-			//req.URL.Host = r.Host
+		// v1.5.3 Rewrite instead of Director, forwarding headers are set by the policy of application
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			setForwardedHeaders(pr.Out, pr.In, app, srcIP, domainStr)
 		},
-		Transport:      transport,
-		ModifyResponse: rewriteResponse}
+		Transport: upstream,
+		ModifyResponse: func(resp *http.Response) error {
+			// v1.5.3 revalidation and stale-if-error of HTTP cache, before the response is rewritten
+			handleCacheResponse(resp, app)
+			if err := rewriteResponse(resp); err != nil {
+				return err
+			}
+			// v1.5.3 compress after the response is inspected by WAF
+			compressResponse(resp, app)
+			setCacheStatus(resp)
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, req *http.Request, err error) {
+			if writeBodyError(w, req, app, srcIP, err) {
+				return
+			}
+			isTLSError := backend.IsUpstreamTLSError(err)
+			if isTLSError {
+				utils.DebugPrintln("ReverseProxy upstream TLS error", targetDest, err)
+			} else {
+				utils.DebugPrintln("ReverseProxy error", targetDest, err)
+			}
+			if serveStaleIfError(w, req, app, getCacheRequest(req)) {
+				return
+			}
+			if errors.Is(err, errResponseTimeout) {
+				writeErrorPage(w, req, app, srcIP, http.StatusGatewayTimeout, "")
+				return
+			}
+			if isTLSError {
+				writeErrorPage(w, req, app, srcIP, http.StatusBadGateway, "Internal Server TLS Handshake Failed")
+				return
+			}
+			dest.Mutex.RLock()
+			online := dest.Online
+			dest.Mutex.RUnlock()
+			description := ""
+			if !online {
+				description = "Internal Server Offline"
+			}
+			writeErrorPage(w, req, app, srcIP, http.StatusBadGateway, description)
+		}}
 	if utils.Debug {
 		dump, err := httputil.DumpRequest(r, true)
 		if err != nil {
@@ -569,6 +528,16 @@ func ReverseHandlerFunc(w http.ResponseWriter, r *http.Request) {
 		fmt.Println(string(dump))
 	}
 	r.Host = domainStr
+	if err := prepareRetryBody(r, app); err != nil {
+		if !writeBodyError(w, r, app, srcIP, err) {
+			writeErrorPage(w, r, app, srcIP, http.StatusBadRequest, "")
+		}
+		return
+	}
+	// v1.5.3 shadow requests, not counted in access statistics
+	mirrorRequest(r, app, dest)
+	r = withProxyAddrs(r, srcIP)
+	r = withConnectTimeout(r, app)
 	proxy.ServeHTTP(w, r)
 }
 
@@ -654,17 +623,22 @@ func GetClientIP(r *http.Request, app *models.Application) (clientIP string) {
 	case models.IPMethod_REMOTE_ADDR:
 		clientIP, _, _ = net.SplitHostPort(r.RemoteAddr)
 		return clientIP
+	}
+	remoteIP, _, _ := net.SplitHostPort(r.RemoteAddr)
+	if len(app.TrustedProxies) > 0 && !isTrustedProxy(remoteIP, app.TrustedProxies) {
+		// v1.5.3 headers from untrusted sources are spoofable
+		return remoteIP
+	}
+	switch app.ClientIPMethod {
 	case models.IPMethod_X_FORWARDED_FOR:
-		xForwardedFor := r.Header.Get("X-Forwarded-For")
-		ips := strings.Split(xForwardedFor, ", ")
-		clientIP = ips[len(ips)-1]
+		clientIP = getForwardedForIP(r, app, remoteIP)
 	case models.IPMethod_X_REAL_IP:
 		clientIP = r.Header.Get("X-Real-IP")
 	case models.IPMethod_REAL_IP:
 		clientIP = r.Header.Get("Real-IP")
 	}
 	if len(clientIP) == 0 {
-		clientIP, _, _ = net.SplitHostPort(r.RemoteAddr)
+		clientIP = remoteIP
 	}
 	return clientIP
 }
@@ -0,0 +1,807 @@
+/*
+ * @Copyright Reserved By Janusec (https://www.janusec.com/).
+ * @Author: U2
+ * @Date: 2026-10-18 21:34:50
+ * @Last Modified: U2, 2026-10-18 21:34:50
+ */
+
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"janusec/firewall"
+	"janusec/models"
+	"janusec/utils"
+)
+
+// cache status in X-Cache header
+const (
+	cacheHit         = "HIT"
+	cacheMiss        = "MISS"
+	cacheExpired     = "EXPIRED"
+	cacheStale       = "STALE"
+	cacheRevalidated = "REVALIDATED"
+	cacheBypass      = "BYPASS"
+)
+
+const (
+	// defaultStaticTTL is the freshness lifetime of static resources without Cache-Control, Expires or Last-Modified
+	defaultStaticTTL = 1800
+	// maxHeuristicTTL is the max freshness lifetime calculated from Last-Modified
+	maxHeuristicTTL = 86400
+	// cacheKeepSeconds is how long the stale entries with validators are kept for revalidation
+	cacheKeepSeconds = 86400 * 7
+	// cacheRevalidateTimeout of the background revalidation
+	cacheRevalidateTimeout = 60 * time.Second
+)
+
+// cacheSkippedHeaders are not stored, Content-Length is set by the body when served
+var cacheSkippedHeaders = []string{"Connection", "Keep-Alive", "Proxy-Connection", "Proxy-Authenticate",
+	"Te", "Trailer", "Transfer-Encoding", "Upgrade", "Content-Length"}
+
+// defaultCacheCompression keep compressing cached responses if the compression of application is not configured
+var defaultCacheCompression = &models.CompressionConfig{Enabled: true}
+
+// cacheRevalidations map[variant key]bool, background revalidations in progress
+var cacheRevalidations sync.Map
+
+type cacheRequestKey struct{}
+
+// cacheRequest is the cache state of a request, kept in the context for the response of backend
+type cacheRequest struct {
+	appID      int64
+	primaryKey string
+	// url is host + path + normalized query
+	url  string
+	host string
+	rule *models.CacheRule
+	// defaultTTL is used if the response has no freshness information
+	defaultTTL int64
+	// cacheable requests are served from and stored in the cache
+	cacheable bool
+	status    string
+	// stale entry under revalidation
+	stale       *cacheEntry
+	requestTime int64
+	// rawHeader of the backend response before rewritten
+	rawHeader http.Header
+	// headers of the client, the request headers are changed for the backend
+	ifNoneMatch     string
+	ifModifiedSince string
+	acceptEncoding  string
+}
+
+// RoutineCleanCacheTick remove the cache entries which can not be served even as stale
+func RoutineCleanCacheTick() {
+	routineTicker := time.NewTicker(time.Duration(600) * time.Second)
+	for range routineTicker.C {
+		now := time.Now().Unix()
+		count := httpCache.RemoveMatched(func(entry *cacheEntry) bool {
+			return isCacheEntryExpired(entry, now)
+		})
+		if count > 0 {
+			utils.DebugPrintln("RoutineCleanCacheTick removed", count)
+		}
+	}
+}
+
+func isCacheEntryExpired(entry *cacheEntry, now int64) bool {
+	keepSeconds := entry.TTL + max(entry.StaleWhileRevalidate, entry.StaleIfError)
+	if hasValidator(entry.Header) {
+		keepSeconds = max(keepSeconds, entry.TTL+cacheKeepSeconds)
+	}
+	return currentAge(entry, now) > keepSeconds
+}
+
+// newCacheRequest return nil if the cache is not enabled, the key is used for invalidation even if not cacheable
+func newCacheRequest(r *http.Request, app *models.Application, host string) *cacheRequest {
+	if !app.CacheEnabled || httpCache == nil {
+		return nil
+	}
+	rule := getCacheRule(app, r.URL.Path)
+	cacheURL := strings.ToLower(host) + r.URL.Path
+	// the key uses the escaped path, the decoded path may contain control characters such as %0A
+	cacheKey := strings.ToLower(host) + r.URL.EscapedPath()
+	if query := normalizeCacheQuery(r.URL.RawQuery, rule); len(query) > 0 {
+		cacheURL += "?" + query
+		cacheKey += "?" + query
+	}
+	cacheReq := &cacheRequest{
+		appID:           app.ID,
+		primaryKey:      strconv.FormatInt(app.ID, 10) + "|" + cacheKey,
+		url:             cacheURL,
+		host:            host,
+		rule:            rule,
+		status:          cacheBypass,
+		ifNoneMatch:     r.Header.Get("If-None-Match"),
+		ifModifiedSince: r.Header.Get("If-Modified-Since"),
+		acceptEncoding:  r.Header.Get("Accept-Encoding"),
+	}
+	switch {
+	case r.Method != http.MethodGet && r.Method != http.MethodHead:
+	case len(r.Header.Get("Upgrade")) > 0:
+	case rule != nil && rule.Bypass:
+	case rule == nil && !firewall.IsStaticResource(r):
+	case parseCacheControl(r.Header).has("no-store"):
+	default:
+		cacheReq.cacheable = true
+		cacheReq.status = cacheMiss
+		if rule == nil {
+			// static resources, the same as the static cache of previous versions
+			cacheReq.defaultTTL = defaultStaticTTL
+		}
+	}
+	return cacheReq
+}
+
+// getCacheRule return the first rule matching the path
+func getCacheRule(app *models.Application, urlPath string) *models.CacheRule {
+	if app.CachePolicy == nil {
+		return nil
+	}
+	for _, rule := range app.CachePolicy.Rules {
+		if strings.HasPrefix(urlPath, rule.PathPrefix) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// normalizeCacheQuery remove the query string or keep the listed keys in sorted order
+func normalizeCacheQuery(rawQuery string, rule *models.CacheRule) string {
+	if rule == nil || len(rawQuery) == 0 {
+		return rawQuery
+	}
+	if rule.IgnoreQuery {
+		return ""
+	}
+	if len(rule.QueryKeys) == 0 {
+		return rawQuery
+	}
+	values, _ := url.ParseQuery(rawQuery)
+	kept := url.Values{}
+	for _, key := range rule.QueryKeys {
+		if value, ok := values[key]; ok {
+			kept[key] = value
+		}
+	}
+	return kept.Encode()
+}
+
+func withCacheRequest(r *http.Request, cacheReq *cacheRequest) *http.Request {
+	if cacheReq == nil {
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), cacheRequestKey{}, cacheReq))
+}
+
+func getCacheRequest(r *http.Request) *cacheRequest {
+	cacheReq, _ := r.Context().Value(cacheRequestKey{}).(*cacheRequest)
+	return cacheReq
+}
+
+// serveFromCache serve the fresh response, or the stale one while revalidating in background,
+// return false if the request should be forwarded to the backend, conditional headers are added for stale entries
+func serveFromCache(w http.ResponseWriter, r *http.Request, app *models.Application, cacheReq *cacheRequest, upstream *retryTransport) bool {
+	if cacheReq == nil || !cacheReq.cacheable {
+		return false
+	}
+	now := time.Now().Unix()
+	entry := httpCache.Lookup(cacheReq.primaryKey, r)
+	if entry != nil {
+		age := currentAge(entry, now)
+		allowed := isCachedAllowed(r, age)
+		if allowed && age < entry.TTL {
+			if serveCacheEntry(w, r, app, cacheReq, entry, cacheHit) {
+				return true
+			}
+			entry = nil
+		} else if allowed && age < entry.TTL+entry.StaleWhileRevalidate {
+			revalidateInBackground(r, app, cacheReq, entry, upstream)
+			if serveCacheEntry(w, r, app, cacheReq, entry, cacheStale) {
+				return true
+			}
+			entry = nil
+		}
+	}
+	// For cacheable requests, disable compression between gateway and backend
+	r.Header.Del("Accept-Encoding")
+	cacheReq.requestTime = now
+	if entry != nil {
+		cacheReq.status = cacheExpired
+		cacheReq.stale = entry
+		setConditionalHeaders(r, entry)
+	}
+	return false
+}
+
+// isCachedAllowed check Cache-Control of the request, no-cache and max-age=0 require revalidation
+func isCachedAllowed(r *http.Request, age int64) bool {
+	cc := parseCacheControl(r.Header)
+	if cc.has("no-cache") {
+		return false
+	}
+	if len(cc) == 0 && r.Header.Get("Pragma") == "no-cache" {
+		return false
+	}
+	if maxAge, ok := cc.seconds("max-age"); ok && age > maxAge {
+		return false
+	}
+	return true
+}
+
+// serveStaleIfError serve the cached response if the backend is offline or failed, within the stale-if-error window
+func serveStaleIfError(w http.ResponseWriter, r *http.Request, app *models.Application, cacheReq *cacheRequest) bool {
+	if cacheReq == nil || !cacheReq.cacheable {
+		return false
+	}
+	entry := cacheReq.stale
+	if entry == nil {
+		entry = httpCache.Lookup(cacheReq.primaryKey, r)
+	}
+	if entry == nil {
+		return false
+	}
+	age := currentAge(entry, time.Now().Unix())
+	status := cacheHit
+	if age >= entry.TTL {
+		if age >= entry.TTL+entry.StaleIfError {
+			return false
+		}
+		status = cacheStale
+	}
+	return serveCacheEntry(w, r, app, cacheReq, entry, status)
+}
+
+// serveCacheEntry write the cached response, the response headers are rewritten the same as the response of backend
+func serveCacheEntry(w http.ResponseWriter, r *http.Request, app *models.Application, cacheReq *cacheRequest, entry *cacheEntry, status string) bool {
+	body, err := httpCache.Body(entry)
+	if err != nil {
+		utils.DebugPrintln("serveCacheEntry", entry.URL, err)
+		httpCache.Delete(entry)
+		return false
+	}
+	req := r.WithContext(r.Context())
+	req.Host = cacheReq.host
+	resp := &http.Response{
+		Status:        strconv.Itoa(entry.StatusCode) + " " + http.StatusText(entry.StatusCode),
+		StatusCode:    entry.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        cachedHeader(entry, len(body)),
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+	if err := rewriteResponse(resp); err != nil {
+		utils.DebugPrintln("serveCacheEntry rewriteResponse", entry.URL, err)
+		return false
+	}
+	resp.Header.Set("X-Cache", status)
+	writeCacheResponse(w, r, app, cacheReq, resp)
+	countCacheStatus(cacheReq.appID, status)
+	return true
+}
+
+// writeCacheResponse handle Range and conditional requests, and compress the response if accepted
+func writeCacheResponse(w http.ResponseWriter, r *http.Request, app *models.Application, cacheReq *cacheRequest, resp *http.Response) {
+	defer resp.Body.Close()
+	header := w.Header()
+	if resp.StatusCode == http.StatusOK {
+		if len(r.Header.Get("Range")) > 0 {
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				utils.DebugPrintln("writeCacheResponse ReadAll", err)
+				return
+			}
+			for key, values := range resp.Header {
+				header[key] = values
+			}
+			header.Del("Content-Length")
+			// the conditional headers of the client, the request may be changed for revalidation
+			req := r.Clone(r.Context())
+			req.Header.Del("If-None-Match")
+			req.Header.Del("If-Modified-Since")
+			if len(cacheReq.ifNoneMatch) > 0 {
+				req.Header.Set("If-None-Match", cacheReq.ifNoneMatch)
+			}
+			if len(cacheReq.ifModifiedSince) > 0 {
+				req.Header.Set("If-Modified-Since", cacheReq.ifModifiedSince)
+			}
+			lastModified, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+			http.ServeContent(w, req, "", lastModified, bytes.NewReader(body))
+			return
+		}
+		if isNotModified(cacheReq.ifNoneMatch, cacheReq.ifModifiedSince, resp.Header) {
+			for key, values := range resp.Header {
+				header[key] = values
+			}
+			header.Del("Content-Length")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		compression := app.Compression
+		if compression == nil {
+			compression = defaultCacheCompression
+		}
+		if compression.Enabled {
+			compressResponseWith(resp, compression, cacheReq.acceptEncoding)
+		}
+	}
+	for key, values := range resp.Header {
+		header[key] = values
+	}
+	w.WriteHeader(resp.StatusCode)
+	if r.Method == http.MethodHead {
+		return
+	}
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		utils.DebugPrintln("writeCacheResponse Copy", err)
+	}
+}
+
+// revalidateInBackground send the conditional request to the backend after the stale response is served
+func revalidateInBackground(r *http.Request, app *models.Application, cacheReq *cacheRequest, entry *cacheEntry, upstream *retryTransport) {
+	if _, loaded := cacheRevalidations.LoadOrStore(entry.Key, true); loaded {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), cacheRevalidateTimeout)
+	req := r.Clone(ctx)
+	req.Method = http.MethodGet
+	req.Body = http.NoBody
+	req.ContentLength = 0
+	req.RequestURI = ""
+	req.Host = cacheReq.host
+	for _, name := range []string{"Connection", "Keep-Alive", "Upgrade", "Te", "Accept-Encoding", "Range", "If-Range", "If-Match", "If-Unmodified-Since"} {
+		req.Header.Del(name)
+	}
+	setForwardedHeaders(req, r, app, upstream.srcIP, cacheReq.host)
+	setConditionalHeaders(req, entry)
+	req = withProxyAddrs(withConnectTimeout(req, app), upstream.srcIP)
+	bgCacheReq := *cacheReq
+	bgCacheReq.status = cacheExpired
+	bgCacheReq.stale = entry
+	bgCacheReq.requestTime = time.Now().Unix()
+	// retried destinations of the background request are released by itself
+	bgUpstream := *upstream
+	bgUpstream.retried = nil
+	go func() {
+		defer cancel()
+		defer bgUpstream.release()
+		defer cacheRevalidations.Delete(entry.Key)
+		resp, err := bgUpstream.RoundTrip(req)
+		if err != nil {
+			utils.DebugPrintln("revalidateInBackground", entry.URL, err)
+			return
+		}
+		defer resp.Body.Close()
+		now := time.Now().Unix()
+		if resp.StatusCode == http.StatusNotModified {
+			refreshCacheEntry(resp, app, &bgCacheReq, now)
+			return
+		}
+		newEntry := newCacheEntry(resp, app, &bgCacheReq, resp.Header, now)
+		if newEntry == nil || resp.ContentLength > httpCache.maxObjectSize {
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(resp.Body, httpCache.maxObjectSize+1))
+		if err != nil {
+			utils.DebugPrintln("revalidateInBackground ReadAll", entry.URL, err)
+			return
+		}
+		httpCache.Store(newEntry, body)
+	}()
+}
+
+// setConditionalHeaders replace the conditional headers by the validators of the entry
+func setConditionalHeaders(r *http.Request, entry *cacheEntry) {
+	r.Header.Del("If-None-Match")
+	r.Header.Del("If-Modified-Since")
+	if etag := entry.Header.Get("ETag"); len(etag) > 0 {
+		r.Header.Set("If-None-Match", etag)
+	}
+	if lastModified := entry.Header.Get("Last-Modified"); len(lastModified) > 0 {
+		r.Header.Set("If-Modified-Since", lastModified)
+	}
+}
+
+// handleCacheResponse is called before the response of backend is rewritten, the response is replaced by
+// the cached one if revalidated or the backend failed within stale-if-error
+func handleCacheResponse(resp *http.Response, app *models.Application) {
+	cacheReq := getCacheRequest(resp.Request)
+	if cacheReq == nil {
+		return
+	}
+	if !cacheReq.cacheable {
+		invalidateCache(resp, cacheReq)
+		return
+	}
+	now := time.Now().Unix()
+	stale := cacheReq.stale
+	switch {
+	case stale != nil && resp.StatusCode == http.StatusNotModified:
+		entry, body := refreshCacheEntry(resp, app, cacheReq, now)
+		if entry != nil {
+			setCacheResponse(resp, cacheReq, entry, body, cacheRevalidated)
+		}
+	case stale != nil && resp.StatusCode >= http.StatusInternalServerError && currentAge(stale, now) < stale.TTL+stale.StaleIfError:
+		body, err := httpCache.Body(stale)
+		if err != nil {
+			utils.DebugPrintln("handleCacheResponse Body", stale.URL, err)
+			return
+		}
+		setCacheResponse(resp, cacheReq, stale, body, cacheStale)
+	default:
+		cacheReq.rawHeader = resp.Header.Clone()
+	}
+}
+
+// refreshCacheEntry update the stale entry by the headers of 304 response, RFC 9111 4.3.4
+func refreshCacheEntry(resp *http.Response, app *models.Application, cacheReq *cacheRequest, now int64) (*cacheEntry, []byte) {
+	stale := cacheReq.stale
+	body, err := httpCache.Body(stale)
+	if err != nil {
+		utils.DebugPrintln("refreshCacheEntry Body", stale.URL, err)
+		httpCache.Delete(stale)
+		return nil, nil
+	}
+	header := stale.Header.Clone()
+	for key, values := range resp.Header {
+		switch key {
+		case "Content-Encoding", "Content-Range":
+			continue
+		}
+		header[key] = values
+	}
+	for _, name := range cacheSkippedHeaders {
+		header.Del(name)
+	}
+	tags := stale.Tags
+	if len(header.Values("Surrogate-Key")) > 0 {
+		tags = parseSurrogateKey(header)
+	}
+	entry := &cacheEntry{
+		Key:          stale.Key,
+		AppID:        stale.AppID,
+		URL:          stale.URL,
+		StatusCode:   stale.StatusCode,
+		Header:       header,
+		Vary:         stale.Vary,
+		RequestTime:  cacheReq.requestTime,
+		ResponseTime: now,
+		Tags:         tags,
+	}
+	cc := parseCacheControl(header)
+	if cc.has("no-store") || cc.has("private") {
+		httpCache.Delete(stale)
+		return entry, body
+	}
+	setCacheLifetime(entry, cc, app, cacheReq)
+	httpCache.Store(entry, body)
+	return entry, body
+}
+
+// setCacheResponse replace the response of backend by the cached one, 304 if the client has a matched validator
+func setCacheResponse(resp *http.Response, cacheReq *cacheRequest, entry *cacheEntry, body []byte, status string) {
+	resp.Body.Close()
+	resp.StatusCode = entry.StatusCode
+	resp.Header = cachedHeader(entry, len(body))
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Trailer = nil
+	if resp.StatusCode == http.StatusOK && isNotModified(cacheReq.ifNoneMatch, cacheReq.ifModifiedSince, resp.Header) {
+		resp.StatusCode = http.StatusNotModified
+		resp.Header.Del("Content-Length")
+		resp.Body = http.NoBody
+		resp.ContentLength = 0
+	}
+	resp.Status = strconv.Itoa(resp.StatusCode) + " " + http.StatusText(resp.StatusCode)
+	cacheReq.status = status
+}
+
+// setCacheStatus add X-Cache header to the response of backend, Surrogate-Key is only used by the gateway
+func setCacheStatus(resp *http.Response) {
+	if cacheReq := getCacheRequest(resp.Request); cacheReq != nil {
+		resp.Header.Set("X-Cache", cacheReq.status)
+		resp.Header.Del("Surrogate-Key")
+		countCacheStatus(cacheReq.appID, cacheReq.status)
+	}
+}
+
+// invalidateCache remove the stored responses of the URL after an unsafe request succeeded, RFC 9111 4.4
+func invalidateCache(resp *http.Response, cacheReq *cacheRequest) {
+	switch resp.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return
+	}
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusBadRequest {
+		return
+	}
+	httpCache.DeletePrimary(cacheReq.primaryKey)
+}
+
+// storeCacheResponse store the response of backend while it is sent to the client
+func storeCacheResponse(resp *http.Response, app *models.Application) {
+	cacheReq := getCacheRequest(resp.Request)
+	if cacheReq == nil || cacheReq.rawHeader == nil {
+		return
+	}
+	entry := newCacheEntry(resp, app, cacheReq, cacheReq.rawHeader, time.Now().Unix())
+	if entry == nil || resp.ContentLength > httpCache.maxObjectSize {
+		return
+	}
+	resp.Body = &cacheBodyTee{
+		ReadCloser: resp.Body,
+		entry:      entry,
+		limit:      httpCache.maxObjectSize,
+	}
+}
+
+// cacheBodyTee store the body when it is read to the end
+type cacheBodyTee struct {
+	io.ReadCloser
+	entry *cacheEntry
+	buf   bytes.Buffer
+	limit int64
+	done  bool
+}
+
+func (tee *cacheBodyTee) Read(p []byte) (int, error) {
+	n, err := tee.ReadCloser.Read(p)
+	if tee.done {
+		return n, err
+	}
+	tee.buf.Write(p[:n])
+	if int64(tee.buf.Len()) > tee.limit {
+		tee.done = true
+		tee.buf = bytes.Buffer{}
+		return n, err
+	}
+	if err == io.EOF {
+		tee.done = true
+		go httpCache.Store(tee.entry, tee.buf.Bytes())
+	}
+	return n, err
+}
+
+// newCacheEntry return nil if the response is not storable
+func newCacheEntry(resp *http.Response, app *models.Application, cacheReq *cacheRequest, header http.Header, responseTime int64) *cacheEntry {
+	req := resp.Request
+	if req.Method != http.MethodGet || !isStorableStatus(resp.StatusCode) {
+		return nil
+	}
+	cc := parseCacheControl(header)
+	if cc.has("no-store") || cc.has("private") || len(header.Values("Set-Cookie")) > 0 {
+		return nil
+	}
+	if len(req.Header.Get("Authorization")) > 0 && !cc.has("public") && !cc.has("s-maxage") && !cc.has("must-revalidate") {
+		return nil
+	}
+	if encoding := header.Get("Content-Encoding"); len(encoding) > 0 && encoding != "identity" {
+		// the backend ignored the request without Accept-Encoding
+		return nil
+	}
+	vary, ok := parseVary(header)
+	if !ok {
+		return nil
+	}
+	storedHeader := header.Clone()
+	for _, name := range cacheSkippedHeaders {
+		storedHeader.Del(name)
+	}
+	tags := parseSurrogateKey(storedHeader)
+	entry := &cacheEntry{
+		Key:          variantKey(cacheReq.primaryKey, vary, req),
+		PrimaryKey:   cacheReq.primaryKey,
+		AppID:        cacheReq.appID,
+		URL:          cacheReq.url,
+		StatusCode:   resp.StatusCode,
+		Header:       storedHeader,
+		Vary:         vary,
+		RequestTime:  cacheReq.requestTime,
+		ResponseTime: responseTime,
+		Tags:         tags,
+	}
+	setCacheLifetime(entry, cc, app, cacheReq)
+	if entry.TTL <= 0 && !hasValidator(storedHeader) {
+		return nil
+	}
+	return entry
+}
+
+// isStorableStatus return true for the status codes cacheable by default, RFC 9110 15.1
+func isStorableStatus(statusCode int) bool {
+	switch statusCode {
+	case http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusNoContent, http.StatusMultipleChoices,
+		http.StatusMovedPermanently, http.StatusPermanentRedirect, http.StatusNotFound, http.StatusGone:
+		return true
+	}
+	return false
+}
+
+// setCacheLifetime set the freshness lifetime and the stale windows of the entry
+func setCacheLifetime(entry *cacheEntry, cc cacheControl, app *models.Application, cacheReq *cacheRequest) {
+	entry.TTL = freshnessLifetime(entry.Header, cc, cacheReq.rule, cacheReq.defaultTTL, entry.ResponseTime)
+	if cc.has("must-revalidate") || cc.has("proxy-revalidate") || cc.has("s-maxage") || cc.has("no-cache") {
+		// stale responses must not be served
+		return
+	}
+	if app.CachePolicy != nil {
+		entry.StaleWhileRevalidate = app.CachePolicy.StaleWhileRevalidate
+		entry.StaleIfError = app.CachePolicy.StaleIfError
+	}
+	if seconds, ok := cc.seconds("stale-while-revalidate"); ok {
+		entry.StaleWhileRevalidate = seconds
+	}
+	if seconds, ok := cc.seconds("stale-if-error"); ok {
+		entry.StaleIfError = seconds
+	}
+}
+
+// freshnessLifetime by the TTL of rule, s-maxage, max-age, Expires, or heuristic from Last-Modified, RFC 9111 4.2.1
+func freshnessLifetime(header http.Header, cc cacheControl, rule *models.CacheRule, defaultTTL int64, responseTime int64) int64 {
+	if rule != nil && rule.TTL > 0 {
+		return rule.TTL
+	}
+	if cc.has("no-cache") {
+		return 0
+	}
+	if seconds, ok := cc.seconds("s-maxage"); ok {
+		return seconds
+	}
+	if seconds, ok := cc.seconds("max-age"); ok {
+		return seconds
+	}
+	date := responseTime
+	if dateTime, err := http.ParseTime(header.Get("Date")); err == nil {
+		date = dateTime.Unix()
+	}
+	if expires := header.Get("Expires"); len(expires) > 0 {
+		expiresTime, err := http.ParseTime(expires)
+		if err != nil {
+			// invalid Expires such as 0 means already expired
+			return 0
+		}
+		return max(0, expiresTime.Unix()-date)
+	}
+	if lastModified, err := http.ParseTime(header.Get("Last-Modified")); err == nil && date > lastModified.Unix() {
+		return min((date-lastModified.Unix())/10, maxHeuristicTTL)
+	}
+	return defaultTTL
+}
+
+// currentAge of the entry, RFC 9111 4.2.3
+func currentAge(entry *cacheEntry, now int64) int64 {
+	date := entry.ResponseTime
+	if dateTime, err := http.ParseTime(entry.Header.Get("Date")); err == nil {
+		date = dateTime.Unix()
+	}
+	apparentAge := max(0, entry.ResponseTime-date)
+	ageValue, _ := strconv.ParseInt(entry.Header.Get("Age"), 10, 64)
+	correctedAge := max(0, ageValue) + entry.ResponseTime - entry.RequestTime
+	return max(apparentAge, correctedAge) + now - entry.ResponseTime
+}
+
+// cachedHeader is the stored headers with Age and Content-Length
+func cachedHeader(entry *cacheEntry, size int) http.Header {
+	header := entry.Header.Clone()
+	header.Set("Age", strconv.FormatInt(currentAge(entry, time.Now().Unix()), 10))
+	header.Set("Content-Length", strconv.Itoa(size))
+	return header
+}
+
+func hasValidator(header http.Header) bool {
+	return len(header.Get("ETag")) > 0 || len(header.Get("Last-Modified")) > 0
+}
+
+// isNotModified evaluate If-None-Match with weak comparison, or If-Modified-Since if no If-None-Match, RFC 9110 13.2.2
+func isNotModified(ifNoneMatch string, ifModifiedSince string, header http.Header) bool {
+	if len(ifNoneMatch) > 0 {
+		etag := strings.TrimPrefix(header.Get("ETag"), "W/")
+		if len(etag) == 0 {
+			return false
+		}
+		for _, item := range strings.Split(ifNoneMatch, ",") {
+			item = strings.TrimSpace(item)
+			if item == "*" || strings.TrimPrefix(item, "W/") == etag {
+				return true
+			}
+		}
+		return false
+	}
+	if len(ifModifiedSince) == 0 {
+		return false
+	}
+	since, err := http.ParseTime(ifModifiedSince)
+	if err != nil {
+		return false
+	}
+	lastModified, err := http.ParseTime(header.Get("Last-Modified"))
+	if err != nil {
+		return false
+	}
+	return !lastModified.After(since)
+}
+
+// parseVary return the sorted header names, false if Vary: * , Accept-Encoding is skipped
+// since the backend is requested without Accept-Encoding
+func parseVary(header http.Header) ([]string, bool) {
+	vary := []string{}
+	for _, value := range header.Values("Vary") {
+		for _, item := range strings.Split(value, ",") {
+			name := http.CanonicalHeaderKey(strings.TrimSpace(item))
+			switch name {
+			case "", "Accept-Encoding":
+				continue
+			case "*":
+				return nil, false
+			}
+			if !slices.Contains(vary, name) {
+				vary = append(vary, name)
+			}
+		}
+	}
+	sort.Strings(vary)
+	return vary, true
+}
+
+// parseSurrogateKey return the space separated tags and remove the header
+func parseSurrogateKey(header http.Header) []string {
+	tags := []string{}
+	for _, value := range header.Values("Surrogate-Key") {
+		for _, tag := range strings.Fields(value) {
+			if !slices.Contains(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	header.Del("Surrogate-Key")
+	return tags
+}
+
+// cacheControl is the directives of Cache-Control, names are in lower case
+type cacheControl map[string]string
+
+func parseCacheControl(header http.Header) cacheControl {
+	cc := cacheControl{}
+	for _, value := range header.Values("Cache-Control") {
+		for _, item := range strings.Split(value, ",") {
+			name, arg, _ := strings.Cut(strings.TrimSpace(item), "=")
+			name = strings.ToLower(strings.TrimSpace(name))
+			if len(name) > 0 {
+				cc[name] = strings.Trim(strings.TrimSpace(arg), `"`)
+			}
+		}
+	}
+	return cc
+}
+
+func (cc cacheControl) has(name string) bool {
+	_, ok := cc[name]
+	return ok
+}
+
+// seconds return the delta-seconds argument of the directive
+func (cc cacheControl) seconds(name string) (int64, bool) {
+	arg, ok := cc[name]
+	if !ok {
+		return 0, false
+	}
+	seconds, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	return seconds, true
+}
@@ -0,0 +1,385 @@
+/*
+ * @Copyright Reserved By Janusec (https://www.janusec.com/).
+ * @Author: U2
+ * @Date: 2026-10-18 22:41:07
+ * @Last Modified: U2, 2026-10-18 22:41:07
+ */
+
+package gateway
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"janusec/backend"
+	"janusec/data"
+	"janusec/models"
+	"janusec/utils"
+)
+
+// maxCachePurges kept by the primary node for replica nodes
+const maxCachePurges = 1000
+
+var (
+	// cachePurges is the latest purge commands on the primary node, in order of ID
+	cachePurges      = []*models.CachePurge{}
+	cachePurgesMutex sync.Mutex
+
+	// lastCachePurgeID is the ID of latest purge command applied by the replica node
+	lastCachePurgeID int64
+
+	// cacheCounters map[appID int64]*cacheCounter
+	cacheCounters sync.Map
+
+	// replicaCacheStats map[node IP string]*replicaCacheStat, reported by replica nodes
+	replicaCacheStats sync.Map
+)
+
+// cacheCounter of the cache status of an application
+type cacheCounter struct {
+	hits        atomic.Int64
+	stale       atomic.Int64
+	revalidated atomic.Int64
+	misses      atomic.Int64
+	bypass      atomic.Int64
+}
+
+type replicaCacheStat struct {
+	stats      []*models.CacheStat
+	updateTime int64
+}
+
+// PurgeCache remove the cache entries of an application, by URL, prefix or tag, replica nodes purge after sync
+func PurgeCache(body []byte, clientIP string, authUser *models.AuthUser) (*models.CachePurge, error) {
+	var purgeRequest models.APICachePurgeRequest
+	if err := json.Unmarshal(body, &purgeRequest); err != nil {
+		utils.DebugPrintln("PurgeCache", err)
+		return nil, err
+	}
+	app, err := backend.GetApplicationByID(purgeRequest.ObjectID)
+	if err != nil {
+		return nil, err
+	}
+	if !authUser.IsAppAdmin && !authUser.IsSuperAdmin && app.Owner != authUser.Username {
+		return nil, errors.New("no privilege to perform this operation")
+	}
+	purge := purgeRequest.Object
+	if purge == nil {
+		return nil, errors.New("purge command is empty")
+	}
+	value := strings.TrimSpace(purge.Value)
+	switch purge.Type {
+	case models.CachePurge_APP:
+		value = ""
+	case models.CachePurge_URL, models.CachePurge_PREFIX, models.CachePurge_TAG:
+		if len(value) == 0 {
+			return nil, errors.New("value of purge command is empty")
+		}
+	default:
+		return nil, errors.New("unknown purge type")
+	}
+	switch purge.Type {
+	case models.CachePurge_URL:
+		value, err = normalizePurgeURL(app, value)
+		if err != nil {
+			return nil, err
+		}
+	case models.CachePurge_PREFIX:
+		value = normalizePurgePrefix(value)
+	}
+	purge = &models.CachePurge{
+		ID:        utils.GenSnowflakeID(),
+		AppID:     app.ID,
+		Type:      purge.Type,
+		Value:     value,
+		PurgeTime: time.Now().Unix(),
+	}
+	purge.Purged = int64(applyCachePurges([]*models.CachePurge{purge}))
+	cachePurgesMutex.Lock()
+	cachePurges = append(cachePurges, purge)
+	if len(cachePurges) > maxCachePurges {
+		cachePurges = slices.Clone(cachePurges[len(cachePurges)-maxCachePurges:])
+	}
+	data.NodeSetting.CachePurgeLastID = purge.ID
+	cachePurgesMutex.Unlock()
+	go utils.OperationLog(clientIP, authUser.Username, "Purge Cache", app.Name+" "+string(purge.Type)+" "+purge.Value)
+	return purge, nil
+}
+
+// normalizePurgeURL return host + path + normalized query the same as the cache key,
+// or path + query for all domains of the application if the host is not given
+func normalizePurgeURL(app *models.Application, value string) (string, error) {
+	host, pathQuery := splitPurgeURL(value)
+	purgeURL, err := url.Parse(pathQuery)
+	if err != nil {
+		return "", err
+	}
+	value = host + purgeURL.Path
+	if query := normalizeCacheQuery(purgeURL.RawQuery, getCacheRule(app, purgeURL.Path)); len(query) > 0 {
+		value += "?" + query
+	}
+	return value, nil
+}
+
+// normalizePurgePrefix remove the scheme and port, the host is in lower case
+func normalizePurgePrefix(value string) string {
+	host, pathPrefix := splitPurgeURL(value)
+	if unescaped, err := url.PathUnescape(pathPrefix); err == nil {
+		pathPrefix = unescaped
+	}
+	return host + pathPrefix
+}
+
+// splitPurgeURL split the value into host and path, the host is empty if the value starts with /
+func splitPurgeURL(value string) (string, string) {
+	if _, rest, ok := strings.Cut(value, "://"); ok {
+		value = rest
+	}
+	if strings.HasPrefix(value, "/") {
+		return "", value
+	}
+	host, path, ok := strings.Cut(value, "/")
+	path = "/" + path
+	if !ok {
+		path = "/"
+	}
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+	return strings.ToLower(host), path
+}
+
+// applyCachePurges remove the entries matching any of the purge commands, return the number of entries removed
+func applyCachePurges(purges []*models.CachePurge) int {
+	if httpCache == nil || len(purges) == 0 {
+		return 0
+	}
+	// appPurges map[appID int64][]*models.CachePurge
+	appPurges := map[int64][]*models.CachePurge{}
+	for _, purge := range purges {
+		appPurges[purge.AppID] = append(appPurges[purge.AppID], purge)
+	}
+	return httpCache.RemoveMatched(func(entry *cacheEntry) bool {
+		for _, purge := range appPurges[entry.AppID] {
+			if isCachePurgeMatched(entry, purge) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// isCachePurgeMatched check the entry stored before the purge command
+func isCachePurgeMatched(entry *cacheEntry, purge *models.CachePurge) bool {
+	if entry.ResponseTime > purge.PurgeTime {
+		return false
+	}
+	entryURL := entry.URL
+	if strings.HasPrefix(purge.Value, "/") {
+		// all domains
+		index := strings.Index(entryURL, "/")
+		if index < 0 {
+			return false
+		}
+		entryURL = entryURL[index:]
+	}
+	switch purge.Type {
+	case models.CachePurge_APP:
+		return true
+	case models.CachePurge_URL:
+		return entryURL == purge.Value
+	case models.CachePurge_PREFIX:
+		return strings.HasPrefix(entryURL, purge.Value)
+	case models.CachePurge_TAG:
+		return slices.Contains(entry.Tags, purge.Value)
+	}
+	return false
+}
+
+// GetCachePurges return the purge commands after the ID, for replica nodes
+func GetCachePurges(lastID int64) []*models.CachePurge {
+	cachePurgesMutex.Lock()
+	defer cachePurgesMutex.Unlock()
+	purges := []*models.CachePurge{}
+	for _, purge := range cachePurges {
+		if purge.ID > lastID {
+			purges = append(purges, purge)
+		}
+	}
+	return purges
+}
+
+// RPCGetCachePurges receive RPC request from replica nodes
+func RPCGetCachePurges(r *http.Request) ([]*models.CachePurge, error) {
+	var purgesReq models.RPCCachePurgesRequest
+	if err := json.NewDecoder(r.Body).Decode(&purgesReq); err != nil {
+		utils.DebugPrintln("RPCGetCachePurges Decode", err)
+		return nil, err
+	}
+	defer r.Body.Close()
+	return GetCachePurges(purgesReq.Object), nil
+}
+
+// SyncCachePurges let replica nodes apply the purge commands after lastCachePurgeID,
+// all commands kept by the primary node are applied after started, since they only remove the entries stored before
+func SyncCachePurges(cachePurgeLastID int64) {
+	rpcRequest := &models.RPCRequest{Action: "get_cache_purges", Object: lastCachePurgeID}
+	resp, err := data.GetRPCResponse(rpcRequest)
+	if err != nil {
+		utils.DebugPrintln("SyncCachePurges GetRPCResponse", err)
+		return
+	}
+	rpcPurges := &models.RPCCachePurges{}
+	if err = json.Unmarshal(resp, rpcPurges); err != nil {
+		utils.DebugPrintln("SyncCachePurges Unmarshal", err)
+		return
+	}
+	if rpcPurges.Error != nil {
+		utils.DebugPrintln("SyncCachePurges", *rpcPurges.Error)
+		return
+	}
+	count := applyCachePurges(rpcPurges.Object)
+	utils.DebugPrintln("SyncCachePurges commands:", len(rpcPurges.Object), "removed:", count)
+	// the commands before cachePurgeLastID have been returned, even if the old ones were dropped by the primary node
+	lastCachePurgeID = max(lastCachePurgeID, cachePurgeLastID)
+	for _, purge := range rpcPurges.Object {
+		lastCachePurgeID = max(lastCachePurgeID, purge.ID)
+	}
+}
+
+// countCacheStatus increase the counter of the status
+func countCacheStatus(appID int64, status string) {
+	value, _ := cacheCounters.LoadOrStore(appID, &cacheCounter{})
+	counter := value.(*cacheCounter)
+	switch status {
+	case cacheHit:
+		counter.hits.Add(1)
+	case cacheStale:
+		counter.hits.Add(1)
+		counter.stale.Add(1)
+	case cacheRevalidated:
+		counter.hits.Add(1)
+		counter.revalidated.Add(1)
+	case cacheMiss, cacheExpired:
+		counter.misses.Add(1)
+	case cacheBypass:
+		counter.bypass.Add(1)
+	}
+}
+
+// getLocalCacheStats return the counters and the usage of the cache store on this node
+func getLocalCacheStats() []*models.CacheStat {
+	stats := map[int64]*models.CacheStat{}
+	if httpCache != nil {
+		stats = httpCache.Usage()
+	}
+	cacheCounters.Range(func(key, value any) bool {
+		appID := key.(int64)
+		counter := value.(*cacheCounter)
+		stat, ok := stats[appID]
+		if !ok {
+			stat = &models.CacheStat{AppID: appID}
+			stats[appID] = stat
+		}
+		stat.Hits = counter.hits.Load()
+		stat.Stale = counter.stale.Load()
+		stat.Revalidated = counter.revalidated.Load()
+		stat.Misses = counter.misses.Load()
+		stat.Bypass = counter.bypass.Load()
+		return true
+	})
+	localStats := []*models.CacheStat{}
+	for _, stat := range stats {
+		localStats = append(localStats, stat)
+	}
+	return localStats
+}
+
+// ReportCacheStats send the cache statistics of the replica node to the primary node
+func ReportCacheStats() {
+	stats := getLocalCacheStats()
+	if len(stats) == 0 {
+		return
+	}
+	rpcRequest := &models.RPCRequest{Action: "update_cache_stats", Object: stats}
+	if _, err := data.GetRPCResponse(rpcRequest); err != nil {
+		utils.DebugPrintln("RPC update_cache_stats", err)
+	}
+}
+
+// RPCUpdateCacheStats receive the cache statistics from replica nodes
+func RPCUpdateCacheStats(r *http.Request) error {
+	var statsReq models.RPCCacheStatsRequest
+	if err := json.NewDecoder(r.Body).Decode(&statsReq); err != nil {
+		utils.DebugPrintln("RPCUpdateCacheStats Decode", err)
+		return err
+	}
+	defer r.Body.Close()
+	srcIP, _, _ := net.SplitHostPort(r.RemoteAddr)
+	replicaCacheStats.Store(srcIP, &replicaCacheStat{stats: statsReq.Object, updateTime: time.Now().Unix()})
+	return nil
+}
+
+// GetCacheStats return the cache statistics of all nodes by application,
+// the statistics of replica nodes not reported in 3 sync intervals are skipped
+func GetCacheStats(authUser *models.AuthUser) ([]*models.CacheStat, error) {
+	apps, err := backend.GetApplications(authUser)
+	if err != nil {
+		return nil, err
+	}
+	// appStats map[appID int64]*models.CacheStat
+	appStats := map[int64]*models.CacheStat{}
+	addStats := func(stats []*models.CacheStat) {
+		for _, stat := range stats {
+			appStat, ok := appStats[stat.AppID]
+			if !ok {
+				appStat = &models.CacheStat{AppID: stat.AppID}
+				appStats[stat.AppID] = appStat
+			}
+			appStat.Hits += stat.Hits
+			appStat.Stale += stat.Stale
+			appStat.Revalidated += stat.Revalidated
+			appStat.Misses += stat.Misses
+			appStat.Bypass += stat.Bypass
+			appStat.Entries += stat.Entries
+			appStat.Size += stat.Size
+			appStat.MemorySize += stat.MemorySize
+			appStat.Nodes++
+		}
+	}
+	addStats(getLocalCacheStats())
+	expiredTime := time.Now().Unix() - int64(3*max(data.NodeSetting.SyncInterval, time.Minute)/time.Second)
+	replicaCacheStats.Range(func(key, value any) bool {
+		replicaStat := value.(*replicaCacheStat)
+		if replicaStat.updateTime < expiredTime {
+			replicaCacheStats.Delete(key)
+			return true
+		}
+		addStats(replicaStat.stats)
+		return true
+	})
+	stats := []*models.CacheStat{}
+	for _, app := range apps {
+		stat, ok := appStats[app.ID]
+		if !ok {
+			if !app.CacheEnabled {
+				continue
+			}
+			stat = &models.CacheStat{AppID: app.ID}
+		}
+		stat.AppName = app.Name
+		if total := stat.Hits + stat.Misses; total > 0 {
+			stat.HitRatio = float64(stat.Hits) / float64(total)
+		}
+		stats = append(stats, stat)
+	}
+	return stats, nil
+}
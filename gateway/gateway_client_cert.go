@@ -0,0 +1,65 @@
+/*
+ * @Copyright Reserved By Janusec (https://www.janusec.com/).
+ * @Author: U2
+ * @Date: 2026-10-18 22:57:03
+ * @Last Modified: U2, 2026-10-18 22:57:03
+ */
+
+package gateway
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"janusec/models"
+)
+
+// clientCertHeaders are forwarded to backends, the ones sent by clients are removed
+var clientCertHeaders = []string{
+	"X-Client-Cert-Verified",
+	"X-Client-Cert-Subject",
+	"X-Client-Cert-Issuer",
+	"X-Client-Cert-Serial",
+	"X-Client-Cert-Fingerprint",
+}
+
+// checkClientCert reject the request without the client certificate required by the application,
+// and forward the verified certificate to backends by headers, return false if rejected
+func checkClientCert(w http.ResponseWriter, r *http.Request, app *models.Application, srcIP string, domainStr string) bool {
+	clientAuth := app.ClientAuth
+	if clientAuth == nil || clientAuth.Mode == models.ClientAuth_NONE {
+		return true
+	}
+	for _, header := range clientCertHeaders {
+		r.Header.Del(header)
+	}
+	var leaf *x509.Certificate
+	if r.TLS != nil {
+		if !strings.EqualFold(r.TLS.ServerName, domainStr) {
+			// client certificates are requested by SNI, the Host must be the same
+			writeErrorPage(w, r, app, srcIP, http.StatusMisdirectedRequest, "")
+			return false
+		}
+		if len(r.TLS.VerifiedChains) > 0 {
+			leaf = r.TLS.VerifiedChains[0][0]
+		}
+	}
+	if leaf == nil {
+		if clientAuth.Mode == models.ClientAuth_REQUIRED {
+			writeErrorPage(w, r, app, srcIP, http.StatusForbidden, "Client Certificate Required")
+			return false
+		}
+		r.Header.Set("X-Client-Cert-Verified", "NONE")
+		return true
+	}
+	fingerprint := sha256.Sum256(leaf.Raw)
+	r.Header.Set("X-Client-Cert-Verified", "SUCCESS")
+	r.Header.Set("X-Client-Cert-Subject", leaf.Subject.String())
+	r.Header.Set("X-Client-Cert-Issuer", leaf.Issuer.String())
+	r.Header.Set("X-Client-Cert-Serial", strings.ToUpper(leaf.SerialNumber.Text(16)))
+	r.Header.Set("X-Client-Cert-Fingerprint", hex.EncodeToString(fingerprint[:]))
+	return true
+}
@@ -0,0 +1,322 @@
+/*
+ * @Copyright Reserved By Janusec (https://www.janusec.com/).
+ * @Author: U2
+ * @Date: 2026-10-18 20:15:33
+ * @Last Modified: U2, 2026-10-18 20:15:33
+ */
+
+package gateway
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+
+	"janusec/models"
+	"janusec/utils"
+)
+
+var (
+	defaultCompressAlgorithms   = []string{"br", "zstd", "gzip"}
+	defaultCompressContentTypes = []string{
+		"text/html", "text/css", "text/plain", "text/xml", "text/javascript", "text/csv",
+		"application/javascript", "application/json", "application/xml", "application/xhtml+xml",
+		"application/rss+xml", "application/atom+xml", "application/manifest+json", "application/wasm",
+		"image/svg+xml",
+	}
+)
+
+const defaultCompressMinSize = 1024
+
+type acceptEncodingKey struct{}
+
+// withAcceptEncoding keep Accept-Encoding of the client in context,
+// the header of the request may be cleared before forwarding to the backend
+func withAcceptEncoding(r *http.Request, app *models.Application) *http.Request {
+	if app.Compression == nil || !app.Compression.Enabled {
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), acceptEncodingKey{}, r.Header.Get("Accept-Encoding")))
+}
+
+// compressResponse compress the response if the backend did not, called after the response is inspected by WAF
+func compressResponse(resp *http.Response, app *models.Application) {
+	compression := app.Compression
+	if compression == nil || !compression.Enabled {
+		return
+	}
+	acceptEncoding, ok := resp.Request.Context().Value(acceptEncodingKey{}).(string)
+	if !ok {
+		return
+	}
+	compressResponseWith(resp, compression, acceptEncoding)
+}
+
+// compressResponseWith compress the response by the config and Accept-Encoding of the client
+func compressResponseWith(resp *http.Response, compression *models.CompressionConfig, acceptEncoding string) {
+	if resp.Body == nil || resp.Body == http.NoBody {
+		return
+	}
+	if resp.Request.Method == http.MethodHead || resp.StatusCode < http.StatusOK ||
+		resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusPartialContent || resp.StatusCode == http.StatusNotModified {
+		return
+	}
+	if contentEncoding := resp.Header.Get("Content-Encoding"); len(contentEncoding) > 0 && contentEncoding != "identity" {
+		// compressed by the backend
+		return
+	}
+	if len(resp.Header.Get("Content-Range")) > 0 || strings.Contains(resp.Header.Get("Cache-Control"), "no-transform") {
+		return
+	}
+	if !isCompressibleType(resp.Header.Get("Content-Type"), compression.ContentTypes) {
+		return
+	}
+	minSize := compression.MinSize
+	if minSize <= 0 {
+		minSize = defaultCompressMinSize
+	}
+	if resp.ContentLength >= 0 && resp.ContentLength < minSize {
+		return
+	}
+	// the representation depends on Accept-Encoding, even if not compressed for this client
+	addVary(resp.Header, "Accept-Encoding")
+	algorithms := compression.Algorithms
+	if len(algorithms) == 0 {
+		algorithms = defaultCompressAlgorithms
+	}
+	encoding := negotiateEncoding(acceptEncoding, algorithms)
+	if len(encoding) == 0 {
+		return
+	}
+	if resp.ContentLength < 0 {
+		// unknown length, read the head to check the minimum size
+		head := make([]byte, minSize)
+		n, err := io.ReadFull(resp.Body, head)
+		resp.Body = &readCloser{Reader: io.MultiReader(bytes.NewReader(head[:n]), resp.Body), Closer: resp.Body}
+		if err != nil {
+			// smaller than the minimum size, or the error is returned to the proxy by reading the body again
+			return
+		}
+	}
+	compressedBody := newCompressedBody(resp.Body, encoding)
+	if compressedBody == nil {
+		return
+	}
+	resp.Body = compressedBody
+	resp.Header.Set("Content-Encoding", encoding)
+	resp.Header.Del("Content-Length")
+	resp.Header.Del("Accept-Ranges")
+	resp.ContentLength = -1
+	// the compressed representation is not byte-identical
+	if etag := resp.Header.Get("ETag"); strings.HasPrefix(etag, "\"") {
+		resp.Header.Set("ETag", "W/"+etag)
+	}
+}
+
+// isCompressibleType check the media type with the list, text/* matches all text types except text/event-stream
+func isCompressibleType(contentType string, contentTypes []string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType == "text/event-stream" {
+		// events are flushed one by one by the client, not compressed even if text/* configured
+		return false
+	}
+	if len(contentTypes) == 0 {
+		contentTypes = defaultCompressContentTypes
+	}
+	for _, item := range contentTypes {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if item == mediaType {
+			return true
+		}
+		if strings.HasSuffix(item, "/*") && strings.HasPrefix(mediaType, item[:len(item)-1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// negotiateEncoding select the encoding with the highest q-value in Accept-Encoding,
+// the order of algorithms is used if the q-values are equal, empty means identity
+func negotiateEncoding(acceptEncoding string, algorithms []string) string {
+	qValues := map[string]float64{}
+	for _, item := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(item), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if len(coding) == 0 {
+			continue
+		}
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		qValues[coding] = q
+	}
+	selected := ""
+	selectedQ := 0.0
+	for _, algorithm := range algorithms {
+		q, ok := qValues[algorithm]
+		if !ok {
+			q, ok = qValues["*"]
+		}
+		if ok && q > selectedQ {
+			selected = algorithm
+			selectedQ = q
+		}
+	}
+	return selected
+}
+
+func addVary(header http.Header, name string) {
+	for _, value := range header.Values("Vary") {
+		for _, item := range strings.Split(value, ",") {
+			item = strings.TrimSpace(item)
+			if item == "*" || strings.EqualFold(item, name) {
+				return
+			}
+		}
+	}
+	header.Add("Vary", name)
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// compressEncoder is implemented by the writers of gzip, brotli and zstd
+type compressEncoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+var (
+	gzipWriterPool = sync.Pool{
+		New: func() interface{} {
+			return gzip.NewWriter(nil)
+		},
+	}
+	brotliWriterPool = sync.Pool{
+		New: func() interface{} {
+			return brotli.NewWriterLevel(nil, 4)
+		},
+	}
+	zstdEncoderPool = sync.Pool{
+		New: func() interface{} {
+			encoder, err := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1), zstd.WithLowerEncoderMem(true), zstd.WithWindowSize(zstdWindowSize))
+			if err != nil {
+				utils.DebugPrintln("zstd.NewWriter", err)
+				return nil
+			}
+			return encoder
+		},
+	}
+)
+
+// zstdWindowSize is enough for web resources, and keep the memory of pooled encoders low
+const zstdWindowSize = 1 << 20
+
+// compressChunkSize is the size of each read from the body of backend
+const compressChunkSize = 32 << 10
+
+func getEncoderPool(encoding string) *sync.Pool {
+	switch encoding {
+	case "br":
+		return &brotliWriterPool
+	case "zstd":
+		return &zstdEncoderPool
+	default:
+		return &gzipWriterPool
+	}
+}
+
+// compressedBody compress the body of backend when it is read, the encoder is taken from the pool
+type compressedBody struct {
+	mutex    sync.Mutex
+	body     io.ReadCloser
+	encoding string
+	encoder  compressEncoder
+	buf      bytes.Buffer
+	chunk    []byte
+	err      error
+}
+
+// newCompressedBody return nil if the encoder is not available
+func newCompressedBody(body io.ReadCloser, encoding string) *compressedBody {
+	encoder, ok := getEncoderPool(encoding).Get().(compressEncoder)
+	if !ok {
+		return nil
+	}
+	b := &compressedBody{body: body, encoding: encoding, encoder: encoder, chunk: make([]byte, compressChunkSize)}
+	encoder.Reset(&b.buf)
+	return b
+}
+
+func (b *compressedBody) Read(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for b.buf.Len() == 0 && b.err == nil {
+		n, err := b.body.Read(b.chunk)
+		if n > 0 {
+			if _, writeErr := b.encoder.Write(b.chunk[:n]); writeErr != nil {
+				b.finish(writeErr)
+				break
+			}
+			if n < len(b.chunk) && err == nil {
+				// the backend has no more data for now, such as streamed JSON, do not hold the output
+				if flushErr := b.encoder.Flush(); flushErr != nil {
+					b.finish(flushErr)
+					break
+				}
+			}
+		}
+		if err == io.EOF {
+			// flush the remaining data
+			if closeErr := b.encoder.Close(); closeErr != nil {
+				b.finish(closeErr)
+			} else {
+				b.finish(io.EOF)
+			}
+		} else if err != nil {
+			b.finish(err)
+		}
+	}
+	if b.buf.Len() > 0 {
+		return b.buf.Read(p)
+	}
+	return 0, b.err
+}
+
+// finish record the error and return the encoder to the pool
+func (b *compressedBody) finish(err error) {
+	b.err = err
+	if b.encoder != nil {
+		b.encoder.Reset(nil)
+		getEncoderPool(b.encoding).Put(b.encoder)
+		b.encoder = nil
+	}
+}
+
+// Close the body of backend is closed first to interrupt the pending read
+func (b *compressedBody) Close() error {
+	err := b.body.Close()
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.err == nil {
+		b.finish(http.ErrBodyReadAfterClose)
+	}
+	return err
+}
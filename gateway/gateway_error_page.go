@@ -0,0 +1,258 @@
+/*
+ * @Copyright Reserved By Janusec (https://www.janusec.com/).
+ * @Author: U2
+ * @Date: 2026-10-18 19:02:45
+ * @Last Modified: U2, 2026-10-18 19:02:45
+ */
+
+package gateway
+
+import (
+	"bytes"
+	"encoding/json"
+	"html/template"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+	"time"
+
+	"janusec/data"
+	"janusec/firewall"
+	"janusec/models"
+	"janusec/utils"
+)
+
+// errorTemplate is implemented by html/template for HTML and text/template for JSON
+type errorTemplate interface {
+	Execute(wr io.Writer, data any) error
+}
+
+// errorTemplates cache the parsed templates, map[html:|json: + template]*template, so that
+// the changes of application and global settings take effect without reloading
+var errorTemplates sync.Map
+
+var errorTemplateFuncs = texttemplate.FuncMap{
+	"json": func(v interface{}) string {
+		if text, ok := v.(jsonText); ok {
+			v = string(text)
+		}
+		jsonBytes, _ := json.Marshal(v)
+		return string(jsonBytes)
+	},
+}
+
+// jsonText is a string field of JSON error pages, printed with JSON escaping but without quotes,
+// so that client values such as Host and Path can not break the JSON if used without the json func
+type jsonText string
+
+func (text jsonText) String() string {
+	jsonBytes, _ := json.Marshal(string(text))
+	return string(jsonBytes[1 : len(jsonBytes)-1])
+}
+
+// jsonErrorPageInfo is the same as ErrorPageInfo with the string fields escaped for JSON templates
+type jsonErrorPageInfo struct {
+	StatusCode     int
+	StatusText     jsonText
+	Description    jsonText
+	RequestID      jsonText
+	SupportContact jsonText
+	Host           jsonText
+	Path           jsonText
+	ClientIP       jsonText
+	Time           jsonText
+}
+
+func newJSONErrorPageInfo(info *models.ErrorPageInfo) *jsonErrorPageInfo {
+	return &jsonErrorPageInfo{
+		StatusCode:     info.StatusCode,
+		StatusText:     jsonText(info.StatusText),
+		Description:    jsonText(info.Description),
+		RequestID:      jsonText(info.RequestID),
+		SupportContact: jsonText(info.SupportContact),
+		Host:           jsonText(info.Host),
+		Path:           jsonText(info.Path),
+		ClientIP:       jsonText(info.ClientIP),
+		Time:           jsonText(info.Time),
+	}
+}
+
+// writeErrorPage respond the error page of the application, app can be nil for unknown domains
+func writeErrorPage(w http.ResponseWriter, r *http.Request, app *models.Application, srcIP string, statusCode int, description string) {
+	info := newErrorPageInfo(r, app, srcIP, statusCode, description)
+	htmlTmpl, jsonTmpl := getErrorPageTemplates(app, statusCode)
+	renderErrorPage(w, r, info, htmlTmpl, jsonTmpl)
+}
+
+// newErrorPageInfo collect the variables of error page templates
+func newErrorPageInfo(r *http.Request, app *models.Application, srcIP string, statusCode int, description string) *models.ErrorPageInfo {
+	info := &models.ErrorPageInfo{
+		StatusCode:     statusCode,
+		StatusText:     http.StatusText(statusCode),
+		Description:    description,
+		RequestID:      getRequestID(r, app),
+		SupportContact: data.NodeSetting.SupportContact,
+		Host:           r.Host,
+		Path:           r.URL.Path,
+		ClientIP:       srcIP,
+		Time:           time.Now().UTC().Format(time.RFC3339),
+	}
+	if len(info.Description) == 0 {
+		info.Description = info.StatusText
+	}
+	if app != nil && app.ErrorPages != nil && len(app.ErrorPages.SupportContact) > 0 {
+		info.SupportContact = app.ErrorPages.SupportContact
+	}
+	return info
+}
+
+// getErrorPageTemplates return the HTML and JSON templates of the status, the global ones are used if not customized
+func getErrorPageTemplates(app *models.Application, statusCode int) (htmlTmpl string, jsonTmpl string) {
+	htmlTmpl = data.NodeSetting.ErrorPageHTML
+	jsonTmpl = data.NodeSetting.ErrorPageJSON
+	if app == nil || app.ErrorPages == nil {
+		return htmlTmpl, jsonTmpl
+	}
+	for _, page := range app.ErrorPages.Pages {
+		if page.StatusCode != statusCode {
+			continue
+		}
+		if len(page.HTML) > 0 {
+			htmlTmpl = page.HTML
+		}
+		if len(page.JSON) > 0 {
+			jsonTmpl = page.JSON
+		}
+		break
+	}
+	return htmlTmpl, jsonTmpl
+}
+
+// renderErrorPage write the HTML or JSON page selected by the Accept header, or grpc-status for gRPC
+func renderErrorPage(w http.ResponseWriter, r *http.Request, info *models.ErrorPageInfo, htmlTmpl string, jsonTmpl string) {
+	if firewall.IsGRPCRequest(r) {
+		// v1.5.3 gRPC clients can not parse HTML or JSON pages
+		w.Header().Set("X-Request-ID", info.RequestID)
+		writeGRPCError(w, grpcStatusByHTTP(info.StatusCode), info.Description)
+		return
+	}
+	isJSON := acceptsJSON(r)
+	contentType := "text/html; charset=utf-8"
+	tmplContent := htmlTmpl
+	if isJSON {
+		contentType = "application/json; charset=utf-8"
+		tmplContent = jsonTmpl
+	}
+	buf := &bytes.Buffer{}
+	if isJSON {
+		renderJSONErrorPage(buf, info, tmplContent)
+	} else if tmpl := getErrorTemplate(tmplContent, false); tmpl != nil {
+		if err := tmpl.Execute(buf, info); err != nil {
+			utils.DebugPrintln("renderErrorPage Execute", info.StatusCode, err)
+			buf.Reset()
+		}
+	}
+	if buf.Len() == 0 {
+		// no template or invalid template
+		contentType = "text/plain; charset=utf-8"
+		buf.WriteString(strconv.Itoa(info.StatusCode) + " " + info.StatusText)
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("X-Request-ID", info.RequestID)
+	w.WriteHeader(info.StatusCode)
+	_, err := w.Write(buf.Bytes())
+	if err != nil {
+		utils.DebugPrintln("renderErrorPage Write", err)
+	}
+}
+
+// renderJSONErrorPage execute the JSON template, the default one is used if the output is not valid JSON
+func renderJSONErrorPage(buf *bytes.Buffer, info *models.ErrorPageInfo, tmplContent string) {
+	jsonInfo := newJSONErrorPageInfo(info)
+	for _, content := range []string{tmplContent, data.DefaultErrorPageJSON} {
+		buf.Reset()
+		tmpl := getErrorTemplate(content, true)
+		if tmpl == nil {
+			continue
+		}
+		if err := tmpl.Execute(buf, jsonInfo); err != nil {
+			utils.DebugPrintln("renderJSONErrorPage Execute", info.StatusCode, err)
+			continue
+		}
+		if json.Valid(buf.Bytes()) {
+			return
+		}
+		utils.DebugPrintln("renderJSONErrorPage invalid JSON", info.StatusCode)
+	}
+	buf.Reset()
+}
+
+// getErrorTemplate return the parsed template, nil if empty or invalid
+func getErrorTemplate(content string, isJSON bool) errorTemplate {
+	if len(content) == 0 {
+		return nil
+	}
+	key := "html:" + content
+	if isJSON {
+		key = "json:" + content
+	}
+	if tmpl, ok := errorTemplates.Load(key); ok {
+		return tmpl.(errorTemplate)
+	}
+	var tmpl errorTemplate
+	if isJSON {
+		jsonTmpl, err := texttemplate.New("errorJSON").Funcs(errorTemplateFuncs).Parse(content)
+		if err != nil {
+			utils.DebugPrintln("getErrorTemplate Parse JSON", err)
+			return nil
+		}
+		tmpl = jsonTmpl
+	} else {
+		htmlTmpl, err := template.New("errorHTML").Funcs(template.FuncMap(errorTemplateFuncs)).Parse(content)
+		if err != nil {
+			utils.DebugPrintln("getErrorTemplate Parse HTML", err)
+			return nil
+		}
+		tmpl = htmlTmpl
+	}
+	errorTemplates.Store(key, tmpl)
+	return tmpl
+}
+
+// acceptsJSON check whether the client prefers JSON to HTML by the order of Accept header
+func acceptsJSON(r *http.Request) bool {
+	for _, item := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(item))
+		if err != nil {
+			continue
+		}
+		switch {
+		case mediaType == "text/html" || mediaType == "application/xhtml+xml":
+			return false
+		case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+			return true
+		}
+	}
+	return false
+}
+
+// getRequestID return the request ID used by header rules, or a new one
+func getRequestID(r *http.Request, app *models.Application) string {
+	if vars, ok := r.Context().Value(headerVarsKey{}).(*headerVars); ok {
+		return vars.requestID
+	}
+	return newRequestID(r, app)
+}
+
+// getRemoteIP is used for error pages before the application is found
+func getRemoteIP(r *http.Request) string {
+	ip, _, _ := net.SplitHostPort(r.RemoteAddr)
+	return ip
+}
@@ -0,0 +1,154 @@
+/*
+ * @Copyright Reserved By Janusec (https://www.janusec.com/).
+ * @Author: U2
+ * @Date: 2026-10-18 20:38:14
+ * @Last Modified: U2, 2026-10-18 20:38:14
+ */
+
+package gateway
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"janusec/backend"
+	"janusec/models"
+	"janusec/utils"
+)
+
+// trustedNets map[TrustedProxies]*[]*net.IPNet, parsed trusted proxies of applications
+var trustedNets = sync.Map{}
+
+// isTrustedProxy check whether ip is in trustedProxies, comma separated CIDR or IP
+func isTrustedProxy(ip string, trustedProxies string) bool {
+	return utils.IsIPInNets(net.ParseIP(ip), getTrustedNets(trustedProxies))
+}
+
+// isFromTrustedProxy check whether the peer is one of the trusted proxies of the application,
+// headers set by CDN or load balancers are spoofable if sent by others
+func isFromTrustedProxy(r *http.Request, app *models.Application) bool {
+	if app == nil || len(app.TrustedProxies) == 0 {
+		return false
+	}
+	remoteIP, _, _ := net.SplitHostPort(r.RemoteAddr)
+	return isTrustedProxy(remoteIP, app.TrustedProxies)
+}
+
+func getTrustedNets(trustedProxies string) []*net.IPNet {
+	if netsI, ok := trustedNets.Load(trustedProxies); ok {
+		return netsI.([]*net.IPNet)
+	}
+	nets := utils.ParseCIDRList(strings.Split(trustedProxies, ","))
+	trustedNets.Store(trustedProxies, nets)
+	return nets
+}
+
+// getForwardedForIP walk X-Forwarded-For right-to-left through trusted hops,
+// return the first address which is not a trusted proxy
+func getForwardedForIP(r *http.Request, app *models.Application, remoteIP string) string {
+	hops := []string{}
+	for _, value := range r.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(value, ",") {
+			hop = strings.TrimSpace(hop)
+			if len(hop) > 0 {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	if len(hops) == 0 {
+		return ""
+	}
+	if len(app.TrustedProxies) == 0 {
+		// compatible with versions before v1.5.3, use the address added by the nearest proxy
+		return hops[len(hops)-1]
+	}
+	clientIP := remoteIP
+	for i := len(hops) - 1; i >= 0; i-- {
+		if !isTrustedProxy(clientIP, app.TrustedProxies) {
+			break
+		}
+		if net.ParseIP(hops[i]) == nil {
+			// invalid address, stop at the last trusted hop
+			break
+		}
+		clientIP = hops[i]
+	}
+	return clientIP
+}
+
+// setForwardedHeaders set X-Forwarded-For, X-Forwarded-Proto, X-Forwarded-Host, X-Real-IP and Forwarded (RFC 7239)
+// according to the ForwardedPolicy of application, the forwarding headers of out have been removed by ReverseProxy
+func setForwardedHeaders(out *http.Request, in *http.Request, app *models.Application, srcIP string, host string) {
+	if app.ForwardedPolicy == models.ForwardedPolicy_NONE {
+		for _, name := range []string{"X-Forwarded-For", "X-Forwarded-Proto", "X-Forwarded-Host", "Forwarded"} {
+			if values := in.Header.Values(name); len(values) > 0 {
+				out.Header[name] = values
+			}
+		}
+		return
+	}
+	remoteIP, _, err := net.SplitHostPort(in.RemoteAddr)
+	if err != nil {
+		remoteIP = in.RemoteAddr
+	}
+	proto := "http"
+	if in.TLS != nil {
+		proto = "https"
+	}
+	if app.ForwardedPolicy == models.ForwardedPolicy_REPLACE {
+		out.Header.Set("X-Forwarded-For", srcIP)
+		out.Header.Set("X-Forwarded-Proto", proto)
+		out.Header.Set("X-Forwarded-Host", host)
+		out.Header.Set("Forwarded", formatForwarded(srcIP, proto, host))
+	} else {
+		xForwardedFor := remoteIP
+		if priorFor := strings.Join(in.Header.Values("X-Forwarded-For"), ", "); len(priorFor) > 0 {
+			xForwardedFor = priorFor + ", " + remoteIP
+		}
+		out.Header.Set("X-Forwarded-For", xForwardedFor)
+		// keep proto and host set by trusted proxies
+		trusted := len(app.TrustedProxies) > 0 && isTrustedProxy(remoteIP, app.TrustedProxies)
+		forwardedProto := in.Header.Get("X-Forwarded-Proto")
+		if !trusted || len(forwardedProto) == 0 {
+			forwardedProto = proto
+		}
+		out.Header.Set("X-Forwarded-Proto", forwardedProto)
+		forwardedHost := in.Header.Get("X-Forwarded-Host")
+		if !trusted || len(forwardedHost) == 0 {
+			forwardedHost = host
+		}
+		out.Header.Set("X-Forwarded-Host", forwardedHost)
+		forwarded := formatForwarded(remoteIP, proto, host)
+		if priorForwarded := strings.Join(in.Header.Values("Forwarded"), ", "); len(priorForwarded) > 0 {
+			forwarded = priorForwarded + ", " + forwarded
+		}
+		out.Header.Set("Forwarded", forwarded)
+	}
+	out.Header.Set("X-Real-IP", srcIP)
+}
+
+// formatForwarded IPv6 address is quoted with brackets, RFC 7239 section 6
+func formatForwarded(ip string, proto string, host string) string {
+	forValue := ip
+	if strings.Contains(ip, ":") {
+		forValue = `"[` + ip + `]"`
+	}
+	return "for=" + forValue + ";proto=" + proto + ";host=" + host
+}
+
+// withProxyAddrs carry the client address for destinations which require PROXY protocol, v1.5.3
+func withProxyAddrs(r *http.Request, srcIP string) *http.Request {
+	var src, dst net.Addr
+	if ip := net.ParseIP(srcIP); ip != nil {
+		_, port, _ := net.SplitHostPort(r.RemoteAddr)
+		srcPort, _ := strconv.Atoi(port)
+		src = &net.TCPAddr{IP: ip, Port: srcPort}
+	}
+	if localAddr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr); ok {
+		dst = localAddr
+	}
+	return r.WithContext(backend.WithProxyAddrs(r.Context(), src, dst))
+}
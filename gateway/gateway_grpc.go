@@ -0,0 +1,100 @@
+/*
+ * @Copyright Reserved By Janusec (https://www.janusec.com/).
+ * @Author: U2
+ * @Date: 2026-10-18 21:16:40
+ * @Last Modified: U2, 2026-10-18 21:16:40
+ */
+
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"janusec/utils"
+)
+
+// gRPC status codes, https://grpc.github.io/grpc/core/md_doc_statuscodes.html
+const (
+	grpcUnknown           = 2
+	grpcPermissionDenied  = 7
+	grpcResourceExhausted = 8
+	grpcUnimplemented     = 12
+	grpcInternal          = 13
+	grpcUnavailable       = 14
+	grpcUnauthenticated   = 16
+)
+
+type grpcBackendKey struct{}
+
+// withGRPCBackend mark the request forwarded to a gRPC backend, the response body is not inspected by WAF
+func withGRPCBackend(r *http.Request) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), grpcBackendKey{}, true))
+}
+
+// isGRPCBackendResponse check the mark of withGRPCBackend
+func isGRPCBackendResponse(resp *http.Response) bool {
+	grpcBackend, _ := resp.Request.Context().Value(grpcBackendKey{}).(bool)
+	return grpcBackend
+}
+
+// writeGRPCError respond a Trailers-Only gRPC response, grpc-status instead of HTML pages
+func writeGRPCError(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Content-Type", "application/grpc")
+	w.Header().Set("Grpc-Status", strconv.Itoa(code))
+	if len(message) > 0 {
+		w.Header().Set("Grpc-Message", encodeGRPCMessage(message))
+	}
+	// gRPC errors are always HTTP 200
+	w.WriteHeader(http.StatusOK)
+}
+
+// grpcStatusByHTTP map the HTTP status of error pages to gRPC status, same as the gRPC clients do
+func grpcStatusByHTTP(statusCode int) int {
+	switch statusCode {
+	case http.StatusBadRequest:
+		return grpcInternal
+	case http.StatusUnauthorized:
+		return grpcUnauthenticated
+	case http.StatusForbidden:
+		return grpcPermissionDenied
+	case http.StatusNotFound:
+		return grpcUnimplemented
+	case http.StatusRequestEntityTooLarge, http.StatusTooManyRequests:
+		return grpcResourceExhausted
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return grpcUnavailable
+	}
+	return grpcUnknown
+}
+
+// encodeGRPCMessage percent-encode the grpc-message, except printable ASCII
+func encodeGRPCMessage(message string) string {
+	var sb strings.Builder
+	for i := 0; i < len(message); i++ {
+		c := message[i]
+		if c >= ' ' && c <= '~' && c != '%' {
+			sb.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&sb, "%%%02X", c)
+	}
+	return sb.String()
+}
+
+// logGRPCAccess record the access log after the response finished, grpc-status is in headers or trailers
+func logGRPCAccess(w http.ResponseWriter, domainStr string, srcIP string, fullMethod string, ua string, start time.Time) {
+	status := w.Header().Get("Grpc-Status")
+	if len(status) == 0 {
+		// trailers not announced by the backend
+		status = w.Header().Get(http.TrailerPrefix + "Grpc-Status")
+	}
+	if len(status) == 0 {
+		status = "-"
+	}
+	go utils.GRPCAccessLog(domainStr, srcIP, fullMethod, status, time.Since(start), ua)
+}
@@ -0,0 +1,128 @@
+/*
+ * @Copyright Reserved By Janusec (https://www.janusec.com/).
+ * @Author: U2
+ * @Date: 2026-10-18 20:06:52
+ * @Last Modified: U2, 2026-10-18 20:06:52
+ */
+
+package gateway
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"janusec/data"
+	"janusec/models"
+	"janusec/utils"
+)
+
+// geoCountryHeaders are set by CDN in front of the gateway, used by ${geo_country} if sent by trusted proxies
+var geoCountryHeaders = []string{"CF-IPCountry", "CloudFront-Viewer-Country", "X-Country-Code"}
+
+type headerVarsKey struct{}
+
+// headerVars are the values of variables used in header rules, kept in request context
+// so that request and response rules get the same request ID
+type headerVars struct {
+	urlPath   string
+	requestID string
+	replacer  *strings.Replacer
+}
+
+// newHeaderVars collect the variables, should be called after OAuth and before routing
+func newHeaderVars(r *http.Request, app *models.Application, srcIP string) *headerVars {
+	requestID := newRequestID(r, app)
+	var tlsVersion string
+	scheme := "http"
+	if r.TLS != nil {
+		tlsVersion = tls.VersionName(r.TLS.Version)
+		scheme = "https"
+	}
+	var authUser string
+	if app.OAuthRequired {
+		// set by gateway after authentication
+		authUser = r.Header.Get("X-Auth-User")
+	}
+	var geoCountry string
+	if isFromTrustedProxy(r, app) {
+		for _, header := range geoCountryHeaders {
+			geoCountry = r.Header.Get(header)
+			if len(geoCountry) > 0 {
+				break
+			}
+		}
+	}
+	return &headerVars{
+		urlPath:   r.URL.Path,
+		requestID: requestID,
+		replacer: strings.NewReplacer(
+			"${client_ip}", srcIP,
+			"${request_id}", requestID,
+			"${tls_version}", tlsVersion,
+			"${auth_user}", authUser,
+			"${geo_country}", geoCountry,
+			"${host}", r.Host,
+			"${scheme}", scheme,
+		),
+	}
+}
+
+// newRequestID use X-Request-ID sent by trusted proxies, or generate a new one
+func newRequestID(r *http.Request, app *models.Application) string {
+	if isFromTrustedProxy(r, app) {
+		if requestID := r.Header.Get("X-Request-ID"); len(requestID) > 0 {
+			return requestID
+		}
+	}
+	return strconv.FormatInt(utils.GenSnowflakeID(), 10)
+}
+
+// applyRequestHeaderRules apply request rules, and return the request with header variables in context
+func applyRequestHeaderRules(r *http.Request, app *models.Application, srcIP string) *http.Request {
+	if len(app.HeaderRules) == 0 {
+		return r
+	}
+	vars := newHeaderVars(r, app, srcIP)
+	applyHeaderRules(r.Header, app.HeaderRules, "request", vars)
+	return r.WithContext(context.WithValue(r.Context(), headerVarsKey{}, vars))
+}
+
+// applyResponseHeaderRules remove hidden headers of backends and apply response rules
+func applyResponseHeaderRules(resp *http.Response, app *models.Application) {
+	for _, header := range strings.Split(data.NodeSetting.HiddenHeaders, ",") {
+		header = strings.TrimSpace(header)
+		if len(header) > 0 {
+			resp.Header.Del(header)
+		}
+	}
+	if app == nil || len(app.HeaderRules) == 0 {
+		return
+	}
+	vars, ok := resp.Request.Context().Value(headerVarsKey{}).(*headerVars)
+	if !ok {
+		return
+	}
+	applyHeaderRules(resp.Header, app.HeaderRules, "response", vars)
+}
+
+func applyHeaderRules(header http.Header, headerRules []*models.HeaderRule, direction string, vars *headerVars) {
+	for _, rule := range headerRules {
+		if rule.Direction != direction || len(rule.Name) == 0 {
+			continue
+		}
+		if len(rule.Route) > 0 && !strings.HasPrefix(vars.urlPath, rule.Route) {
+			continue
+		}
+		switch rule.Action {
+		case "remove":
+			header.Del(rule.Name)
+		case "append":
+			header.Add(rule.Name, vars.replacer.Replace(rule.Value))
+		default:
+			header.Set(rule.Name, vars.replacer.Replace(rule.Value))
+		}
+	}
+}
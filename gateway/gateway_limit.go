@@ -0,0 +1,184 @@
+/*
+ * @Copyright Reserved By Janusec (https://www.janusec.com/).
+ * @Author: U2
+ * @Date: 2026-10-18 18:26:50
+ * @Last Modified: U2, 2026-10-18 18:26:50
+ */
+
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"time"
+
+	"janusec/backend"
+	"janusec/models"
+	"janusec/utils"
+)
+
+var (
+	errBodyTimeout     = errors.New("request body timeout")
+	errResponseTimeout = errors.New("response timeout")
+)
+
+// limitRequestBody check the body size and set the deadline of reading body, return false if rejected
+func limitRequestBody(w http.ResponseWriter, r *http.Request, app *models.Application, srcIP string) bool {
+	limits := app.RequestLimits
+	if limits == nil || r.Body == nil || r.Body == http.NoBody {
+		return true
+	}
+	maxBodySize := limits.MaxBodySize
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" && limits.MaxUploadSize > 0 {
+		maxBodySize = limits.MaxUploadSize
+	}
+	if maxBodySize > 0 {
+		if r.ContentLength > maxBodySize {
+			writeBodyError(w, r, app, srcIP, &http.MaxBytesError{Limit: maxBodySize})
+			return false
+		}
+		// chunked body is limited when reading
+		r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+	}
+	if limits.BodyTimeout > 0 {
+		rc := http.NewResponseController(w)
+		err := rc.SetReadDeadline(time.Now().Add(time.Duration(limits.BodyTimeout) * time.Second))
+		if err != nil {
+			utils.DebugPrintln("limitRequestBody SetReadDeadline", err)
+			return true
+		}
+		r.Body = &deadlineBody{ReadCloser: r.Body, rc: rc}
+	}
+	return true
+}
+
+// inspectedBody keep the error of reading the body by WAF, such as exceeding the inspection limit
+type inspectedBody struct {
+	io.ReadCloser
+	err error
+}
+
+func (b *inspectedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && err != io.EOF {
+		b.err = err
+	}
+	return n, err
+}
+
+// limitInspectBody limit the request body to the inspection size of WAF if configured, so that no part of the body
+// is forwarded without inspection, return false if rejected, the body of gRPC calls is not inspected
+func limitInspectBody(w http.ResponseWriter, r *http.Request, app *models.Application, srcIP string, isGRPCBackend bool) (*inspectedBody, bool) {
+	if isGRPCBackend || r.Body == nil || r.Body == http.NoBody {
+		return nil, true
+	}
+	if app.RequestLimits == nil || app.RequestLimits.MaxInspectSize <= 0 {
+		// the whole body is inspected, it may be limited by MaxBodySize
+		body := &inspectedBody{ReadCloser: r.Body}
+		r.Body = body
+		return body, true
+	}
+	maxInspectSize := app.RequestLimits.MaxInspectSize
+	if r.ContentLength > maxInspectSize {
+		writeBodyError(w, r, app, srcIP, &http.MaxBytesError{Limit: maxInspectSize})
+		return nil, false
+	}
+	body := &inspectedBody{ReadCloser: http.MaxBytesReader(w, r.Body, maxInspectSize)}
+	r.Body = body
+	return body, true
+}
+
+// deadlineBody clear the read deadline after the body is read completely,
+// otherwise the connection is closed when the backend takes longer than the deadline
+type deadlineBody struct {
+	io.ReadCloser
+	rc   *http.ResponseController
+	done bool
+	err  error
+}
+
+func (b *deadlineBody) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && !b.done {
+		b.done = true
+		_ = b.rc.SetReadDeadline(time.Time{})
+	}
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		b.err = fmt.Errorf("%w: %v", errBodyTimeout, err)
+		return n, b.err
+	}
+	return n, err
+}
+
+// writeBodyError respond 413 or 408 if err is caused by the limits of request body, return false if not
+func writeBodyError(w http.ResponseWriter, r *http.Request, app *models.Application, srcIP string, err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	statusCode := 0
+	switch {
+	case errors.As(err, &maxBytesErr):
+		statusCode = http.StatusRequestEntityTooLarge
+		utils.DebugPrintln("Request body too large", app.Name, srcIP, r.Method, r.Host+r.URL.Path, "limit:", maxBytesErr.Limit)
+	case errors.Is(err, errBodyTimeout):
+		statusCode = http.StatusRequestTimeout
+		utils.DebugPrintln("Request body timeout", app.Name, srcIP, r.Method, r.Host+r.URL.Path)
+	default:
+		return false
+	}
+	// the remaining body is not read
+	w.Header().Set("Connection", "close")
+	writeErrorPage(w, r, app, srcIP, statusCode, "")
+	return true
+}
+
+// withConnectTimeout set the timeout of connecting to backends for the application
+func withConnectTimeout(r *http.Request, app *models.Application) *http.Request {
+	if app.RequestLimits == nil || app.RequestLimits.ConnectTimeout <= 0 {
+		return r
+	}
+	timeout := time.Duration(app.RequestLimits.ConnectTimeout) * time.Second
+	return r.WithContext(backend.WithConnectTimeout(r.Context(), timeout))
+}
+
+// roundTripWithTimeout cancel the request if the response header is not received in timeout
+func roundTripWithTimeout(req *http.Request, timeout time.Duration, roundTrip func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	ctx, cancel := context.WithCancelCause(req.Context())
+	timer := time.AfterFunc(timeout, func() {
+		cancel(errResponseTimeout)
+	})
+	resp, err := roundTrip(req.WithContext(ctx))
+	if !timer.Stop() {
+		// fired
+		if resp != nil {
+			resp.Body.Close()
+		}
+		cancel(nil)
+		return nil, errResponseTimeout
+	}
+	if err != nil {
+		cancel(nil)
+		return nil, err
+	}
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelBody release the context of the request when the response body closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelCauseFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel(nil)
+	return err
+}
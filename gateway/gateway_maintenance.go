@@ -0,0 +1,106 @@
+/*
+ * @Copyright Reserved By Janusec (https://www.janusec.com/).
+ * @Author: U2
+ * @Date: 2026-10-18 19:41:08
+ * @Last Modified: U2, 2026-10-18 19:41:08
+ */
+
+package gateway
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"janusec/backend"
+	"janusec/data"
+	"janusec/models"
+)
+
+const (
+	maintenanceBypassHeader = "X-Maintenance-Bypass"
+	maintenanceBypassCookie = "janusec-maintenance-bypass"
+)
+
+// checkMaintenance respond the maintenance page if the application is in maintenance, return true if responded
+func checkMaintenance(w http.ResponseWriter, r *http.Request, app *models.Application, srcIP string) bool {
+	maintenance := app.Maintenance
+	if maintenance == nil || !isInMaintenance(maintenance, time.Now().Unix()) {
+		return false
+	}
+	if isMaintenanceBypassed(r, app, srcIP) {
+		return false
+	}
+	retryAfter := maintenance.RetryAfter
+	if retryAfter <= 0 && maintenance.EndTime > 0 {
+		retryAfter = maintenance.EndTime - time.Now().Unix()
+	}
+	if retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.FormatInt(retryAfter, 10))
+	}
+	description := maintenance.Description
+	if len(description) == 0 {
+		description = "Under Maintenance"
+	}
+	info := newErrorPageInfo(r, app, srcIP, http.StatusServiceUnavailable, description)
+	htmlTmpl, jsonTmpl := getErrorPageTemplates(app, http.StatusServiceUnavailable)
+	if len(maintenance.HTML) > 0 {
+		htmlTmpl = maintenance.HTML
+	}
+	if len(maintenance.JSON) > 0 {
+		jsonTmpl = maintenance.JSON
+	}
+	renderErrorPage(w, r, info, htmlTmpl, jsonTmpl)
+	return true
+}
+
+// isInMaintenance check the switch and the schedule window
+func isInMaintenance(maintenance *models.MaintenanceConfig, now int64) bool {
+	if !maintenance.Enabled {
+		return false
+	}
+	if maintenance.StartTime > 0 && now < maintenance.StartTime {
+		return false
+	}
+	if maintenance.EndTime > 0 && now >= maintenance.EndTime {
+		return false
+	}
+	return true
+}
+
+// isMaintenanceBypassed check the IP list, the secret and the user authenticated by OAuth
+func isMaintenanceBypassed(r *http.Request, app *models.Application, srcIP string) bool {
+	maintenance := app.Maintenance
+	if len(maintenance.BypassIPs) > 0 && backend.IsIPInList(srcIP, maintenance.BypassIPs) {
+		return true
+	}
+	if len(maintenance.BypassSecret) > 0 {
+		secret := r.Header.Get(maintenanceBypassHeader)
+		if len(secret) == 0 {
+			if cookie, err := r.Cookie(maintenanceBypassCookie); err == nil {
+				secret = cookie.Value
+			}
+		}
+		if len(secret) > 0 && subtle.ConstantTimeCompare([]byte(secret), []byte(maintenance.BypassSecret)) == 1 {
+			return true
+		}
+	}
+	if app.OAuthRequired && data.NodeSetting.AuthConfig.Enabled {
+		// X-Auth-User is set by the gateway after OAuth authentication, it is spoofable otherwise
+		username := r.Header.Get("X-Auth-User")
+		if len(username) == 0 {
+			return false
+		}
+		if username == app.Owner {
+			return true
+		}
+		for _, user := range strings.Split(maintenance.BypassUsers, ",") {
+			if strings.TrimSpace(user) == username {
+				return true
+			}
+		}
+	}
+	return false
+}
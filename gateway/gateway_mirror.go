@@ -0,0 +1,99 @@
+/*
+ * @Copyright Reserved By Janusec (https://www.janusec.com/).
+ * @Author: U2
+ * @Date: 2026-10-18 17:48:06
+ * @Last Modified: U2, 2026-10-18 17:48:06
+ */
+
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"janusec/backend"
+	"janusec/models"
+	"janusec/utils"
+)
+
+const (
+	// maxMirrorBodySize is the max size of request body mirrored, requests with larger body are not mirrored
+	maxMirrorBodySize = 1024 * 1024
+
+	// mirrorTimeout is the timeout of a shadow request
+	mirrorTimeout = 30 * time.Second
+)
+
+// mirrorSlots limit the concurrent shadow requests, requests are not mirrored when slots are full
+var mirrorSlots = make(chan struct{}, 256)
+
+// mirrorRequest duplicate the request to the mirror target of the destination, the shadow response is discarded
+// r has been rewritten by SelectBackendRoute, it should be called before the request is sent to destination
+func mirrorRequest(r *http.Request, app *models.Application, dest *models.Destination) {
+	if len(dest.MirrorTarget) == 0 || dest.MirrorPercent <= 0 {
+		return
+	}
+	if dest.MirrorPercent < 100 && rand.Int63n(100) >= dest.MirrorPercent {
+		return
+	}
+	if len(r.Header.Get("Upgrade")) > 0 {
+		// WebSocket is not mirrored
+		return
+	}
+	var bodyBuf []byte
+	if r.Body != nil && r.Body != http.NoBody {
+		if r.ContentLength <= 0 || r.ContentLength > maxMirrorBodySize {
+			return
+		}
+		var err error
+		bodyBuf, err = io.ReadAll(r.Body)
+		if err != nil {
+			utils.DebugPrintln("mirrorRequest ReadAll", err)
+			// the error is returned again when proxying, such as body too large or timeout
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(bodyBuf), r.Body), r.Body}
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(bodyBuf))
+		body := bodyBuf
+		r.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+	select {
+	case mirrorSlots <- struct{}{}:
+	default:
+		utils.DebugPrintln("mirrorRequest slots full, skip", app.Name, r.URL.Path)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), mirrorTimeout)
+	shadowReq := r.Clone(ctx)
+	shadowReq.RequestURI = ""
+	shadowReq.Body = http.NoBody
+	if bodyBuf != nil {
+		shadowReq.Body = io.NopCloser(bytes.NewReader(bodyBuf))
+	}
+	shadowReq.Header.Set("X-Janusec-Mirror", "1")
+	go func() {
+		defer func() {
+			cancel()
+			<-mirrorSlots
+		}()
+		resp, err := backend.GetMirrorTransport(dest).RoundTrip(shadowReq)
+		if err != nil {
+			utils.DebugPrintln("mirrorRequest", dest.MirrorTarget, err)
+			return
+		}
+		_, err = io.Copy(io.Discard, resp.Body)
+		if err != nil {
+			utils.DebugPrintln("mirrorRequest discard", dest.MirrorTarget, err)
+		}
+		resp.Body.Close()
+	}()
+}
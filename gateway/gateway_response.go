@@ -9,14 +9,10 @@ package gateway
 
 import (
 	"bytes"
-	"compress/flate"
-	"compress/gzip"
 	"fmt"
 	"html/template"
 	"io"
 	"net/http"
-	"os"
-	"path/filepath"
 	"regexp"
 	"strconv"
 	"time"
@@ -24,7 +20,6 @@ import (
 	//"net/http/httputil"
 	"strings"
 
-	"github.com/andybalholm/brotli"
 	"golang.org/x/net/html"
 
 	"janusec/backend"
@@ -77,10 +72,12 @@ func rewriteResponse(resp *http.Response) (err error) {
 	if xPoweredBy != "" {
 		resp.Header.Set("X-Powered-By", "Janusec")
 	}
+	// v1.5.3 hidden headers and response header rules
+	applyResponseHeaderRules(resp, app)
 
 	srcIP := GetClientIP(r, app)
 	if app.WAFEnabled {
-		if isHit, policy := firewall.IsResponseHitPolicy(resp, app.ID); isHit {
+		if isHit, policy := firewall.IsResponseHitPolicy(resp, app.ID, isGRPCBackendResponse(resp)); isHit {
 			switch policy.Action {
 			case models.Action_Block_100:
 				vulnName, _ := firewall.VulnMap.Load(policy.VulnID)
@@ -145,62 +142,8 @@ func rewriteResponse(resp *http.Response) (err error) {
 		}
 	}
 
-	// Static Cache
-	if resp.StatusCode == http.StatusOK && firewall.IsStaticResource(r) {
-		if resp.ContentLength < 0 || resp.ContentLength > 1024*1024*10 {
-			// Not cache big files which size bigger than 10MB or unknown
-			return nil
-		}
-		staticRoot := fmt.Sprintf("./static/cdncache/%d", app.ID)
-		targetFile := staticRoot + r.URL.Path
-		cacheFilePath := filepath.Dir(targetFile)
-		bodyBuf, _ := io.ReadAll(resp.Body)
-		resp.Body = io.NopCloser(bytes.NewBuffer(bodyBuf))
-		err := os.MkdirAll(cacheFilePath, 0666)
-		if err != nil {
-			utils.DebugPrintln("Cache Path Error", err)
-		}
-		contentEncoding := resp.Header.Get("Content-Encoding")
-		switch contentEncoding {
-		case "gzip":
-			reader, _ := gzip.NewReader(bytes.NewBuffer(bodyBuf))
-			defer reader.Close()
-			decompressedBodyBuf, err := io.ReadAll(reader)
-			if err != nil {
-				utils.DebugPrintln("Gzip decompress Error", err)
-			}
-			_ = os.WriteFile(targetFile, decompressedBodyBuf, 0600)
-		case "br":
-			reader := brotli.NewReader(bytes.NewBuffer(bodyBuf))
-			decompressedBodyBuf, err := io.ReadAll(reader)
-			if err != nil {
-				utils.DebugPrintln("Brotli decompress Error", err)
-			}
-			_ = os.WriteFile(targetFile, decompressedBodyBuf, 0600)
-		case "deflate":
-			reader := flate.NewReader(bytes.NewBuffer(bodyBuf))
-			defer reader.Close()
-			decompressedBodyBuf, err := io.ReadAll(reader)
-			if err != nil {
-				utils.DebugPrintln("deflate decompress Error", err)
-			}
-			_ = os.WriteFile(targetFile, decompressedBodyBuf, 0600)
-		default:
-			_ = os.WriteFile(targetFile, bodyBuf, 0600)
-		}
-		if err != nil {
-			utils.DebugPrintln("Cache File Error", targetFile, err)
-		}
-		lastModified, err := time.Parse(http.TimeFormat, resp.Header.Get("Last-Modified"))
-		if err != nil {
-			//utils.DebugPrintln("Cache File Parse Last-Modified", targetFile, err)
-			return nil
-		}
-		err = os.Chtimes(targetFile, time.Now(), lastModified)
-		if err != nil {
-			utils.DebugPrintln("Cache File Chtimes", targetFile, err)
-		}
-	}
+	// v1.5.3 HTTP cache, the response is stored while it is sent to the client
+	storeCacheResponse(resp, app)
 
 	// Cookie Management
 	if app.CookieMgmtEnabled {
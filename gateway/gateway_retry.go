@@ -0,0 +1,162 @@
+/*
+ * @Copyright Reserved By Janusec (https://www.janusec.com/).
+ * @Author: U2
+ * @Date: 2026-10-18 16:40:12
+ * @Last Modified: U2, 2026-10-18 16:40:12
+ */
+
+package gateway
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"janusec/backend"
+	"janusec/models"
+	"janusec/utils"
+)
+
+// maxRetryBodySize is the max size of request body buffered for retries
+const maxRetryBodySize = 1024 * 1024
+
+// retryTransport send request to the selected destination, and retry on other destinations of the same route
+type retryTransport struct {
+	app   *models.Application
+	srcIP string
+	// urlPath is the original path before route rewriting
+	urlPath string
+	// group is selected by traffic split
+	group      string
+	dest       *models.Destination
+	targetDest string
+	// retried destinations are counted in Outstanding until released
+	retried []*models.Destination
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	limits := t.app.RequestLimits
+	if limits != nil && limits.ResponseTimeout > 0 && len(req.Header.Get("Upgrade")) == 0 {
+		return roundTripWithTimeout(req, time.Duration(limits.ResponseTimeout)*time.Second, t.roundTrip)
+	}
+	return t.roundTrip(req)
+}
+
+func (t *retryTransport) roundTrip(req *http.Request) (*http.Response, error) {
+	var retries int64
+	if t.app.RetryPolicy != nil && len(req.Header.Get("Upgrade")) == 0 {
+		retries = t.app.RetryPolicy.Retries
+	}
+	dest := t.dest
+	targetDest := t.targetDest
+	tried := []*models.Destination{}
+	for attempt := int64(0); ; attempt++ {
+		tried = append(tried, dest)
+		resp, err := backend.GetTransport(dest, targetDest, backend.IsH2CScheme(t.app.InternalScheme)).RoundTrip(req)
+		backend.ReportDestinationResult(t.app, dest, isUpstreamFailure(resp, err))
+		if attempt >= retries || !isRetryable(req, resp, err) {
+			return resp, err
+		}
+		next := backend.SelectRetryDestination(t.app, req, t.srcIP, t.urlPath, t.group, tried)
+		if next == nil {
+			return resp, err
+		}
+		if req.Body != nil && req.Body != http.NoBody {
+			if req.GetBody == nil {
+				// body can not be replayed
+				return resp, err
+			}
+			body, bodyErr := req.GetBody()
+			if bodyErr != nil {
+				return resp, err
+			}
+			req.Body = body
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+		dest = next
+		targetDest = dest.Destination
+		if dest.RouteType == models.K8S_Ingress {
+			targetDest = backend.SelectPodFromDestination(dest, t.srcIP, req)
+		}
+		req.URL.Path = backend.RewriteRoutePath(dest, t.urlPath)
+		req.URL.RawPath = ""
+		utils.DebugPrintln("Retry", t.app.Name, req.Method, req.URL.Path, "on", targetDest, "error:", err)
+		dest.Outstanding.Add(1)
+		t.retried = append(t.retried, dest)
+	}
+}
+
+// release decrease Outstanding of the retried destinations, called when the response finished, same as the selected one
+func (t *retryTransport) release() {
+	for _, dest := range t.retried {
+		dest.Outstanding.Add(-1)
+	}
+	t.retried = nil
+}
+
+// isUpstreamFailure connection errors, timeouts and 502/503/504 are counted for outlier detection,
+// other 5xx are errors of the application instead of the destination
+func isUpstreamFailure(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// isRetryable idempotent requests retry on errors and 502/503/504, others retry on dial errors only
+func isRetryable(req *http.Request, resp *http.Response, err error) bool {
+	if req.Context().Err() != nil {
+		// client canceled
+		return false
+	}
+	if err != nil {
+		var opErr *net.OpError
+		if errors.As(err, &opErr) && opErr.Op == "dial" {
+			return true
+		}
+		return isIdempotent(req)
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return isIdempotent(req)
+	}
+	return false
+}
+
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return len(req.Header.Get("Idempotency-Key")) > 0
+}
+
+// prepareRetryBody buffer the small request body so that it can be sent again
+func prepareRetryBody(r *http.Request, app *models.Application) error {
+	if app.RetryPolicy == nil || app.RetryPolicy.Retries <= 0 {
+		return nil
+	}
+	if r.Body == nil || r.Body == http.NoBody || r.ContentLength <= 0 || r.ContentLength > maxRetryBodySize {
+		return nil
+	}
+	bodyBuf, err := io.ReadAll(r.Body)
+	if err != nil {
+		utils.DebugPrintln("prepareRetryBody ReadAll", err)
+		return err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(bodyBuf))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(bodyBuf)), nil
+	}
+	return nil
+}
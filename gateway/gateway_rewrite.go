@@ -0,0 +1,73 @@
+/*
+ * @Copyright Reserved By Janusec (https://www.janusec.com/).
+ * @Author: U2
+ * @Date: 2026-10-18 19:41:07
+ * @Last Modified: U2, 2026-10-18 19:41:07
+ */
+
+package gateway
+
+import (
+	"net/http"
+	"strings"
+
+	"janusec/backend"
+	"janusec/models"
+	"janusec/utils"
+)
+
+// applyRewriteRules evaluate rewrite rules of the application in order,
+// return true if the response has been written (redirect or return)
+func applyRewriteRules(w http.ResponseWriter, r *http.Request, app *models.Application, srcIP string) bool {
+	for _, rule := range app.RewriteRules {
+		replacement, matched := backend.MatchRewriteRule(rule, r, srcIP)
+		if !matched {
+			continue
+		}
+		switch rule.Action {
+		case models.RewriteAction_REDIRECT:
+			statusCode := int(rule.StatusCode)
+			switch statusCode {
+			case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+			default:
+				statusCode = http.StatusFound
+			}
+			http.Redirect(w, r, replacement, statusCode)
+			return true
+		case models.RewriteAction_RETURN:
+			statusCode := int(rule.StatusCode)
+			if statusCode < 100 || statusCode > 599 {
+				statusCode = http.StatusForbidden
+			}
+			if len(replacement) == 0 && statusCode >= http.StatusBadRequest {
+				// v1.5.3 custom error page
+				writeErrorPage(w, r, app, srcIP, statusCode, "")
+				return true
+			}
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.WriteHeader(statusCode)
+			_, err := w.Write([]byte(replacement))
+			if err != nil {
+				utils.DebugPrintln("applyRewriteRules Write", err)
+			}
+			return true
+		case models.RewriteAction_REWRITE:
+			// internal rewrite, the query is kept if replacement has no query
+			newPath, newQuery, hasQuery := strings.Cut(replacement, "?")
+			if len(newPath) > 0 {
+				if !strings.HasPrefix(newPath, "/") {
+					newPath = "/" + newPath
+				}
+				r.URL.Path = newPath
+				r.URL.RawPath = ""
+			}
+			if hasQuery {
+				r.URL.RawQuery = newQuery
+			}
+			if rule.Last {
+				return false
+			}
+		}
+	}
+	return false
+}
@@ -59,96 +59,104 @@ func InitAccessStat() {
 	// synchronize statMap to database periodically
 	statTicker := time.NewTicker(time.Duration(1) * time.Minute)
 	for range statTicker.C {
-		now := time.Now()
-		statDate := now.Format("20060102")
-		accessStats := []*models.AccessStat{}
-		statMap.Range(func(key, value interface{}) bool {
-			appID := key.(int64)
-			pathMap := value.(*sync.Map)
-			pathMap.Range(func(key, value interface{}) bool {
-				urlPath := key.(string)
-				delta := value.(int64)
-				// Add to database
-				// go IncAmountToDB(appID, urlPath, statDate, delta, now.Unix())
-				accessStat := &models.AccessStat{
-					AppID:      appID,
-					URLPath:    urlPath,
-					StatDate:   statDate,
-					Delta:      delta,
-					UpdateTime: now.Unix(),
-				}
-				accessStats = append(accessStats, accessStat)
-				// Clear
-				pathMap.Delete(urlPath)
-				return true
-			})
+		FlushAccessStat()
+		// check offline destinations
+		nowTimeStamp := time.Now().Unix()
+		backend.CheckOfflineDestinations(nowTimeStamp)
+		backend.CheckOfflineVipTargets(nowTimeStamp)
+	}
+}
+
+// FlushAccessStat synchronize the access and referer statistics in memory to database, also used before exit
+func FlushAccessStat() {
+	now := time.Now()
+	statDate := now.Format("20060102")
+	accessStats := []*models.AccessStat{}
+	statMap.Range(func(key, value interface{}) bool {
+		appID := key.(int64)
+		pathMap := value.(*sync.Map)
+		pathMap.Range(func(key, value interface{}) bool {
+			urlPath := key.(string)
+			delta := value.(int64)
+			// Add to database
+			// go IncAmountToDB(appID, urlPath, statDate, delta, now.Unix())
+			accessStat := &models.AccessStat{
+				AppID:      appID,
+				URLPath:    urlPath,
+				StatDate:   statDate,
+				Delta:      delta,
+				UpdateTime: now.Unix(),
+			}
+			accessStats = append(accessStats, accessStat)
+			// Clear
+			pathMap.Delete(urlPath)
 			return true
 		})
-		if data.IsPrimary {
-			go UpdateAccessStat(accessStats)
-		} else if len(accessStats) > 0 {
-			// Replica
-			rpcRequest := &models.RPCRequest{Action: "update_access_stat", Object: accessStats}
-			_, err := data.GetRPCResponse(rpcRequest)
-			if err != nil {
-				utils.DebugPrintln("RPC update_access_stat", err)
-			}
+		return true
+	})
+	if data.IsPrimary {
+		UpdateAccessStat(accessStats)
+	} else if len(accessStats) > 0 {
+		// Replica
+		rpcRequest := &models.RPCRequest{Action: "update_access_stat", Object: accessStats}
+		_, err := data.GetRPCResponse(rpcRequest)
+		if err != nil {
+			utils.DebugPrintln("RPC update_access_stat", err)
 		}
+	}
 
-		// Declare a nested map for replica nodes
-		// map[appID int64][host string][path string][clientID string](count int64)
-		mapReferer := map[int64]map[string]map[string]map[string]int64{}
-		refererMap.Range(func(key, value interface{}) bool {
-			appID := key.(int64)
-			hostMap := value.(*sync.Map)
-			// map[host string][path string][clientID string](count int64)
-			mapHost := map[string]map[string]map[string]int64{}
-			hostMap.Range(func(key, value interface{}) bool {
-				refererHost := key.(string)
-				pathMap := value.(*sync.Map)
-				// map[path string][clientID string](count int64)
-				mapPath := map[string]map[string]int64{}
-				pathMap.Range(func(key, value interface{}) bool {
-					refererPath := key.(string)
-					clientMap := value.(*sync.Map)
-					// map[clientID string](count int64)
-					mapClient := map[string]int64{}
-					clientMap.Range(func(key, value interface{}) bool {
-						clientID := key.(string)
-						count := value.(int64)
-						mapClient[clientID] = count
-						// Clear
-						clientMap.Delete(clientID)
-						return true
-					})
-					mapPath[refererPath] = mapClient
+	// Declare a nested map for replica nodes
+	// map[appID int64][host string][path string][clientID string](count int64)
+	mapReferer := map[int64]map[string]map[string]map[string]int64{}
+	refererMap.Range(func(key, value interface{}) bool {
+		appID := key.(int64)
+		hostMap := value.(*sync.Map)
+		// map[host string][path string][clientID string](count int64)
+		mapHost := map[string]map[string]map[string]int64{}
+		hostMap.Range(func(key, value interface{}) bool {
+			refererHost := key.(string)
+			pathMap := value.(*sync.Map)
+			// map[path string][clientID string](count int64)
+			mapPath := map[string]map[string]int64{}
+			pathMap.Range(func(key, value interface{}) bool {
+				refererPath := key.(string)
+				clientMap := value.(*sync.Map)
+				// map[clientID string](count int64)
+				mapClient := map[string]int64{}
+				clientMap.Range(func(key, value interface{}) bool {
+					clientID := key.(string)
+					count := value.(int64)
+					mapClient[clientID] = count
 					// Clear
-					pathMap.Delete(refererPath)
+					clientMap.Delete(clientID)
 					return true
 				})
-				mapHost[refererHost] = mapPath
-				hostMap.Delete(refererHost)
+				mapPath[refererPath] = mapClient
+				// Clear
+				pathMap.Delete(refererPath)
 				return true
 			})
-			mapReferer[appID] = mapHost
-			refererMap.Delete(appID)
+			mapHost[refererHost] = mapPath
+			hostMap.Delete(refererHost)
 			return true
 		})
+		mapReferer[appID] = mapHost
+		refererMap.Delete(appID)
+		return true
+	})
 
-		if data.IsPrimary {
-			go UpdateRefererStat(&mapReferer)
-		} else if len(mapReferer) > 0 {
-			// Replica
-			rpcRequest := &models.RPCRequest{Action: "update_referer_stat", Object: mapReferer}
-			_, err := data.GetRPCResponse(rpcRequest)
-			if err != nil {
-				utils.DebugPrintln("RPC update_referer_stat", err)
-			}
+	if data.IsPrimary {
+		err := UpdateRefererStat(&mapReferer)
+		if err != nil {
+			utils.DebugPrintln("FlushAccessStat UpdateRefererStat", err)
+		}
+	} else if len(mapReferer) > 0 {
+		// Replica
+		rpcRequest := &models.RPCRequest{Action: "update_referer_stat", Object: mapReferer}
+		_, err := data.GetRPCResponse(rpcRequest)
+		if err != nil {
+			utils.DebugPrintln("RPC update_referer_stat", err)
 		}
-
-		// check offline destinations
-		backend.CheckOfflineDestinations(now.Unix())
-		backend.CheckOfflineVipTargets(now.Unix())
 	}
 }
 
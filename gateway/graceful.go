@@ -0,0 +1,47 @@
+/*
+ * @Copyright Reserved By Janusec (https://www.janusec.com/).
+ * @Author: U2
+ * @Date: 2026-10-18 17:40:27
+ * @Last Modified: U2, 2026-10-18 17:40:27
+ */
+
+package gateway
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// inFlight is the number of requests being served, including upgraded WebSocket and WebSSH sessions,
+// which are not tracked by http.Server after hijacked
+var inFlight int64
+
+// TrackInFlight count the requests being served by next
+func TrackInFlight(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&inFlight, 1)
+		defer atomic.AddInt64(&inFlight, -1)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// WaitInFlight wait for the requests being served until ctx done, used after the servers are shut down
+func WaitInFlight(ctx context.Context) error {
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	for atomic.LoadInt64(&inFlight) > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// GetInFlightCount return the number of requests being served
+func GetInFlightCount() int64 {
+	return atomic.LoadInt64(&inFlight)
+}
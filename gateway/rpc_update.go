@@ -43,6 +43,12 @@ func SyncTimeTick() {
 		if discoveryLastModified < data.NodeSetting.DiscoveryLastModified {
 			go firewall.LoadDiscoveryRules()
 		}
+		if lastCachePurgeID < data.NodeSetting.CachePurgeLastID {
+			SyncCachePurges(data.NodeSetting.CachePurgeLastID)
+		}
+		go ReportCacheStats()
+		// v1.5.3 share the session ticket keys of primary node
+		backend.UpdateSessionTicketKeys(data.NodeSetting.SessionTicketKeys)
 		if lastSyncInterval != data.NodeSetting.SyncInterval {
 			syncTicker.Stop()
 			syncTicker = time.NewTicker(data.NodeSetting.SyncInterval)
@@ -13,6 +13,7 @@ import (
 	"net/http"
 
 	"janusec/data"
+	"janusec/firewall"
 	"janusec/models"
 	"janusec/utils"
 )
@@ -29,6 +30,15 @@ func GenerateBlockPage(w http.ResponseWriter, hitInfo *models.HitInfo) {
 	}
 }
 
+// writeBlockResponse respond grpc-status to gRPC clients, and the block page to others, v1.5.3
+func writeBlockResponse(w http.ResponseWriter, r *http.Request, hitInfo *models.HitInfo) {
+	if !firewall.IsGRPCRequest(r) {
+		GenerateBlockPage(w, hitInfo)
+		return
+	}
+	writeGRPCError(w, grpcPermissionDenied, "blocked by "+hitInfo.VulnName+" policy")
+}
+
 // GenerateBlockContent ...
 func GenerateBlockContent(hitInfo *models.HitInfo) []byte {
 	if data.TmplWAF == nil {
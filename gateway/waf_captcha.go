@@ -8,6 +8,7 @@
 package gateway
 
 import (
+	"bytes"
 	"html"
 	"net/http"
 	"sync"
@@ -16,6 +17,7 @@ import (
 
 	"janusec/firewall"
 	"janusec/models"
+	"janusec/utils"
 
 	"github.com/dchest/captcha"
 )
@@ -35,8 +37,15 @@ func ShowCaptchaHandlerFunc(w http.ResponseWriter, r *http.Request) {
 	go ClearExpiredCapthchaHitInfo()
 	id := html.EscapeString(r.FormValue("id"))
 	captchaContext := models.CaptchaContext{CaptchaId: captcha.New(), ClientID: id}
-	if err := formTemplate.Execute(w, &captchaContext); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	buf := &bytes.Buffer{}
+	if err := formTemplate.Execute(buf, &captchaContext); err != nil {
+		utils.DebugPrintln("ShowCaptchaHandlerFunc Execute", err)
+		writeErrorPage(w, r, nil, getRemoteIP(r), http.StatusInternalServerError, "")
+		return
+	}
+	_, err := w.Write(buf.Bytes())
+	if err != nil {
+		utils.DebugPrintln("ShowCaptchaHandlerFunc w.Write", err)
 	}
 }
 
@@ -10,6 +10,7 @@ package main
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/gob"
 	"flag"
 	"fmt"
@@ -17,10 +18,12 @@ import (
 	"net/http"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
 	"runtime"
 	"sync"
 	"syscall"
+	"time"
 
 	// _ "net/http/pprof"
 	"janusec/backend"
@@ -31,6 +34,7 @@ import (
 	"janusec/utils"
 
 	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go/http3"
 )
 
 func main() {
@@ -67,33 +71,18 @@ func main() {
 	if !data.IsPrimary {
 		go gateway.SyncTimeTick()
 	}
+	gateway.InitCache()
 	go gateway.InitAccessStat()
 	go gateway.Counter()
 	go gateway.DailyRoutineTasks()
+	go backend.RoutineHealthCheckTick()
 
-	tlsconfig := &tls.Config{
-		GetCertificate: func(helloInfo *tls.ClientHelloInfo) (*tls.Certificate, error) {
-			cert, err := backend.GetCertificateByDomain(helloInfo)
-			return cert, err
-		},
-		NextProtos: []string{"h2", "http/1.1"},
-		MaxVersion: tls.VersionTLS13,
-		MinVersion: tls.VersionTLS11,
-		CipherSuites: []uint16{
-			tls.TLS_AES_128_GCM_SHA256,
-			tls.TLS_CHACHA20_POLY1305_SHA256,
-			tls.TLS_AES_256_GCM_SHA384,
-			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
-			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
-			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
-			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
-			tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
-			tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
-			tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA256,
-			tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
-			tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
-			tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA256,
-		},
+	// v1.5.3 TLS profiles and client certificates are selected by SNI
+	tlsconfig := backend.InitTLSConfig()
+	if data.IsPrimary {
+		go backend.RoutineRotateSessionTicketKeys()
+		// v1.5.3 certificates obtained by ACME with DNS-01 challenges
+		go backend.RoutineRenewACMECerts()
 	}
 	gateMux := http.NewServeMux()
 	if data.IsPrimary {
@@ -101,41 +90,37 @@ func main() {
 		if admin.Listen {
 			adminMux := http.NewServeMux()
 			LoadAPIRoute(adminMux)
+			adminHandler := gateway.TrackInFlight(adminMux)
 			if len(admin.ListenHTTP) > 0 {
-				go func() {
-					listen, err := net.Listen("tcp", admin.ListenHTTP)
-					if err != nil {
-						utils.CheckError("Admin Port occupied.", err)
-						utils.DebugPrintln("Admin Port occupied.", err)
-						os.Exit(1)
-					}
-					utils.DebugPrintln("Admin Listen HTTP ", admin.ListenHTTP)
-					err = http.Serve(listen, adminMux)
-					if err != nil {
-						utils.CheckError("http.Serve adminMux error", err)
-						utils.DebugPrintln("http.Serve adminMux error", err)
-						os.Exit(1)
-					}
-					defer listen.Close()
-				}()
+				listen, err := utils.Listen(admin.ListenHTTP)
+				if err != nil {
+					utils.CheckError("Admin Port occupied.", err)
+					utils.DebugPrintln("Admin Port occupied.", err)
+					os.Exit(1)
+				}
+				utils.DebugPrintln("Admin Listen HTTP ", admin.ListenHTTP)
+				ServeGracefully(listen, adminHandler)
 			}
 			if len(admin.ListenHTTPS) > 0 {
-				go func() {
-					listen, err := tls.Listen("tcp", admin.ListenHTTPS, tlsconfig)
-					if err != nil {
-						utils.CheckError("Admin Port occupied.", err)
-						utils.DebugPrintln("Admin Port occupied.", err)
+				listen, err := utils.Listen(admin.ListenHTTPS)
+				if err != nil {
+					utils.CheckError("Admin Port occupied.", err)
+					utils.DebugPrintln("Admin Port occupied.", err)
+					os.Exit(1)
+				}
+				utils.DebugPrintln("Admin Listen HTTPS", admin.ListenHTTPS)
+				adminTLSConfig := tlsconfig
+				if len(admin.ClientCACert) > 0 {
+					// v1.5.3 admin portal requires client certificates
+					caPEM, err := os.ReadFile(admin.ClientCACert)
+					clientCAs := x509.NewCertPool()
+					if err != nil || !clientCAs.AppendCertsFromPEM(caPEM) {
+						utils.DebugPrintln("Admin client_ca_cert error", admin.ClientCACert, err)
 						os.Exit(1)
 					}
-					utils.DebugPrintln("Admin Listen HTTPS", admin.ListenHTTPS)
-					err = http.Serve(listen, adminMux)
-					if err != nil {
-						utils.CheckError("http.Serve adminMux error", err)
-						utils.DebugPrintln("http.Serve adminMux error", err)
-						os.Exit(1)
-					}
-					defer listen.Close()
-				}()
+					adminTLSConfig = backend.NewAdminTLSConfig(tlsconfig, clientCAs)
+				}
+				ServeGracefully(tls.NewListener(listen, adminTLSConfig), adminHandler)
 			}
 		} else {
 			// Add API and admin
@@ -170,9 +155,19 @@ func main() {
 	// DNS Management
 	if data.IsPrimary && data.PrimarySetting.DNSEnabled {
 		dns.HandleFunc(".", gateway.DNSHandler)
+		udpConn, err := utils.ListenUDP(":53")
+		if err != nil {
+			utils.DebugPrintln("DNS UDP", err)
+			os.Exit(1)
+		}
+		tcpListen, err := utils.Listen(":53")
+		if err != nil {
+			utils.DebugPrintln("DNS TCP", err)
+			os.Exit(1)
+		}
 		go func() {
 			utils.DebugPrintln("DNS Listen UDP 53")
-			err := dns.ListenAndServe(":53", "udp", nil)
+			err := (&dns.Server{PacketConn: udpConn}).ActivateAndServe()
 			if err != nil {
 				utils.DebugPrintln("DNS UDP", err)
 				os.Exit(1)
@@ -180,7 +175,7 @@ func main() {
 		}()
 		go func() {
 			utils.DebugPrintln("DNS Listen TCP 53")
-			err := dns.ListenAndServe(":53", "tcp", nil)
+			err := (&dns.Server{Listener: tcpListen}).ActivateAndServe()
 			if err != nil {
 				utils.DebugPrintln("DNS TCP", err)
 				os.Exit(1)
@@ -191,40 +186,182 @@ func main() {
 	// Reverse Proxy
 	gateMux.HandleFunc("/", gateway.ReverseHandlerFunc)
 	ctxGateMux := AddContextHandler(gateMux)
-	go func(listenPort string) {
-		listen, err := net.Listen("tcp", listenPort)
+	gateHandler := gateway.TrackInFlight(backend.AcmeCertManager.HTTPHandler(ctxGateMux))
+	httpListen, err := utils.Listen(data.CFG.ListenHTTP)
+	if err != nil {
+		msg := "Port " + data.CFG.ListenHTTP + " is occupied."
+		utils.CheckError(msg, err)
+		utils.DebugPrintln(msg, err)
+		os.Exit(1)
+	}
+	utils.DebugPrintln("Listen HTTP ", data.CFG.ListenHTTP)
+	ServeGracefully(WrapProxyListener(httpListen), gateHandler)
+	httpsListen, err := utils.Listen(data.CFG.ListenHTTPS)
+	if err != nil {
+		msg := "Port " + data.CFG.ListenHTTPS + " is occupied."
+		utils.CheckError(msg, err)
+		utils.DebugPrintln(msg, err)
+		os.Exit(1)
+	}
+	utils.DebugPrintln("Listen HTTPS", data.CFG.ListenHTTPS)
+	httpsHandler := gateHandler
+	if len(data.CFG.ListenHTTP3) > 0 {
+		udpConn, err := utils.ListenUDP(data.CFG.ListenHTTP3)
 		if err != nil {
-			msg := "Port " + listenPort + " is occupied."
+			msg := "UDP Port " + data.CFG.ListenHTTP3 + " is occupied."
 			utils.CheckError(msg, err)
 			utils.DebugPrintln(msg, err)
 			os.Exit(1)
 		}
-		utils.DebugPrintln("Listen HTTP ", listenPort)
-		// err = http.Serve(listen, ctxGateMux)
-		err = http.Serve(listen, backend.AcmeCertManager.HTTPHandler(ctxGateMux))
-		if err != nil {
+		utils.DebugPrintln("Listen HTTP3", data.CFG.ListenHTTP3)
+		srv := ServeHTTP3(udpConn, tlsconfig, gateHandler)
+		httpsHandler = AltSvcHandler(srv, gateHandler)
+	}
+	// PROXY protocol header is before TLS handshake
+	ServeGracefully(tls.NewListener(WrapProxyListener(httpsListen), tlsconfig), httpsHandler)
+	// v1.5.3 listeners are ready, the previous process can exit if upgraded
+	utils.NotifyUpgradeParent()
+	HandleSignals()
+}
+
+// servers are shut down gracefully when SIGTERM received
+var (
+	servers      = []*http.Server{}
+	http3Servers = []*http3.Server{}
+)
+
+// ServeGracefully serve on the listener in a new goroutine
+func ServeGracefully(listen net.Listener, handler http.Handler) {
+	cfg := data.CFG.Server
+	srv := &http.Server{
+		Handler: handler,
+		// v1.5.3 slow-client protection, negative value means no timeout
+		ReadHeaderTimeout: time.Duration(cfg.ReadHeaderTimeout) * time.Second,
+		ReadTimeout:       time.Duration(cfg.ReadTimeout) * time.Second,
+		WriteTimeout:      time.Duration(cfg.WriteTimeout) * time.Second,
+		IdleTimeout:       time.Duration(cfg.IdleTimeout) * time.Second,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+	}
+	servers = append(servers, srv)
+	go func() {
+		err := srv.Serve(listen)
+		if err != nil && err != http.ErrServerClosed {
 			utils.CheckError("http.Serve error", err)
 			utils.DebugPrintln("http.Serve error", err)
 			os.Exit(1)
 		}
-		defer listen.Close()
-	}(data.CFG.ListenHTTP)
-	listen, err := tls.Listen("tcp", data.CFG.ListenHTTPS, tlsconfig)
+	}()
+}
+
+// ServeHTTP3 serve HTTP/3 on the UDP connection in a new goroutine, the certificates are selected by tlsconfig, v1.5.3
+func ServeHTTP3(udpConn net.PacketConn, tlsconfig *tls.Config, handler http.Handler) *http3.Server {
+	cfg := data.CFG.Server
+	srv := &http3.Server{
+		Handler: handler,
+		// QUIC requires TLS 1.3, which is enforced by quic-go
+		TLSConfig:      http3.ConfigureTLSConfig(tlsconfig),
+		IdleTimeout:    time.Duration(cfg.IdleTimeout) * time.Second,
+		MaxHeaderBytes: cfg.MaxHeaderBytes,
+	}
+	http3Servers = append(http3Servers, srv)
+	go func() {
+		err := srv.Serve(udpConn)
+		if err != nil && err != http.ErrServerClosed {
+			utils.CheckError("http3.Serve error", err)
+			utils.DebugPrintln("http3.Serve error", err)
+			os.Exit(1)
+		}
+	}()
+	return srv
+}
+
+// AltSvcHandler advertise the HTTP/3 listener by Alt-Svc header in the responses over HTTPS, v1.5.3
+func AltSvcHandler(srv *http3.Server, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = srv.SetQUICHeaders(w.Header())
+		next.ServeHTTP(w, r)
+	})
+}
+
+// HandleSignals SIGTERM or SIGINT: graceful shutdown, SIGHUP: reload configuration,
+// SIGUSR2: start the upgraded binary which takes over the listening sockets
+func HandleSignals() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP, syscall.SIGUSR2)
+	for sig := range signals {
+		switch sig {
+		case syscall.SIGHUP:
+			utils.DebugPrintln("SIGHUP received, reload configuration")
+			ReloadConfiguration()
+		case syscall.SIGUSR2:
+			utils.DebugPrintln("SIGUSR2 received, start upgraded process")
+			err := utils.StartUpgradedProcess()
+			if err != nil {
+				utils.DebugPrintln("StartUpgradedProcess error", err)
+			}
+		default:
+			utils.DebugPrintln(sig, "received, shutting down")
+			Shutdown()
+			os.Exit(0)
+		}
+	}
+}
+
+// Shutdown stop accepting, wait for in-flight requests up to shutdown_timeout and flush statistics
+func Shutdown() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(data.CFG.ShutdownTimeout)*time.Second)
+	defer cancel()
+	backend.StopVipApps()
+	wg := sync.WaitGroup{}
+	for _, srv := range servers {
+		wg.Add(1)
+		go func(srv *http.Server) {
+			defer wg.Done()
+			err := srv.Shutdown(ctx)
+			if err != nil {
+				utils.DebugPrintln("Shutdown error", err)
+			}
+		}(srv)
+	}
+	for _, srv := range http3Servers {
+		wg.Add(1)
+		go func(srv *http3.Server) {
+			defer wg.Done()
+			err := srv.Shutdown(ctx)
+			if err != nil {
+				utils.DebugPrintln("Shutdown HTTP3 error", err)
+			}
+		}(srv)
+	}
+	wg.Wait()
+	err := gateway.WaitInFlight(ctx)
 	if err != nil {
-		msg := "Port " + data.CFG.ListenHTTPS + " is occupied."
-		utils.CheckError(msg, err)
-		utils.DebugPrintln(msg, err)
-		os.Exit(1)
+		utils.DebugPrintln("Shutdown in-flight requests:", gateway.GetInFlightCount(), err)
 	}
-	utils.DebugPrintln("Listen HTTPS", data.CFG.ListenHTTPS)
-	//err = http.Serve(listen, ctxGateMux)
-	err = http.Serve(listen, backend.AcmeCertManager.HTTPHandler(ctxGateMux))
+	gateway.FlushAccessStat()
+	utils.DebugPrintln("Shutdown completed")
+}
+
+// ReloadConfiguration reload config.json and the configuration of applications
+func ReloadConfiguration() {
+	err := data.ReloadConfig()
 	if err != nil {
-		utils.CheckError("http.Serve error", err)
-		utils.DebugPrintln("http.Serve error", err)
-		os.Exit(1)
+		utils.DebugPrintln("ReloadConfig error", err)
+		return
+	}
+	backend.LoadAppConfiguration()
+	firewall.InitFirewall()
+	data.LoadSettings()
+	firewall.LoadDiscoveryRules()
+}
+
+// WrapProxyListener parse PROXY protocol header from trusted load balancers if enabled, v1.5.3
+func WrapProxyListener(listen net.Listener) net.Listener {
+	cfg := data.CFG.ProxyProtocol
+	if !cfg.Enabled {
+		return listen
 	}
-	defer listen.Close()
+	return utils.NewProxyListener(listen, cfg.TrustedSources, time.Duration(cfg.HeaderTimeout)*time.Second, firewall.IsBlockedIP)
 }
 
 // AddContextHandler to add context handler
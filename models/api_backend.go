@@ -20,6 +20,20 @@ type APIApplicationRequest struct {
 	Object   *Application `json:"object"`
 }
 
+// APIMaintenanceRequest for set_app_maintenance, ObjectID is the ID of application, v1.5.3
+type APIMaintenanceRequest struct {
+	Action   string             `json:"action"`
+	ObjectID int64              `json:"id,string"`
+	Object   *MaintenanceConfig `json:"object"`
+}
+
+// APICachePurgeRequest for purge_cache, ObjectID is the ID of application, v1.5.3
+type APICachePurgeRequest struct {
+	Action   string      `json:"action"`
+	ObjectID int64       `json:"id,string"`
+	Object   *CachePurge `json:"object"`
+}
+
 type APIVipAppRequest struct {
 	Action   string  `json:"action"`
 	ObjectID int64   `json:"id,string"`
@@ -32,6 +46,13 @@ type APICertRequest struct {
 	Object   *CertItem `json:"object"`
 }
 
+// APIACMECertRequest for apply_acme_cert, ObjectID is the ID of certificate to be renewed, 0 for new, v1.5.3
+type APIACMECertRequest struct {
+	Action   string         `json:"action"`
+	ObjectID int64          `json:"id,string"`
+	Object   *ACMECertOrder `json:"object"`
+}
+
 type APIAppUserRequest struct {
 	Action   string        `json:"action"`
 	ObjectID int64         `json:"id,string"`
@@ -44,6 +65,12 @@ type APICookieRequest struct {
 	Object   *Cookie `json:"object"`
 }
 
+type APIRewriteRuleRequest struct {
+	Action   string       `json:"action"`
+	ObjectID int64        `json:"id,string"`
+	Object   *RewriteRule `json:"object"`
+}
+
 type APICookieRefRequest struct {
 	Action   string     `json:"action"`
 	ObjectID int64      `json:"id,string"`
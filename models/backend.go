@@ -10,19 +10,21 @@ package models
 import (
 	"crypto/tls"
 	"database/sql"
+	"regexp"
 	"sync"
+	"sync/atomic"
 )
 
 // Application i.e. Web site
 type Application struct {
 	ID             int64          `json:"id,string"`
 	Name           string         `json:"name"`
-	InternalScheme string         `json:"internal_scheme"` // http, https
+	InternalScheme string         `json:"internal_scheme"` // http, https, h2c or grpc (HTTP/2 without TLS), v1.5.3
 	Destinations   []*Destination `json:"destinations"`
 
-	// Route: map[string][]*Destination
-	// {"/abc/": ["192.168.1.1:8800", "192.168.1.2:8800"], ".php": [...], "/": [...]}
-	Route sync.Map `json:"-"`
+	// Routes is the route table built from destinations, replaced the Route sync.Map in v1.5.3
+	// [{"/api/v2/": [...]}, {"/api/": ["192.168.1.1:8800", "192.168.1.2:8800"]}, {".php": [...]}, {"/": [...]}]
+	Routes atomic.Pointer[RouteTable] `json:"-"`
 
 	Domains       []*Domain `json:"domains"`
 	RedirectHTTPS bool      `json:"redirect_https"`
@@ -64,13 +66,53 @@ type Application struct {
 	Cookies            []*Cookie `json:"cookies"`
 	// CustomHeader add by gateway, v1.4.2
 	CustomHeaders []*CustomHeader `json:"custom_headers"`
+
+	// RetryPolicy for failed requests and outlier ejection, v1.5.3
+	RetryPolicy *RetryPolicy `json:"retry_policy"`
+
+	// RewriteRules sorted by Sequence, v1.5.3
+	RewriteRules []*RewriteRule `json:"rewrite_rules"`
+
+	// TrafficSplits split traffic of routes between destination groups, v1.5.3
+	TrafficSplits []*TrafficSplit `json:"traffic_splits"`
+
+	// HeaderRules manipulate request and response headers, v1.5.3
+	HeaderRules []*HeaderRule `json:"header_rules"`
+
+	// ForwardedPolicy of X-Forwarded-For, X-Forwarded-Proto, X-Real-IP and Forwarded, v1.5.3
+	ForwardedPolicy ForwardedPolicy `json:"forwarded_policy"`
+
+	// TrustedProxies comma separated CIDR or IP of CDN and load balancers in front of the gateway,
+	// empty means the header of ClientIPMethod is trusted from any source
+	TrustedProxies string `json:"trusted_proxies"`
+
+	// RequestLimits of body size and timeouts, nil means no limit, v1.5.3
+	RequestLimits *RequestLimits `json:"request_limits"`
+
+	// ErrorPages custom error pages of the application, nil means using the global error page, v1.5.3
+	ErrorPages *ErrorPageConfig `json:"error_pages"`
+
+	// Maintenance mode of the application, nil means not in maintenance, v1.5.3
+	Maintenance *MaintenanceConfig `json:"maintenance"`
+
+	// Compression of dynamic responses, nil means not compressed by the gateway, v1.5.3
+	Compression *CompressionConfig `json:"compression"`
+
+	// CachePolicy rules of the HTTP cache, used when CacheEnabled, nil means caching static resources only, v1.5.3
+	CachePolicy *CachePolicy `json:"cache_policy"`
+
+	// UpstreamTLS for HTTPS backends, nil means the certificates of backends are not verified, v1.5.3
+	UpstreamTLS *UpstreamTLS `json:"upstream_tls"`
+
+	// ClientAuth requires client certificates of the domains of the application, nil means none, v1.5.3
+	ClientAuth *ClientAuthConfig `json:"client_auth"`
 }
 
 // DBApplication for storage in database
 type DBApplication struct {
 	ID             int64  `json:"id,string"`
 	Name           string `json:"name"`
-	InternalScheme string `json:"internal_scheme"` // http, https
+	InternalScheme string `json:"internal_scheme"` // http, https, h2c or grpc
 	RedirectHTTPS  bool   `json:"redirect_https"`
 	HSTSEnabled    bool   `json:"hsts_enabled"`
 	WAFEnabled     bool   `json:"waf_enabled"`
@@ -106,6 +148,273 @@ type DBApplication struct {
 	EnableUnclassified bool   `json:"enable_unclassified"`
 	// CustomHeaders add by gateway, v1.4.2
 	CustomHeaders string `json:"custom_headers"`
+
+	// RetryPolicy JSON string, v1.5.3
+	RetryPolicy string `json:"retry_policy"`
+
+	// TrafficSplits JSON string, v1.5.3
+	TrafficSplits string `json:"traffic_splits"`
+
+	// HeaderRules JSON string, v1.5.3
+	HeaderRules string `json:"header_rules"`
+
+	ForwardedPolicy ForwardedPolicy `json:"forwarded_policy"`
+	TrustedProxies  string          `json:"trusted_proxies"`
+
+	// RequestLimits JSON string, v1.5.3
+	RequestLimits string `json:"request_limits"`
+
+	// ErrorPages JSON string, v1.5.3
+	ErrorPages string `json:"error_pages"`
+
+	// Maintenance JSON string, v1.5.3
+	Maintenance string `json:"maintenance"`
+
+	// Compression JSON string, v1.5.3
+	Compression string `json:"compression"`
+
+	// CachePolicy JSON string, v1.5.3
+	CachePolicy string `json:"cache_policy"`
+
+	// UpstreamTLS JSON string, v1.5.3
+	UpstreamTLS string `json:"upstream_tls"`
+
+	// ClientAuth JSON string, v1.5.3
+	ClientAuth string `json:"client_auth"`
+}
+
+// TrafficSplit used for canary release, split traffic of a route between destination groups, v1.5.3
+type TrafficSplit struct {
+	// RequestRoute such as / or /api/ , same as RequestRoute of destinations
+	RequestRoute string `json:"request_route"`
+
+	// RouteMatch, Methods, HeaderMatch and Priority identify the route together with RequestRoute,
+	// same as the fields of destinations
+	RouteMatch  RouteMatch `json:"route_match"`
+	Methods     string     `json:"methods"`
+	HeaderMatch string     `json:"header_match"`
+	Priority    int64      `json:"priority"`
+
+	// Groups with percentage, the group name is Destination.Group
+	Groups []*SplitGroup `json:"groups"`
+
+	// Matchers pin a request to a group, checked in order
+	Matchers []*SplitMatcher `json:"matchers"`
+
+	// Sticky keep a client on its group by cookie
+	Sticky bool `json:"sticky"`
+}
+
+// SplitGroup is a named destination group with percentage
+type SplitGroup struct {
+	Name    string `json:"name"`
+	Percent int64  `json:"percent"`
+}
+
+// SplitMatcher pin the request to Group if matched
+type SplitMatcher struct {
+	// Type: header, cookie, query or ip
+	Type string `json:"type"`
+	// Name of header, cookie or query parameter, not used by ip
+	Name string `json:"name"`
+	// Value for exact match, empty means exists, CIDR for ip, such as 10.0.0.0/8
+	Value string `json:"value"`
+	Group string `json:"group"`
+}
+
+// RetryPolicy used for retrying on other destinations and passive outlier detection, v1.5.3
+type RetryPolicy struct {
+	// Retries is the max number of retries on other destinations of the same route, 0 means disabled
+	// Idempotent requests are retried on connection errors and 502/503/504,
+	// other requests are retried on dial errors only
+	Retries int64 `json:"retries"`
+
+	// OutlierDetection eject the destination after consecutive 5xx or timeouts
+	OutlierDetection bool `json:"outlier_detection"`
+	// ConsecutiveErrors default 5
+	ConsecutiveErrors int64 `json:"consecutive_errors"`
+	// BaseEjectionSeconds default 30, doubled for each ejection until MaxEjectionSeconds
+	BaseEjectionSeconds int64 `json:"base_ejection_seconds"`
+	// MaxEjectionSeconds default 300
+	MaxEjectionSeconds int64 `json:"max_ejection_seconds"`
+	// SlowStartSeconds default 30, traffic increases gradually after ejection
+	SlowStartSeconds int64 `json:"slow_start_seconds"`
+}
+
+// RequestLimits protect the gateway and backends from large bodies and slow clients, v1.5.3
+// Zero value means no limit, or using the value of upstream in config.json for timeouts
+type RequestLimits struct {
+	// MaxBodySize in bytes of the request body, 413 if exceeded
+	MaxBodySize int64 `json:"max_body_size"`
+	// MaxUploadSize in bytes of the multipart/form-data request body, 413 if exceeded
+	MaxUploadSize int64 `json:"max_upload_size"`
+	// BodyTimeout in seconds for reading the request body from the client, 408 if exceeded
+	BodyTimeout int64 `json:"body_timeout"`
+	// ConnectTimeout in seconds for connecting to the backend
+	ConnectTimeout int64 `json:"connect_timeout"`
+	// ResponseTimeout in seconds waiting for the response header of the backend, 504 if exceeded
+	ResponseTimeout int64 `json:"response_timeout"`
+	// MaxInspectSize in bytes of the request body inspected by WAF, 413 if exceeded when WAF enabled, 0 means no limit
+	MaxInspectSize int64 `json:"max_inspect_size"`
+}
+
+// MaintenanceConfig put the application into maintenance without deleting destinations, v1.5.3
+// Requests get 503 with the maintenance page unless bypassed
+type MaintenanceConfig struct {
+	Enabled bool `json:"enabled"`
+	// StartTime and EndTime are unix timestamps of the schedule window, 0 means no limit
+	StartTime int64 `json:"start_time"`
+	EndTime   int64 `json:"end_time"`
+	// RetryAfter in seconds of the Retry-After header, 0 means the seconds until EndTime
+	RetryAfter int64 `json:"retry_after"`
+	// Description shown in the page, variable {{ .Description }}
+	Description string `json:"description"`
+	// HTML and JSON templates of the page, empty means using the 503 error page
+	HTML string `json:"html"`
+	JSON string `json:"json"`
+	// BypassIPs comma separated CIDR or IP allowed to visit the backends
+	BypassIPs string `json:"bypass_ips"`
+	// BypassSecret in header X-Maintenance-Bypass or cookie janusec-maintenance-bypass
+	BypassSecret string `json:"bypass_secret"`
+	// BypassUsers comma separated usernames authenticated by OAuth of the application,
+	// the owner of the application is also allowed
+	BypassUsers string `json:"bypass_users"`
+}
+
+// CachePolicy of the HTTP cache, responses are cached by Cache-Control, Expires, Vary and ETag of backends, v1.5.3
+type CachePolicy struct {
+	// Rules are checked in order, requests not matched are cached only if they are static resources
+	Rules []*CacheRule `json:"rules"`
+	// StaleWhileRevalidate in seconds, serve the stale response and revalidate in background,
+	// used if not given by Cache-Control of the response
+	StaleWhileRevalidate int64 `json:"stale_while_revalidate"`
+	// StaleIfError in seconds, serve the stale response if the backends are offline or return 5xx,
+	// used if not given by Cache-Control of the response
+	StaleIfError int64 `json:"stale_if_error"`
+}
+
+// CacheRule for the requests matching the path prefix, v1.5.3
+type CacheRule struct {
+	// PathPrefix such as /api/news/ , / means all paths
+	PathPrefix string `json:"path_prefix"`
+	// Bypass the cache, the responses are neither served from nor stored in the cache
+	Bypass bool `json:"bypass"`
+	// TTL in seconds overrides the freshness lifetime given by the backend, 0 means using Cache-Control or Expires
+	TTL int64 `json:"ttl"`
+	// IgnoreQuery remove the query string from the cache key
+	IgnoreQuery bool `json:"ignore_query"`
+	// QueryKeys only these query parameters are included in the cache key, empty means all
+	QueryKeys []string `json:"query_keys"`
+}
+
+// UpstreamTLS is the TLS setting of connections to HTTPS backends, v1.5.3
+type UpstreamTLS struct {
+	// Verify the certificate of backends, against the CA certificate or system roots if CACertID is 0
+	Verify bool `json:"verify"`
+	// CACertID is the ID of the CA certificate in certificates, private key is not required
+	CACertID int64 `json:"ca_cert_id,string"`
+	// ServerName overrides the SNI and the hostname for verification, the Host of request by default
+	ServerName string `json:"server_name"`
+	// ClientCertID is the ID of the certificate presented to backends for mutual TLS, 0 means none
+	ClientCertID int64 `json:"client_cert_id,string"`
+	// MinVersion is 1.0, 1.1, 1.2 or 1.3, default 1.2
+	MinVersion string `json:"min_version"`
+}
+
+// ClientAuthConfig of client certificate (mutual TLS) authentication, v1.5.3
+type ClientAuthConfig struct {
+	Mode ClientAuthMode `json:"mode"`
+	// CACertID is the ID of the CA certificate in certificates, which issued the client certificates
+	CACertID int64 `json:"ca_cert_id,string"`
+}
+
+// ClientAuthMode of ClientAuthConfig, v1.5.3
+type ClientAuthMode int64
+
+const (
+	// ClientAuth_NONE client certificates are not requested (default)
+	ClientAuth_NONE ClientAuthMode = 0
+	// ClientAuth_OPTIONAL client certificates are verified if given
+	ClientAuth_OPTIONAL ClientAuthMode = 1
+	// ClientAuth_REQUIRED requests without verified client certificates are rejected
+	ClientAuth_REQUIRED ClientAuthMode = 1 << 1
+)
+
+// CachePurgeType of CachePurge
+type CachePurgeType string
+
+const (
+	// CachePurge_APP purge all entries of the application
+	CachePurge_APP CachePurgeType = "app"
+	// CachePurge_URL purge the URL such as https://www.example.com/a.js?v=1 , or /a.js?v=1 for all domains of the application
+	CachePurge_URL CachePurgeType = "url"
+	// CachePurge_PREFIX purge the URLs with the prefix such as www.example.com/static/ , or /static/ for all domains
+	CachePurge_PREFIX CachePurgeType = "prefix"
+	// CachePurge_TAG purge the entries tagged by Surrogate-Key header of the backend
+	CachePurge_TAG CachePurgeType = "tag"
+)
+
+// CachePurge command, synchronized to replica nodes, v1.5.3
+type CachePurge struct {
+	ID    int64          `json:"id,string"`
+	AppID int64          `json:"app_id,string"`
+	Type  CachePurgeType `json:"type"`
+	Value string         `json:"value"`
+	// PurgeTime only the entries stored before it are removed, so that the command can be applied more than once
+	PurgeTime int64 `json:"purge_time"`
+	// Purged is the number of entries removed on the primary node
+	Purged int64 `json:"purged"`
+}
+
+// CacheStat of an application, counters are accumulated since the node started, v1.5.3
+type CacheStat struct {
+	AppID   int64  `json:"app_id,string"`
+	AppName string `json:"app_name"`
+	// Hits served from the cache including stale and revalidated responses
+	Hits        int64 `json:"hits"`
+	Stale       int64 `json:"stale"`
+	Revalidated int64 `json:"revalidated"`
+	// Misses include the expired entries which are fetched again
+	Misses   int64   `json:"misses"`
+	Bypass   int64   `json:"bypass"`
+	HitRatio float64 `json:"hit_ratio"`
+	Entries  int64   `json:"entries"`
+	// Size in bytes of the bodies, MemorySize is the part in memory tier
+	Size       int64 `json:"size"`
+	MemorySize int64 `json:"memory_size"`
+	// Nodes reported the statistics
+	Nodes int64 `json:"nodes"`
+}
+
+// CompressionConfig of the responses not compressed by backends, v1.5.3
+type CompressionConfig struct {
+	Enabled bool `json:"enabled"`
+	// Algorithms in order of preference: br, zstd, gzip, empty means all
+	Algorithms []string `json:"algorithms"`
+	// ContentTypes allowed to compress, such as text/html, text/*, empty means the default list of text types
+	ContentTypes []string `json:"content_types"`
+	// MinSize in bytes, smaller responses are not compressed, default 1024
+	MinSize int64 `json:"min_size"`
+}
+
+// ErrorPageConfig of an application, v1.5.3
+type ErrorPageConfig struct {
+	// SupportContact such as email or phone number, empty means using the global support contact
+	SupportContact string `json:"support_contact"`
+	// Pages of status codes, the global error page is used for other status codes
+	Pages []*ErrorPage `json:"pages"`
+}
+
+// ErrorPage is the template of an error status, HTML or JSON is selected by the Accept header of the request
+// Variables: {{ .StatusCode }} {{ .StatusText }} {{ .Description }} {{ .RequestID }} {{ .SupportContact }} {{ .Host }} {{ .Path }} {{ .ClientIP }} {{ .Time }}
+type ErrorPage struct {
+	// StatusCode: 400, 403, 404, 429, 500, 502, 503, 504 etc.
+	StatusCode int `json:"status_code"`
+	// HTML template, empty means using the global HTML error page
+	HTML string `json:"html"`
+	// JSON template, fields should be written as {{ json .Path }} which outputs a quoted JSON string,
+	// fields used directly are escaped for JSON strings, invalid JSON falls back to the default JSON error page
+	JSON string `json:"json"`
 }
 
 type CustomHeader struct {
@@ -113,11 +422,27 @@ type CustomHeader struct {
 	Value string `json:"value"`
 }
 
+// HeaderRule set, append or remove a request or response header, v1.5.3
+type HeaderRule struct {
+	// Direction: request or response
+	Direction string `json:"direction"`
+	// Route is the path prefix such as /api/ , empty means all routes
+	Route string `json:"route"`
+	// Action: set, append or remove
+	Action string `json:"action"`
+	Name   string `json:"name"`
+	// Value support variables: ${client_ip} ${request_id} ${tls_version} ${auth_user} ${geo_country} ${host} ${scheme}
+	Value string `json:"value"`
+}
+
 type DomainRelation struct {
-	App      *Application
-	Cert     *CertItem
-	Redirect bool
-	Location string
+	App  *Application
+	Cert *CertItem
+	// Certs are Cert and the extra certificates, ECDSA first, v1.5.3
+	Certs      []*CertItem
+	Redirect   bool
+	Location   string
+	TLSProfile *TLSProfile
 }
 
 type Domain struct {
@@ -129,6 +454,16 @@ type Domain struct {
 	Location string       `json:"location"`
 	App      *Application `json:"-"`
 	Cert     *CertItem    `json:"-"`
+
+	// TLSProfile of HTTPS, nil means intermediate, v1.5.3
+	TLSProfile *TLSProfile `json:"tls_profile"`
+
+	// ExtraCertIDs comma separated IDs of other certificates, such as RSA for the ECDSA CertID,
+	// selected by the ClientHello, v1.5.3
+	ExtraCertIDs string `json:"extra_cert_ids"`
+
+	// CertWarning such as the certificates not matching the domain name, v1.5.3
+	CertWarning string `json:"cert_warning,omitempty"`
 }
 
 type DBDomain struct {
@@ -138,6 +473,43 @@ type DBDomain struct {
 	CertID   int64  `json:"cert_id,string"`
 	Redirect bool   `json:"redirect"`
 	Location string `json:"location"`
+
+	// TLSProfile JSON string, v1.5.3
+	TLSProfile string `json:"tls_profile"`
+
+	// ExtraCertIDs comma separated IDs, v1.5.3
+	ExtraCertIDs string `json:"extra_cert_ids"`
+}
+
+// TLSProfileName of TLSProfile, v1.5.3
+type TLSProfileName string
+
+const (
+	// TLSProfile_MODERN TLS 1.3 only
+	TLSProfile_MODERN TLSProfileName = "modern"
+	// TLSProfile_INTERMEDIATE TLS 1.2 and 1.3 with AEAD cipher suites (default)
+	TLSProfile_INTERMEDIATE TLSProfileName = "intermediate"
+	// TLSProfile_LEGACY TLS 1.0 and later with CBC cipher suites, for old clients only
+	TLSProfile_LEGACY TLSProfileName = "legacy"
+	// TLSProfile_CUSTOM versions, cipher suites and curves given by the profile
+	TLSProfile_CUSTOM TLSProfileName = "custom"
+)
+
+// TLSProfile of the HTTPS domain, v1.5.3
+type TLSProfile struct {
+	Name TLSProfileName `json:"name"`
+	// MinVersion and MaxVersion are 1.0, 1.1, 1.2 or 1.3, used by custom profile
+	MinVersion string `json:"min_version"`
+	MaxVersion string `json:"max_version"`
+	// CipherSuites such as TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, used by custom profile,
+	// the cipher suites of TLS 1.3 are not configurable
+	CipherSuites []string `json:"cipher_suites"`
+	// Curves such as X25519, P256, P384 and P521, used by custom profile, empty means the default
+	Curves []string `json:"curves"`
+	// ALPN such as h2 and http/1.1, empty means h2 and http/1.1
+	ALPN []string `json:"alpn"`
+	// SessionTicketsDisabled disable the session resumption by session tickets
+	SessionTicketsDisabled bool `json:"session_tickets_disabled"`
 }
 
 // RouteType used for backend routing
@@ -157,6 +529,50 @@ const (
 	K8S_Ingress RouteType = 1 << 3
 )
 
+// RouteMatch is the matching type of RequestRoute, v1.5.3
+type RouteMatch int64
+
+const (
+	// RouteMatch_PREFIX /abc/ or /abc/xyz/ match the path prefix, .php match the file extension, default
+	RouteMatch_PREFIX RouteMatch = 0
+
+	// RouteMatch_EXACT match the whole path
+	RouteMatch_EXACT RouteMatch = 1
+
+	// RouteMatch_REGEX match the path with regular expression, BackendRoute can use $1 etc.
+	RouteMatch_REGEX RouteMatch = 1 << 1
+
+	// RouteMatch_GRPC match gRPC requests by package.Service/Method , or package.Service for all methods,
+	// BackendRoute renames the service or method in the same format
+	RouteMatch_GRPC RouteMatch = 1 << 2
+)
+
+// RouteTable is the sorted route entries of an application, v1.5.3
+type RouteTable struct {
+	Entries []*RouteEntry
+}
+
+// RouteEntry is a group of destinations with the same match conditions
+type RouteEntry struct {
+	RequestRoute string
+	Match        RouteMatch
+	Regex        *regexp.Regexp
+	// Methods empty means any method
+	Methods     []string
+	HeaderName  string
+	HeaderValue string
+	Priority    int64
+
+	// LBMethod and HashKey of the route, taken once from its destinations, v1.5.3
+	LBMethod LBMethod
+	HashKey  string
+
+	// Split is the traffic split of the route, nil if not configured
+	Split *TrafficSplit
+
+	Destinations []*Destination
+}
+
 // Destination is used for backend routing
 type Destination struct {
 	ID int64 `json:"id,string"`
@@ -170,6 +586,31 @@ type Destination struct {
 	// 0.9.8+
 	BackendRoute string `json:"backend_route"`
 
+	// RouteMatch of RequestRoute: prefix (default), exact, regex or grpc, v1.5.3
+	RouteMatch RouteMatch `json:"route_match"`
+
+	// Methods such as GET,HEAD , empty means any method
+	Methods string `json:"methods"`
+
+	// HeaderMatch such as `X-Version: v2` , or `X-Version` means the header exists
+	HeaderMatch string `json:"header_match"`
+
+	// Priority of the route, higher is matched first, default 0
+	Priority int64 `json:"priority"`
+
+	// MirrorTarget is the shadow backend IP:Port, requests are duplicated to it asynchronously, v1.5.3
+	MirrorTarget string `json:"mirror_target"`
+
+	// MirrorPercent is the sample percentage (0-100) of requests mirrored
+	MirrorPercent int64 `json:"mirror_percent"`
+
+	// Group name used by traffic split, empty is the default group, v1.5.3
+	Group string `json:"group"`
+
+	// ProxyProtocol version sent to the backend: 0 none, 1 v1, 2 v2, v1.5.3
+	// Connections are not reused across requests if enabled
+	ProxyProtocol int64 `json:"proxy_protocol"`
+
 	// Destination is backend IP:Port , or static directory
 	// If RoutyType is K8S, this field is not used
 	Destination string `json:"destination"`
@@ -188,11 +629,92 @@ type Destination struct {
 	Online    bool  `json:"online"`
 	CheckTime int64 `json:"check_time"`
 
+	// LBMethod is the load balancing method of the route, destinations in the same route should use the same value
+	LBMethod LBMethod `json:"lb_method"`
+	// Weight used by weighted round robin, least requests and consistent hash, default 1
+	Weight int64 `json:"weight"`
+	// HashKey used by consistent hash, ip (default), header:Header-Name or cookie:CookieName
+	HashKey string `json:"hash_key"`
+
 	// added in 1.3.1, K8s routine updating and avoid race
 	Mutex      sync.RWMutex `json:"-"`
 	IsUpdating bool         `json:"-"`
+
+	// CurrentWeight used by smooth weighted round robin
+	CurrentWeight int64 `json:"-"`
+	// Outstanding is the number of requests in progress
+	Outstanding atomic.Int64 `json:"-"`
+
+	// HealthCheck is the active health check configuration, nil or disabled means TCP dial check for offline destinations
+	HealthCheck *HealthCheck `json:"health_check"`
+	HealthState
+
+	// EjectedUntil is the timestamp when outlier ejection ends
+	EjectedUntil      int64 `json:"ejected_until"`
+	Ejections         int64 `json:"-"`
+	ConsecutiveErrors int64 `json:"-"`
+}
+
+// HealthCheck configuration of active health check for Destination and VipTarget, v1.5.3
+type HealthCheck struct {
+	Enabled bool `json:"enabled"`
+	// Scheme: http, https or tcp
+	Scheme string `json:"scheme"`
+	// Method: GET, HEAD, etc.
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	// Host header, optional
+	Host string `json:"host"`
+	// ExpectedStatus example: 200-399 or 200,204
+	ExpectedStatus string `json:"expected_status"`
+	// BodyRegex optional, the response body must match it
+	BodyRegex string `json:"body_regex"`
+	// Interval and Timeout in seconds
+	Interval int64 `json:"interval"`
+	Timeout  int64 `json:"timeout"`
+	// Rise is the number of consecutive successes to mark online
+	Rise int64 `json:"rise"`
+	// Fall is the number of consecutive failures to mark offline
+	Fall int64 `json:"fall"`
 }
 
+// HealthState is the runtime state of active health check
+type HealthState struct {
+	Successes    int64          `json:"-"`
+	Failures     int64          `json:"-"`
+	LastCheck    int64          `json:"-"`
+	IsChecking   bool           `json:"-"`
+	CheckResult  string         `json:"check_result"`
+	HealthEvents []*HealthEvent `json:"health_events"`
+}
+
+// HealthEvent records the transition of online status
+type HealthEvent struct {
+	Time   int64  `json:"time"`
+	Online bool   `json:"online"`
+	Reason string `json:"reason"`
+}
+
+// LBMethod used for selecting a destination in the same route
+type LBMethod int64
+
+const (
+	// LBMethod_IP_UA_HASH is the default method, Hash(IP+UA) % len(online destinations)
+	LBMethod_IP_UA_HASH LBMethod = 0
+
+	// LBMethod_WEIGHTED_ROUND_ROBIN smooth weighted round robin
+	LBMethod_WEIGHTED_ROUND_ROBIN LBMethod = 1
+
+	// LBMethod_LEAST_REQUESTS select the destination with least outstanding requests / weight
+	LBMethod_LEAST_REQUESTS LBMethod = 1 << 1
+
+	// LBMethod_RANDOM_TWO_CHOICES select two destinations randomly and use the one with less outstanding requests
+	LBMethod_RANDOM_TWO_CHOICES LBMethod = 1 << 2
+
+	// LBMethod_CONSISTENT_HASH hash ring keyed by client IP, header or cookie
+	LBMethod_CONSISTENT_HASH LBMethod = 1 << 3
+)
+
 // PODS for k8s /api/v1/namespaces/default/pods
 type PODS struct {
 	Items []Item `json:"items"`
@@ -215,6 +737,22 @@ type CertItem struct {
 	TlsCert        tls.Certificate `json:"-"`
 	ExpireTime     int64           `json:"expire_time"`
 	Description    string          `json:"description"`
+
+	// Domains using the certificate, MismatchedDomains are not covered by the certificate, v1.5.3
+	Domains           []string `json:"domains,omitempty"`
+	MismatchedDomains []string `json:"mismatched_domains,omitempty"`
+
+	// ACMEDomains of the certificate obtained by ACME, renewed automatically if not empty, v1.5.3
+	ACMEDomains []string `json:"acme_domains,omitempty"`
+}
+
+// ACMECertOrder of certificate obtained by ACME with DNS-01 challenges, v1.5.3
+type ACMECertOrder struct {
+	// Domains such as example.com and *.example.com, the first one is used as the common name
+	Domains []string `json:"domains"`
+	// KeyType ecdsa (default) or rsa
+	KeyType     string `json:"key_type"`
+	Description string `json:"description"`
 }
 
 type DBCertItem struct {
@@ -224,6 +762,7 @@ type DBCertItem struct {
 	EncryptedPrivKey []byte
 	ExpireTime       int64
 	Description      sql.NullString
+	ACMEDomains      string
 }
 
 type IPMethod int64
@@ -235,6 +774,18 @@ const (
 	IPMethod_REAL_IP         IPMethod = 1 << 3
 )
 
+// ForwardedPolicy of forwarding headers sent to backends, v1.5.3
+type ForwardedPolicy int64
+
+const (
+	// ForwardedPolicy_APPEND append the peer address to X-Forwarded-For and Forwarded (default)
+	ForwardedPolicy_APPEND ForwardedPolicy = 0
+	// ForwardedPolicy_REPLACE discard the received forwarding headers, use the client IP only
+	ForwardedPolicy_REPLACE ForwardedPolicy = 1
+	// ForwardedPolicy_NONE pass the received headers without adding any
+	ForwardedPolicy_NONE ForwardedPolicy = 1 << 1
+)
+
 // VipApp configuration, added from 0.9.12, database table name forwarding_app
 type VipApp struct {
 	ID int64 `json:"id,string"`
@@ -283,4 +834,14 @@ type VipTarget struct {
 	// Online status of Destination (IP:Port)
 	Online    bool  `json:"online"`
 	CheckTime int64 `json:"check_time"`
+
+	// HealthCheck is the active health check configuration, v1.5.3
+	HealthCheck *HealthCheck `json:"health_check"`
+
+	// ProxyProtocol version sent to the TCP target: 0 none, 1 v1, 2 v2, v1.5.3
+	ProxyProtocol int64 `json:"proxy_protocol"`
+	HealthState
+
+	// Mutex guards Online, CheckTime and HealthState, v1.5.3
+	Mutex sync.RWMutex `json:"-"`
 }
@@ -9,11 +9,31 @@ package models
 
 // Config is the format of config.json
 type Config struct {
-	NodeRole    string            `json:"node_role"`
-	ListenHTTP  string            `json:"listen_http"`
-	ListenHTTPS string            `json:"listen_https"`
+	NodeRole    string `json:"node_role"`
+	ListenHTTP  string `json:"listen_http"`
+	ListenHTTPS string `json:"listen_https"`
+	// ListenHTTP3 is the UDP address of HTTP/3 (QUIC) listener such as :443, empty means disabled, optional, v1.5.3
+	ListenHTTP3 string            `json:"listen_http3,omitempty"`
 	PrimaryNode PrimaryNodeConfig `json:"primary_node"`
 	ReplicaNode ReplicaNodeConfig `json:"replica_node"`
+
+	// Upstream is the connection pool setting for backend destinations, optional
+	Upstream *UpstreamConfig `json:"upstream,omitempty"`
+
+	// ProxyProtocol of the gateway listeners, optional, v1.5.3
+	ProxyProtocol *ProxyProtocolConfig `json:"proxy_protocol,omitempty"`
+
+	// ShutdownTimeout in seconds, waiting for in-flight requests when SIGTERM received, optional
+	ShutdownTimeout int64 `json:"shutdown_timeout,omitempty"`
+
+	// Server is the timeouts and header size of the gateway and admin servers, optional
+	Server *ServerConfig `json:"server,omitempty"`
+
+	// Cache is the size of memory and disk used by the HTTP cache, optional
+	Cache *CacheStoreConfig `json:"cache,omitempty"`
+
+	// ACME is the certificate authority used to obtain certificates with DNS-01 challenges, optional, v1.5.3
+	ACME *ACMEConfig `json:"acme,omitempty"`
 }
 
 type OAuthConfig struct {
@@ -44,6 +64,69 @@ type AdminConfig struct {
 	ListenHTTPS   string `json:"listen_https"`
 	Portal        string `json:"portal"`
 	WebSSHEnabled bool   `json:"webssh_enabled"`
+	// ClientCACert is the PEM file of CA certificates, the admin portal on ListenHTTPS requires
+	// client certificates issued by them if not empty, v1.5.3
+	ClientCACert string `json:"client_ca_cert,omitempty"`
+}
+
+// UpstreamConfig used for the pooled transports to backend destinations
+// Timeouts are in seconds, zero value means using the default value
+type UpstreamConfig struct {
+	MaxIdleConns          int   `json:"max_idle_conns"`
+	MaxIdleConnsPerHost   int   `json:"max_idle_conns_per_host"`
+	MaxConnsPerHost       int   `json:"max_conns_per_host"`
+	DialTimeout           int64 `json:"dial_timeout"`
+	IdleConnTimeout       int64 `json:"idle_conn_timeout"`
+	TLSHandshakeTimeout   int64 `json:"tls_handshake_timeout"`
+	ResponseHeaderTimeout int64 `json:"response_header_timeout"`
+}
+
+// ProxyProtocolConfig used for the gateway listeners behind L4 load balancers
+// PROXY protocol v1 and v2 headers are accepted only from TrustedSources (CIDR or IP)
+type ProxyProtocolConfig struct {
+	Enabled        bool     `json:"enabled"`
+	TrustedSources []string `json:"trusted_sources"`
+	// HeaderTimeout in seconds, zero value means using the default value
+	HeaderTimeout int64 `json:"header_timeout"`
+}
+
+// ServerConfig used for slow-client protection, timeouts are in seconds
+// Zero value means using the default value, -1 means no timeout
+type ServerConfig struct {
+	// ReadHeaderTimeout also limits the TLS handshake, default 10
+	ReadHeaderTimeout int64 `json:"read_header_timeout"`
+	// ReadTimeout of the entire request including body, default no timeout, see body_timeout of applications
+	ReadTimeout int64 `json:"read_timeout"`
+	// WriteTimeout of the response, default no timeout for large downloads and WebSocket
+	WriteTimeout int64 `json:"write_timeout"`
+	// IdleTimeout of keep-alive connections, default 120
+	IdleTimeout int64 `json:"idle_timeout"`
+	// MaxHeaderBytes default 1MB
+	MaxHeaderBytes int `json:"max_header_bytes"`
+}
+
+// CacheStoreConfig of the HTTP cache, sizes are in MB, zero value means using the default value
+type CacheStoreConfig struct {
+	// MemorySize default 64, hot entries are kept in memory
+	MemorySize int64 `json:"memory_size"`
+	// DiskSize default 1024, entries are stored in ./static/cdncache/ , -1 means memory only
+	DiskSize int64 `json:"disk_size"`
+	// MaxObjectSize default 10, larger responses are not cached
+	MaxObjectSize int64 `json:"max_object_size"`
+	// MaxMemoryObjectSize in KB default 512, larger entries are kept on disk only
+	MaxMemoryObjectSize int64 `json:"max_memory_object_size"`
+}
+
+// ACMEConfig of the ACME client, DNS-01 challenges are answered by the built-in DNS server of the primary node
+type ACMEConfig struct {
+	// DirectoryURL default is Let's Encrypt, such as https://localhost:14000/dir for Pebble
+	DirectoryURL string `json:"directory_url"`
+	// Email is the contact of the ACME account, optional
+	Email string `json:"email"`
+	// CACert is the PEM file of CA certificates trusted for the directory, such as the root of Pebble, optional
+	CACert string `json:"ca_cert,omitempty"`
+	// RenewBefore in days before expiry, default 30
+	RenewBefore int64 `json:"renew_before"`
 }
 
 type DBConfig struct {
@@ -55,11 +138,31 @@ type DBConfig struct {
 }
 
 type EncryptedConfig struct {
-	NodeRole    string            `json:"node_role"`
-	ListenHTTP  string            `json:"listen_http"`
-	ListenHTTPS string            `json:"listen_https"`
+	NodeRole    string `json:"node_role"`
+	ListenHTTP  string `json:"listen_http"`
+	ListenHTTPS string `json:"listen_https"`
+	// ListenHTTP3 is the UDP address of HTTP/3 (QUIC) listener such as :443, empty means disabled, optional, v1.5.3
+	ListenHTTP3 string            `json:"listen_http3,omitempty"`
 	PrimaryNode PrimaryNodeConfig `json:"primary_node"`
 	ReplicaNode ReplicaNodeConfig `json:"replica_node"`
+
+	// Upstream is the connection pool setting for backend destinations, optional
+	Upstream *UpstreamConfig `json:"upstream,omitempty"`
+
+	// ProxyProtocol of the gateway listeners, optional, v1.5.3
+	ProxyProtocol *ProxyProtocolConfig `json:"proxy_protocol,omitempty"`
+
+	// ShutdownTimeout in seconds, waiting for in-flight requests when SIGTERM received, optional
+	ShutdownTimeout int64 `json:"shutdown_timeout,omitempty"`
+
+	// Server is the timeouts and header size of the gateway and admin servers, optional
+	Server *ServerConfig `json:"server,omitempty"`
+
+	// Cache is the size of memory and disk used by the HTTP cache, optional
+	Cache *CacheStoreConfig `json:"cache,omitempty"`
+
+	// ACME is the certificate authority used to obtain certificates with DNS-01 challenges, optional, v1.5.3
+	ACME *ACMEConfig `json:"acme,omitempty"`
 }
 
 type WxworkConfig struct {
@@ -59,9 +59,17 @@ type PopularContent struct {
 	Amount  int64  `json:"amount"`
 }
 
-// InternalErrorInfo i.e. 502 or server offline
-type InternalErrorInfo struct {
-	Description string `json:"description"`
+// ErrorPageInfo is the variables of error page templates, v1.5.3
+type ErrorPageInfo struct {
+	StatusCode     int    `json:"status_code"`
+	StatusText     string `json:"status_text"`
+	Description    string `json:"description"`
+	RequestID      string `json:"request_id"`
+	SupportContact string `json:"support_contact"`
+	Host           string `json:"host"`
+	Path           string `json:"path"`
+	ClientIP       string `json:"client_ip"`
+	Time           string `json:"time"`
 }
 
 // GateHealth give basic information
@@ -137,6 +145,16 @@ type PrimarySetting struct {
 	// ShieldHTML for 5-second shield, v1.4.1 added
 	ShieldHTML string `json:"shield_html"`
 
+	// HiddenHeaders is comma separated response headers removed from all backends, v1.5.3
+	HiddenHeaders string `json:"hidden_headers"`
+
+	// ErrorPageHTML and ErrorPageJSON are the default error pages of all applications, v1.5.3
+	ErrorPageHTML string `json:"error_page_html"`
+	ErrorPageJSON string `json:"error_page_json"`
+
+	// SupportContact shown in error pages, v1.5.3
+	SupportContact string `json:"support_contact"`
+
 	// WAFLogDays for WAF logs
 	WAFLogDays int64 `json:"waf_log_days"`
 
@@ -157,6 +175,9 @@ type PrimarySetting struct {
 
 	// DNS GSLB, v1.4.1 added
 	DNSEnabled bool `json:"dns_enabled"`
+
+	// SessionTicketRotation in hours, the interval of rotating TLS session ticket keys, default 24, v1.5.3
+	SessionTicketRotation int64 `json:"session_ticket_rotation"`
 }
 
 // NodeShareSetting for sync to replica nodes, v1.2.0
@@ -170,6 +191,13 @@ type NodeShareSetting struct {
 	// DiscoveryLastModified is the timestamp fot latest change of DiscoveryRules
 	DiscoveryLastModified int64 `json:"discovery_last_modified"`
 
+	// CachePurgeLastID is the ID of latest cache purge command, not persisted, v1.5.3
+	CachePurgeLastID int64 `json:"cache_purge_last_id"`
+
+	// SessionTicketKeys are hex TLS session ticket keys shared by all nodes, the first one is used
+	// for new tickets, generated by primary node and not persisted, v1.5.3
+	SessionTicketKeys []string `json:"session_ticket_keys"`
+
 	// SyncDuration for replica nodes to check update
 	// SyncDuration = "sync_seconds" * time.Second
 	SyncInterval time.Duration `json:"sync_interval"`
@@ -184,6 +212,16 @@ type NodeShareSetting struct {
 	// ShieldHTML for 5-second shield, v1.4.1 added
 	ShieldHTML string `json:"shield_html"`
 
+	// HiddenHeaders is comma separated response headers removed from all backends, v1.5.3
+	HiddenHeaders string `json:"hidden_headers"`
+
+	// ErrorPageHTML and ErrorPageJSON are the default error pages of all applications, v1.5.3
+	ErrorPageHTML string `json:"error_page_html"`
+	ErrorPageJSON string `json:"error_page_json"`
+
+	// SupportContact shown in error pages, v1.5.3
+	SupportContact string `json:"support_contact"`
+
 	// AuthConfig for authentication
 	AuthConfig *OAuthConfig `json:"auth_config"`
 
@@ -0,0 +1,47 @@
+/*
+ * @Copyright Reserved By Janusec (https://www.janusec.com/).
+ * @Author: U2
+ * @Date: 2026-10-18 19:02:16
+ * @Last Modified: U2, 2026-10-18 19:02:16
+ */
+
+package models
+
+// RewriteAction of RewriteRule
+type RewriteAction int64
+
+const (
+	// RewriteAction_REWRITE internal rewrite, replace the path (and query) of the request
+	RewriteAction_REWRITE RewriteAction = 1
+	// RewriteAction_REDIRECT redirect with StatusCode 301, 302, 303, 307 or 308
+	RewriteAction_REDIRECT RewriteAction = 1 << 1
+	// RewriteAction_RETURN return StatusCode with Replacement as body
+	RewriteAction_RETURN RewriteAction = 1 << 2
+)
+
+// RewriteRule of application, evaluated in order of Sequence before routing, v1.5.3
+type RewriteRule struct {
+	ID    int64 `json:"id,string"`
+	AppID int64 `json:"app_id,string"`
+	// Sequence smaller is evaluated first
+	Sequence int64 `json:"sequence"`
+	// Target which Regex match: path (default), query, host or uri (path?query)
+	Target string        `json:"target"`
+	Regex  string        `json:"regex"`
+	Action RewriteAction `json:"action"`
+	// Replacement is the new URI for rewrite, Location for redirect, body for return, $1 etc. are capture groups of Regex
+	Replacement string `json:"replacement"`
+	// StatusCode for redirect (default 302) and return (default 403)
+	StatusCode int64 `json:"status_code"`
+
+	// HeaderName and HeaderRegex is the condition of request header, empty HeaderRegex means the header exists
+	HeaderName  string `json:"header_name"`
+	HeaderRegex string `json:"header_regex"`
+	// IPList is the condition of client IP, comma separated IP or CIDR, such as 10.0.0.0/8,192.168.1.1
+	IPList string `json:"ip_list"`
+
+	// Last stop evaluating the following rules after rewrite
+	Last        bool   `json:"last"`
+	Enabled     bool   `json:"enabled"`
+	Description string `json:"description"`
+}
@@ -107,6 +107,25 @@ type RPCRefererRequest struct {
 	Object   *map[int64]map[string]map[string]map[string]int64 `json:"object"`
 }
 
+// RPCCachePurgesRequest for replica nodes to get the purge commands after the ID in Object, v1.5.3
+type RPCCachePurgesRequest struct {
+	Action  string `json:"action"`
+	AuthKey string `json:"auth_key"`
+	Object  int64  `json:"object"`
+}
+
+type RPCCachePurges struct {
+	Error  *string       `json:"err"`
+	Object []*CachePurge `json:"object"`
+}
+
+// RPCCacheStatsRequest for replica nodes to report the cache statistics, v1.5.3
+type RPCCacheStatsRequest struct {
+	Action  string       `json:"action"`
+	AuthKey string       `json:"auth_key"`
+	Object  []*CacheStat `json:"object"`
+}
+
 type RPCNodeSetting struct {
 	Error  *string           `json:"err"`
 	Object *NodeShareSetting `json:"object"`
@@ -0,0 +1,180 @@
+/*
+ * @Copyright Reserved By Janusec (https://www.janusec.com/).
+ * @Author: U2
+ * @Date: 2026-10-18 17:12:36
+ * @Last Modified: U2, 2026-10-18 17:12:36
+ */
+
+package utils
+
+import (
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// Listener hand-off: the upgraded process inherits the listening sockets as file descriptors 3, 4, ...
+// and the key (network|address) of each descriptor in the environment variable
+const (
+	inheritedListenersEnv = "JANUSEC_INHERITED_LISTENERS"
+	upgradeParentEnv      = "JANUSEC_UPGRADE_PARENT"
+)
+
+// fileListener is implemented by *net.TCPListener and *net.UDPConn
+type fileListener interface {
+	File() (*os.File, error)
+}
+
+var (
+	listenerMutex sync.Mutex
+	// activeListeners map[network|address]fileListener, closed ones are skipped when handing off
+	activeListeners = map[string]fileListener{}
+	// inheritedFiles map[network|address]*os.File, sockets passed by the previous process
+	inheritedFiles map[string]*os.File
+)
+
+func listenerKey(network string, address string) string {
+	return network + "|" + address
+}
+
+func loadInheritedFiles() {
+	if inheritedFiles != nil {
+		return
+	}
+	inheritedFiles = map[string]*os.File{}
+	value := os.Getenv(inheritedListenersEnv)
+	if len(value) == 0 {
+		return
+	}
+	for i, key := range strings.Split(value, ",") {
+		inheritedFiles[key] = os.NewFile(uintptr(3+i), key)
+	}
+	DebugPrintln("Inherited listeners:", value)
+}
+
+// takeInheritedFile return the socket inherited from the previous process, nil if not found
+func takeInheritedFile(key string) *os.File {
+	loadInheritedFiles()
+	file, ok := inheritedFiles[key]
+	if ok {
+		delete(inheritedFiles, key)
+	}
+	return file
+}
+
+// Listen is the same as net.Listen("tcp", address), but reuse the socket inherited from the previous process
+func Listen(address string) (net.Listener, error) {
+	listenerMutex.Lock()
+	defer listenerMutex.Unlock()
+	key := listenerKey("tcp", address)
+	var listener net.Listener
+	var err error
+	if file := takeInheritedFile(key); file != nil {
+		listener, err = net.FileListener(file)
+		file.Close()
+	} else {
+		listener, err = net.Listen("tcp", address)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if fl, ok := listener.(fileListener); ok {
+		activeListeners[key] = fl
+	}
+	return listener, nil
+}
+
+// ListenUDP is the same as net.ListenUDP, but reuse the socket inherited from the previous process
+func ListenUDP(address string) (*net.UDPConn, error) {
+	listenerMutex.Lock()
+	defer listenerMutex.Unlock()
+	key := listenerKey("udp", address)
+	if file := takeInheritedFile(key); file != nil {
+		conn, err := net.FilePacketConn(file)
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+		udpConn, ok := conn.(*net.UDPConn)
+		if !ok {
+			conn.Close()
+			return nil, errors.New("inherited socket is not udp: " + address)
+		}
+		activeListeners[key] = udpConn
+		return udpConn, nil
+	}
+	udpAddr, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	udpConn, err := net.ListenUDP("udp", udpAddr)
+	if err != nil {
+		return nil, err
+	}
+	activeListeners[key] = udpConn
+	return udpConn, nil
+}
+
+// StartUpgradedProcess start the new binary with the listening sockets,
+// the new process sends SIGTERM to this process after its listeners are ready
+func StartUpgradedProcess() error {
+	listenerMutex.Lock()
+	defer listenerMutex.Unlock()
+	keys := []string{}
+	files := []*os.File{}
+	for key, fl := range activeListeners {
+		file, err := fl.File()
+		if err != nil {
+			// closed, such as the port forwarding was deleted
+			delete(activeListeners, key)
+			continue
+		}
+		keys = append(keys, key)
+		files = append(files, file)
+	}
+	defer func() {
+		for _, file := range files {
+			file.Close()
+		}
+	}()
+	exePath, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(exePath, os.Args[1:]...)
+	cmd.Env = append(os.Environ(),
+		inheritedListenersEnv+"="+strings.Join(keys, ","),
+		upgradeParentEnv+"="+strconv.Itoa(os.Getpid()))
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = files
+	err = cmd.Start()
+	if err != nil {
+		return err
+	}
+	DebugPrintln("StartUpgradedProcess pid:", cmd.Process.Pid, "listeners:", keys)
+	return nil
+}
+
+// NotifyUpgradeParent ask the previous process to drain and exit, called after the listeners are ready
+func NotifyUpgradeParent() {
+	value := os.Getenv(upgradeParentEnv)
+	if len(value) == 0 {
+		return
+	}
+	os.Unsetenv(upgradeParentEnv)
+	os.Unsetenv(inheritedListenersEnv)
+	parentPID, err := strconv.Atoi(value)
+	if err != nil || parentPID != os.Getppid() {
+		return
+	}
+	err = syscall.Kill(parentPID, syscall.SIGTERM)
+	if err != nil {
+		DebugPrintln("NotifyUpgradeParent", parentPID, err)
+	}
+}
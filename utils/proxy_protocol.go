@@ -0,0 +1,312 @@
+/*
+ * @Copyright Reserved By Janusec (https://www.janusec.com/).
+ * @Author: U2
+ * @Date: 2026-10-18 16:05:12
+ * @Last Modified: U2, 2026-10-18 16:05:12
+ */
+
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PROXY protocol versions, https://www.haproxy.org/download/2.9/doc/proxy-protocol.txt
+const (
+	ProxyProtocol_NONE int64 = 0
+	ProxyProtocol_V1   int64 = 1
+	ProxyProtocol_V2   int64 = 2
+)
+
+var (
+	proxyV1Prefix  = []byte("PROXY ")
+	proxyV2Sig     = []byte("\r\n\r\n\x00\r\nQUIT\n")
+	errProxyHeader = errors.New("invalid PROXY protocol header")
+	errBlockedIP   = errors.New("blocked client address")
+)
+
+// ProxyListener parse the PROXY protocol header of connections from trusted sources, such as L4 load balancers
+type ProxyListener struct {
+	net.Listener
+	trustedNets   []*net.IPNet
+	headerTimeout time.Duration
+	// isBlocked checks the client address carried by the header, the connection is closed if true
+	isBlocked func(ip string) bool
+}
+
+// NewProxyListener wrap the listener, trustedSources is the list of CIDR or IP
+func NewProxyListener(ln net.Listener, trustedSources []string, headerTimeout time.Duration, isBlocked func(ip string) bool) *ProxyListener {
+	return &ProxyListener{
+		Listener:      ln,
+		trustedNets:   ParseCIDRList(trustedSources),
+		headerTimeout: headerTimeout,
+		isBlocked:     isBlocked,
+	}
+}
+
+// Accept return the connection, the header is parsed on first Read or RemoteAddr in the serving goroutine
+func (ln *ProxyListener) Accept() (net.Conn, error) {
+	conn, err := ln.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	ip, _, _ := net.SplitHostPort(conn.RemoteAddr().String())
+	if !IsIPInNets(net.ParseIP(ip), ln.trustedNets) {
+		// PROXY header from other sources is spoofable
+		return conn, nil
+	}
+	return &proxyConn{Conn: conn, reader: bufio.NewReader(conn), listener: ln}, nil
+}
+
+type proxyConn struct {
+	net.Conn
+	reader     *bufio.Reader
+	listener   *ProxyListener
+	once       sync.Once
+	remoteAddr net.Addr
+	localAddr  net.Addr
+	err        error
+}
+
+func (c *proxyConn) parseHeader() {
+	if c.listener.headerTimeout > 0 {
+		_ = c.Conn.SetReadDeadline(time.Now().Add(c.listener.headerTimeout))
+		defer c.Conn.SetReadDeadline(time.Time{})
+	}
+	c.remoteAddr, c.localAddr, c.err = ReadProxyHeader(c.reader)
+	if c.err != nil {
+		DebugPrintln("ReadProxyHeader", c.Conn.RemoteAddr(), c.err)
+		c.Conn.Close()
+		return
+	}
+	if c.remoteAddr != nil && c.listener.isBlocked != nil {
+		ip, _, _ := net.SplitHostPort(c.remoteAddr.String())
+		if c.listener.isBlocked(ip) {
+			c.err = errBlockedIP
+			c.Conn.Close()
+		}
+	}
+}
+
+func (c *proxyConn) Read(b []byte) (int, error) {
+	c.once.Do(c.parseHeader)
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.reader.Read(b)
+}
+
+// RemoteAddr return the client address in the header, or the peer address if no header (LOCAL command)
+func (c *proxyConn) RemoteAddr() net.Addr {
+	c.once.Do(c.parseHeader)
+	if c.remoteAddr != nil {
+		return c.remoteAddr
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// LocalAddr return the original destination address in the header
+func (c *proxyConn) LocalAddr() net.Addr {
+	c.once.Do(c.parseHeader)
+	if c.localAddr != nil {
+		return c.localAddr
+	}
+	return c.Conn.LocalAddr()
+}
+
+// ReadProxyHeader read PROXY protocol v1 or v2 header, nil addresses are returned if no header or LOCAL command
+func ReadProxyHeader(reader *bufio.Reader) (src net.Addr, dst net.Addr, err error) {
+	first, err := reader.Peek(1)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch first[0] {
+	case proxyV1Prefix[0]:
+		prefix, err := reader.Peek(len(proxyV1Prefix))
+		if err != nil || !bytes.Equal(prefix, proxyV1Prefix) {
+			return nil, nil, nil
+		}
+		return readProxyHeaderV1(reader)
+	case proxyV2Sig[0]:
+		sig, err := reader.Peek(len(proxyV2Sig))
+		if err != nil || !bytes.Equal(sig, proxyV2Sig) {
+			return nil, nil, nil
+		}
+		return readProxyHeaderV2(reader)
+	}
+	// direct connection without header
+	return nil, nil, nil
+}
+
+// readProxyHeaderV1 example: PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\r\n
+func readProxyHeaderV1(reader *bufio.Reader) (net.Addr, net.Addr, error) {
+	// max length of v1 header is 107 bytes
+	line := make([]byte, 0, 107)
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return nil, nil, err
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+		if len(line) >= 107 {
+			return nil, nil, errProxyHeader
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, nil, errProxyHeader
+	}
+	fields := strings.Fields(string(line[:len(line)-2]))
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, nil, errProxyHeader
+	}
+	srcIP := net.ParseIP(fields[2])
+	dstIP := net.ParseIP(fields[3])
+	srcPort, err1 := strconv.ParseUint(fields[4], 10, 16)
+	dstPort, err2 := strconv.ParseUint(fields[5], 10, 16)
+	if srcIP == nil || dstIP == nil || err1 != nil || err2 != nil {
+		return nil, nil, errProxyHeader
+	}
+	return &net.TCPAddr{IP: srcIP, Port: int(srcPort)}, &net.TCPAddr{IP: dstIP, Port: int(dstPort)}, nil
+}
+
+func readProxyHeaderV2(reader *bufio.Reader) (net.Addr, net.Addr, error) {
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, nil, err
+	}
+	if header[12]>>4 != 2 {
+		return nil, nil, errProxyHeader
+	}
+	command := header[12] & 0x0F
+	family := header[13]
+	payload := make([]byte, binary.BigEndian.Uint16(header[14:16]))
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return nil, nil, err
+	}
+	if command == 0x00 {
+		// LOCAL, such as health check of the load balancer
+		return nil, nil, nil
+	}
+	if command != 0x01 {
+		return nil, nil, errProxyHeader
+	}
+	switch family {
+	case 0x11:
+		// TCP over IPv4
+		if len(payload) < 12 {
+			return nil, nil, errProxyHeader
+		}
+		src := &net.TCPAddr{IP: net.IP(payload[0:4]), Port: int(binary.BigEndian.Uint16(payload[8:10]))}
+		dst := &net.TCPAddr{IP: net.IP(payload[4:8]), Port: int(binary.BigEndian.Uint16(payload[10:12]))}
+		return src, dst, nil
+	case 0x21:
+		// TCP over IPv6
+		if len(payload) < 36 {
+			return nil, nil, errProxyHeader
+		}
+		src := &net.TCPAddr{IP: net.IP(payload[0:16]), Port: int(binary.BigEndian.Uint16(payload[32:34]))}
+		dst := &net.TCPAddr{IP: net.IP(payload[16:32]), Port: int(binary.BigEndian.Uint16(payload[34:36]))}
+		return src, dst, nil
+	}
+	// UNSPEC or unix sockets, keep the peer address
+	return nil, nil, nil
+}
+
+// WriteProxyHeader send PROXY protocol header of version 1 or 2 to the backend
+func WriteProxyHeader(w io.Writer, version int64, src net.Addr, dst net.Addr) error {
+	srcAddr, ok1 := src.(*net.TCPAddr)
+	dstAddr, ok2 := dst.(*net.TCPAddr)
+	isIPv4 := ok1 && ok2 && srcAddr.IP.To4() != nil && dstAddr.IP.To4() != nil
+	isIPv6 := ok1 && ok2 && !isIPv4 && srcAddr.IP.To4() == nil && dstAddr.IP.To4() == nil
+	var buf bytes.Buffer
+	switch version {
+	case ProxyProtocol_V1:
+		switch {
+		case isIPv4:
+			buf.WriteString("PROXY TCP4 " + srcAddr.IP.String() + " " + dstAddr.IP.String() + " ")
+		case isIPv6:
+			buf.WriteString("PROXY TCP6 " + srcAddr.IP.String() + " " + dstAddr.IP.String() + " ")
+		default:
+			buf.WriteString("PROXY UNKNOWN\r\n")
+			_, err := w.Write(buf.Bytes())
+			return err
+		}
+		buf.WriteString(strconv.Itoa(srcAddr.Port) + " " + strconv.Itoa(dstAddr.Port) + "\r\n")
+	case ProxyProtocol_V2:
+		buf.Write(proxyV2Sig)
+		switch {
+		case isIPv4:
+			buf.Write([]byte{0x21, 0x11, 0, 12})
+			buf.Write(srcAddr.IP.To4())
+			buf.Write(dstAddr.IP.To4())
+		case isIPv6:
+			buf.Write([]byte{0x21, 0x21, 0, 36})
+			buf.Write(srcAddr.IP.To16())
+			buf.Write(dstAddr.IP.To16())
+		default:
+			// LOCAL command, the backend uses the real connection endpoints
+			buf.Write([]byte{0x20, 0x00, 0, 0})
+			_, err := w.Write(buf.Bytes())
+			return err
+		}
+		_ = binary.Write(&buf, binary.BigEndian, uint16(srcAddr.Port))
+		_ = binary.Write(&buf, binary.BigEndian, uint16(dstAddr.Port))
+	default:
+		return nil
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// ParseCIDRList parse the list of CIDR or IP, a single IP is converted to /32 or /128
+func ParseCIDRList(list []string) []*net.IPNet {
+	nets := []*net.IPNet{}
+	for _, item := range list {
+		item = strings.TrimSpace(item)
+		if len(item) == 0 {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			if ip := net.ParseIP(item); ip != nil && ip.To4() != nil {
+				item += "/32"
+			} else {
+				item += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(item)
+		if err != nil {
+			DebugPrintln("ParseCIDRList", item, err)
+			continue
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets
+}
+
+// IsIPInNets check whether the ip is in one of the networks
+func IsIPInNets(ip net.IP, nets []*net.IPNet) bool {
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
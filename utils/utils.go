@@ -87,6 +87,20 @@ func AccessLog(domain string, method string, ip string, url string, ua string) {
 	}
 }
 
+// GRPCAccessLog record the gRPC method and grpc-status into the access log of domain, v1.5.3
+func GRPCAccessLog(domain string, ip string, fullMethod string, status string, duration time.Duration, ua string) {
+	now := time.Now()
+	f, err := os.OpenFile("./log/"+domain+now.Format("20060102")+".log", os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		log.Printf("error opening file: %s\n", err.Error())
+	}
+	log.SetOutput(f)
+	log.Printf("[%s] GRPC [%s] status:[%s] duration:[%s] UA:[%s]\n", ip, fullMethod, status, duration, ua)
+	if err := f.Close(); err != nil {
+		log.Printf("error closing file: %s\n", err.Error())
+	}
+}
+
 // VipAccessLog record logs of port forwarding
 func VipAccessLog(name string, clientAddr string, gateAddr string, backendAddr string) {
 	now := time.Now()